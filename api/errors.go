@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/JayJosh846/donationPlatform/services"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Machine-readable error codes. Clients should branch on these rather than
+// on messages, which are meant for people and may change.
+const (
+	CodeBadRequest          = "bad_request"
+	CodeInvalidBody         = "invalid_body"
+	CodeValidationFailed    = "validation_failed"
+	CodeUnauthorized        = "unauthorized"
+	CodeInvalidToken        = "invalid_token"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeEmailTaken          = "email_taken"
+	CodePhoneTaken          = "phone_taken"
+	CodeTwoFactorRequired   = "two_factor_required"
+	CodeInvalidTwoFactor    = "invalid_two_factor_code"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeConflict            = "conflict"
+	CodeLimitExceeded       = "limit_exceeded"
+	CodeOtpCooldown         = "otp_cooldown"
+	CodeIdentityMismatch    = "identity_mismatch"
+	CodeFaceMismatch        = "face_mismatch"
+	CodeNoReferencePhoto    = "no_reference_photo"
+	CodeAccountLocked       = "account_locked"
+	CodeRateLimited         = "rate_limited"
+	CodeUploadPrefix        = "upload_"
+	CodeProviderRejected    = "provider_rejected"
+	CodeProviderUnavailable = "provider_unavailable"
+	CodeProviderError       = "provider_error"
+	CodeTimeout             = "timeout"
+	CodeUnavailable         = "unavailable"
+	CodeInternal            = "internal_error"
+)
+
+// Error is an error meant for the API caller. Status and Code say what
+// went wrong, Message says it in words and Data carries any details.
+// Cause is the underlying error; it is logged but never sent.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Data    interface{}
+	Cause   error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithData attaches details for the caller, such as which limit was hit.
+func (e *Error) WithData(data interface{}) *Error {
+	e.Data = data
+	return e
+}
+
+// WithCause records the error behind e for the logs.
+func (e *Error) WithCause(err error) *Error {
+	e.Cause = err
+	return e
+}
+
+func NewError(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return NewError(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// InvalidBody is for a request body that could not be decoded.
+func InvalidBody(err error) *Error {
+	return NewError(http.StatusBadRequest, CodeInvalidBody, "Request body is invalid").WithCause(err)
+}
+
+// Validation reports the fields that failed validation in Data.
+func Validation(err error) *Error {
+	apiErr := NewError(http.StatusBadRequest, CodeValidationFailed, err.Error())
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		fields := make([]FieldError, 0, len(fieldErrs))
+		for _, fieldErr := range fieldErrs {
+			fields = append(fields, FieldError{Field: fieldErr.Field(), Rule: fieldErr.Tag()})
+		}
+		apiErr.Data = fields
+	}
+	return apiErr
+}
+
+// FieldError is one failed validation rule.
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+}
+
+func Unauthorized(message string) *Error {
+	return NewError(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return NewError(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return NewError(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return NewError(http.StatusConflict, CodeConflict, message)
+}
+
+// Internal hides err from the caller behind a generic message.
+func Internal(err error) *Error {
+	return NewError(http.StatusInternalServerError, CodeInternal, "Something went wrong. Please try again.").WithCause(err)
+}
+
+// From turns any error into an *Error. Errors the services return for
+// known conditions get their own status and code; anything else is an
+// internal error.
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var limitErr *services.LimitError
+	if errors.As(err, &limitErr) {
+		return NewError(http.StatusForbidden, CodeLimitExceeded, limitErr.Error()).WithData(limitErr)
+	}
+	var cooldownErr *services.OtpCooldownError
+	if errors.As(err, &cooldownErr) {
+		return NewError(http.StatusTooManyRequests, CodeOtpCooldown, capitalize(cooldownErr.Error()))
+	}
+	var uploadErr *services.UploadError
+	if errors.As(err, &uploadErr) {
+		return fromUploadError(uploadErr)
+	}
+	var providerErr *services.ProviderError
+	if errors.As(err, &providerErr) {
+		return fromProviderError(providerErr)
+	}
+	for _, known := range knownErrors {
+		if errors.Is(err, known.err) {
+			return NewError(known.status, known.code, capitalize(known.err.Error()))
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewError(http.StatusGatewayTimeout, CodeTimeout, "The request took too long. Please try again.").WithCause(err)
+	}
+	return Internal(err)
+}
+
+// knownErrors maps the services' sentinel errors to a status and code.
+var knownErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{services.ErrTwoFactorRequired, http.StatusUnauthorized, CodeTwoFactorRequired},
+	{services.ErrInvalidTwoFactorCode, http.StatusUnauthorized, CodeInvalidTwoFactor},
+	{services.ErrTwoFactorLocked, http.StatusTooManyRequests, "two_factor_locked"},
+	{services.ErrTwoFactorEnabled, http.StatusConflict, "two_factor_enabled"},
+	{services.ErrTwoFactorNotEnabled, http.StatusConflict, "two_factor_not_enabled"},
+	{services.ErrNoPendingEnrollment, http.StatusConflict, "no_pending_enrollment"},
+	{services.ErrOtpInvalid, http.StatusBadRequest, "otp_invalid"},
+	{services.ErrOtpExpired, http.StatusBadRequest, "otp_expired"},
+	{services.ErrOtpTooManyAttempts, http.StatusTooManyRequests, "otp_too_many_attempts"},
+	{services.ErrKycNotPendingReview, http.StatusConflict, "kyc_not_pending_review"},
+	{services.ErrKycAlreadySubmitted, http.StatusConflict, "kyc_already_submitted"},
+	{services.ErrKycReasonRequired, http.StatusBadRequest, CodeValidationFailed},
+	{services.ErrKycFaceNotMatched, http.StatusConflict, "kyc_face_not_matched"},
+	{services.ErrKycPhoneNotVerified, http.StatusConflict, "kyc_phone_not_verified"},
+	{services.ErrIdentityNotFound, http.StatusNotFound, "identity_not_found"},
+	{services.ErrIdentityUnsupported, http.StatusBadRequest, "identity_unsupported"},
+	{services.ErrNoReferencePhoto, http.StatusUnprocessableEntity, CodeNoReferencePhoto},
+	{services.ErrAccountNotLocked, http.StatusConflict, "account_not_locked"},
+	{services.ErrNoHeldTransaction, http.StatusNotFound, CodeNotFound},
+	{services.ErrEmailNotVerified, http.StatusConflict, "email_not_verified"},
+	{services.ErrNoEmail, http.StatusBadRequest, "no_email"},
+	{services.ErrNoPhone, http.StatusBadRequest, "no_phone"},
+	{services.ErrEmailTaken, http.StatusConflict, CodeEmailTaken},
+	{services.ErrPhoneTaken, http.StatusConflict, CodePhoneTaken},
+	{services.ErrUsernameTaken, http.StatusConflict, "username_taken"},
+	{services.ErrInsufficientBalance, http.StatusForbidden, CodeForbidden},
+	{services.ErrTransactionNotPending, http.StatusConflict, "transaction_not_pending"},
+	{services.ErrInvalidAmount, http.StatusBadRequest, CodeValidationFailed},
+	{services.ErrBlobNotFound, http.StatusNotFound, CodeNotFound},
+	{services.ErrInvalidBlobKey, http.StatusNotFound, CodeNotFound},
+}
+
+func fromUploadError(uploadErr *services.UploadError) *Error {
+	status := http.StatusBadRequest
+	switch uploadErr.Code {
+	case services.UploadFileTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case services.UploadUnsupportedType:
+		status = http.StatusUnsupportedMediaType
+	case services.UploadInvalidImage:
+		status = http.StatusUnprocessableEntity
+	}
+	return NewError(status, CodeUploadPrefix+uploadErr.Code, uploadErr.Message).WithData(uploadErr)
+}
+
+// fromProviderError only passes a provider's own message on when the
+// request itself was refused; outages and credential problems are ours.
+func fromProviderError(providerErr *services.ProviderError) *Error {
+	switch {
+	case errors.Is(providerErr, services.ErrProviderRejected), errors.Is(providerErr, services.ErrProviderNotFound):
+		message := providerErr.Message
+		if message == "" {
+			message = providerErr.Kind.Error()
+		}
+		return NewError(http.StatusBadRequest, CodeProviderRejected, capitalize(message)).WithCause(providerErr)
+	case errors.Is(providerErr, services.ErrProviderUnavailable), errors.Is(providerErr, services.ErrProviderRateLimited):
+		message := fmt.Sprintf("The %s service is unavailable. Please try again later.", providerErr.Provider)
+		return NewError(http.StatusServiceUnavailable, CodeProviderUnavailable, message).WithCause(providerErr)
+	}
+	return NewError(http.StatusBadGateway, CodeProviderError, "Something went wrong. Please try again.").WithCause(providerErr)
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// NotFoundOr reports a missing record as a 404 with message, and any other
+// lookup failure as From would.
+func NotFoundOr(err error, message string) *Error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return NotFound(message)
+	}
+	return From(err)
+}
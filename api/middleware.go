@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler renders the errors handlers pass to Fail, and turns a panic
+// anywhere further down the chain into a 500 instead of a dropped
+// connection. It should be the first middleware.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				slog.ErrorContext(c.Request.Context(), "panic serving request", "method", c.Request.Method, "route", c.FullPath(),
+					"panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+				writeError(c, Internal(fmt.Errorf("panic: %v", recovered)))
+			}
+		}()
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+		apiErr := From(c.Errors.Last().Err)
+		if apiErr.Status >= http.StatusInternalServerError {
+			slog.ErrorContext(c.Request.Context(), "error serving request", "method", c.Request.Method, "route", c.FullPath(),
+				"code", apiErr.Code, "err", apiErr)
+		}
+		writeError(c, apiErr)
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Envelope is the body of every response. Code is only set on errors.
+type Envelope struct {
+	Error         bool        `json:"error"`
+	Response_Code int         `json:"response code"`
+	Code          string      `json:"code,omitempty"`
+	Message       string      `json:"message"`
+	Data          interface{} `json:"data"`
+}
+
+// Respond writes a successful response.
+func Respond(c *gin.Context, status int, message string, data interface{}) {
+	c.JSON(status, Envelope{
+		Response_Code: status,
+		Message:       message,
+		Data:          data,
+	})
+}
+
+// OK writes a 200 response.
+func OK(c *gin.Context, message string, data interface{}) {
+	Respond(c, http.StatusOK, message, data)
+}
+
+// Created writes a 201 response for a request that created something.
+func Created(c *gin.Context, message string, data interface{}) {
+	Respond(c, http.StatusCreated, message, data)
+}
+
+// Accepted writes a 202 response for a request whose outcome is settled
+// later.
+func Accepted(c *gin.Context, message string, data interface{}) {
+	Respond(c, http.StatusAccepted, message, data)
+}
+
+// Fail records err on the request and stops the handler chain. ErrorHandler
+// writes the response, so handlers only need to return afterwards.
+func Fail(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// writeError renders err unless a response has already been written.
+func writeError(c *gin.Context, apiErr *Error) {
+	if c.Writer.Written() {
+		return
+	}
+	c.AbortWithStatusJSON(apiErr.Status, Envelope{
+		Error:         true,
+		Response_Code: apiErr.Status,
+		Code:          apiErr.Code,
+		Message:       apiErr.Message,
+		Data:          apiErr.Data,
+	})
+}
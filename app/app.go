@@ -0,0 +1,183 @@
+// Package app wires the configuration, the database, the services and the
+// controllers into a server.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/config"
+	"github.com/JayJosh846/donationPlatform/database"
+	"github.com/JayJosh846/donationPlatform/migrations"
+	"github.com/JayJosh846/donationPlatform/telemetry"
+	helper "github.com/JayJosh846/donationPlatform/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// App owns the single database client and everything built on it.
+type App struct {
+	Config   *config.Config
+	Client   *mongo.Client
+	Services *Services
+	Router   *gin.Engine
+	health   *health
+	// stopTracing flushes spans that have not been exported yet.
+	stopTracing func(context.Context) error
+}
+
+// New starts tracing, connects to the configured database, migrates it
+// unless told not to and builds the server.
+func New(ctx context.Context, cfg *config.Config) (*App, error) {
+	stopTracing, err := telemetry.SetupTracing(ctx, cfg.Telemetry)
+	if err != nil {
+		return nil, err
+	}
+	client, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		stopTracing(ctx)
+		return nil, err
+	}
+	db := client.Database(cfg.Database.Name)
+	if err := migrate(ctx, cfg.Database, db); err != nil {
+		database.CloseMongoDBConnection(client)
+		return nil, err
+	}
+	s, err := NewMongoServices(ctx, cfg, db)
+	if err != nil {
+		database.CloseMongoDBConnection(client)
+		return nil, err
+	}
+	a, err := NewWithServices(cfg, s)
+	if err != nil {
+		database.CloseMongoDBConnection(client)
+		return nil, err
+	}
+	a.Client = client
+	a.stopTracing = stopTracing
+	a.AddHealthCheck(MongoCheck(client))
+	if cfg.Server.Check_Providers {
+		a.AddHealthCheck(HTTPCheck("paystack", cfg.Paystack.Base_URL))
+		if cfg.Identity.Provider == "checkid" {
+			a.AddHealthCheck(HTTPCheck("checkid", cfg.Identity.CheckID_Base_URL))
+		}
+	}
+	return a, nil
+}
+
+// NewWithServices builds the server on top of s without touching the
+// database, so tests can run it against fakes.
+func NewWithServices(cfg *config.Config, s *Services) (*App, error) {
+	helper.SECRET_KEY = cfg.Auth.Secret_Key
+	router, err := NewRouter(cfg, s)
+	if err != nil {
+		return nil, err
+	}
+	h := &health{}
+	router.GET("/healthz", h.Healthz)
+	router.GET("/readyz", h.Readyz)
+	if cfg.Telemetry.Metrics_Enabled {
+		router.GET("/metrics", gin.WrapH(telemetry.Handler(cfg.Telemetry.Metrics_Token)))
+	}
+	return &App{Config: cfg, Services: s, Router: router, health: h}, nil
+}
+
+// AddHealthCheck makes /readyz depend on check. It must be called before
+// the server starts.
+func (a *App) AddHealthCheck(check HealthCheck) {
+	a.health.checks = append(a.health.checks, check)
+}
+
+// Run serves HTTP until ctx is cancelled, then stops accepting
+// connections, waits for in-flight requests and background work to finish
+// and closes the database client.
+func (a *App) Run(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              a.Config.Server.Addr(),
+		Handler:           a.Router,
+		ReadTimeout:       a.Config.Server.Read_Timeout,
+		ReadHeaderTimeout: a.Config.Server.Read_Header_Timeout,
+		WriteTimeout:      a.Config.Server.Write_Timeout,
+		IdleTimeout:       a.Config.Server.Idle_Timeout,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "addr", server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		a.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down")
+	a.health.draining.Store(true)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.Config.Server.Shutdown_Timeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	if a.Services.Workers != nil {
+		if waitErr := a.Services.Workers.Wait(shutdownCtx); waitErr != nil {
+			slog.Warn("background work did not finish", "err", waitErr)
+		}
+	}
+	a.Close()
+	if a.stopTracing != nil {
+		if stopErr := a.stopTracing(shutdownCtx); stopErr != nil {
+			slog.Warn("flushing traces", "err", stopErr)
+		}
+	}
+	if errors.Is(<-serveErr, http.ErrServerClosed) {
+		slog.Info("server stopped")
+	}
+	return err
+}
+
+// Close releases the database client.
+func (a *App) Close() {
+	database.CloseMongoDBConnection(a.Client)
+}
+
+// migrateLockWait is how long an instance waits for another one starting
+// at the same time to finish migrating.
+const migrateLockWait = 2 * time.Minute
+
+// migrate applies pending migrations, or with MIGRATE_ON_START off only
+// warns about them. Instances starting together take turns through the
+// migration lock; one that cannot get it in time fails rather than serve
+// an unmigrated database.
+func migrate(ctx context.Context, cfg config.DatabaseConfig, db *mongo.Database) error {
+	m := migrations.New(db)
+	if !cfg.Migrate_On_Start {
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			slog.Warn("database has pending migrations; run cmd/migrate up", "pending", len(pending))
+		}
+		return nil
+	}
+	deadline := time.Now().Add(migrateLockWait)
+	for {
+		_, err := m.Up(ctx, 0)
+		if !errors.Is(err, migrations.ErrLocked) || time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("migrating database: %w", err)
+			}
+			return nil
+		}
+		slog.Info("waiting for another instance to finish migrating")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/api"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// HealthCheck reports whether a dependency can serve traffic.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+const healthCheckTimeout = 3 * time.Second
+
+// MongoCheck pings the primary.
+func MongoCheck(client *mongo.Client) HealthCheck {
+	return HealthCheck{Name: "mongo", Check: func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	}}
+}
+
+// HTTPCheck treats any response below 500 from url as reachable. Provider
+// APIs answer unauthenticated requests with 401 or 404, which still shows
+// the network path works.
+func HTTPCheck(name, url string) HealthCheck {
+	client := &http.Client{Timeout: healthCheckTimeout}
+	return HealthCheck{Name: name, Check: func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode >= 500 {
+			return fmt.Errorf("unexpected status %d", res.StatusCode)
+		}
+		return nil
+	}}
+}
+
+type health struct {
+	checks   []HealthCheck
+	draining atomic.Bool
+}
+
+// Healthz only says the process is up and serving.
+func (h *health) Healthz(c *gin.Context) {
+	api.OK(c, "ok", "")
+}
+
+// Readyz runs every check and fails while the server is shutting down, so
+// load balancers stop sending traffic before connections are closed.
+func (h *health) Readyz(c *gin.Context) {
+	if h.draining.Load() {
+		api.Fail(c, api.NewError(http.StatusServiceUnavailable, api.CodeUnavailable, "shutting down"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+	results := make(map[string]string, len(h.checks))
+	ready := true
+	for _, check := range h.checks {
+		if err := check.Check(ctx); err != nil {
+			results[check.Name] = err.Error()
+			ready = false
+			continue
+		}
+		results[check.Name] = "ok"
+	}
+	if !ready {
+		api.Fail(c, api.NewError(http.StatusServiceUnavailable, api.CodeUnavailable, "not ready").WithData(results))
+		return
+	}
+	api.OK(c, "ready", results)
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/api"
+	"github.com/JayJosh846/donationPlatform/config"
+	"github.com/JayJosh846/donationPlatform/controllers"
+	"github.com/JayJosh846/donationPlatform/logging"
+	"github.com/JayJosh846/donationPlatform/middleware"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Limits for the unauthenticated and payment endpoints. Each can be
+// overridden in the configuration, e.g. RATE_LIMIT_PAYIN=5/1m.
+func rateLimitRules() []middleware.RateLimitRule {
+	return []middleware.RateLimitRule{
+		{Name: "payin", Route: "/api/v1/payment/payin", Limit: middleware.RateLimit{Requests: 10, Per: time.Minute}, Key: middleware.KeyByIP},
+		{Name: "banks", Route: "/api/v1/payment/banks", Limit: middleware.RateLimit{Requests: 30, Per: time.Minute}, Key: middleware.KeyByIP},
+		{Name: "signup", Route: "/api/v1/user/signup", Limit: middleware.RateLimit{Requests: 5, Per: time.Hour}, Key: middleware.KeyByIP},
+		{Name: "users", Route: "/api/v1/user/all", Limit: middleware.RateLimit{Requests: 60, Per: time.Minute}, Key: middleware.KeyByIP},
+		{Name: "profile", Route: "/api/v1/user/profile-noauth", Limit: middleware.RateLimit{Requests: 60, Per: time.Minute}, Key: middleware.KeyByIP},
+		{Name: "payouts", Route: "/api/v1/payment/payouts", Limit: middleware.RateLimit{Requests: 5, Per: time.Minute}, Key: middleware.KeyByUser},
+	}
+}
+
+// NewRouter builds the controllers from s and registers every route.
+func NewRouter(cfg *config.Config, s *Services) (*gin.Engine, error) {
+	uc := controllers.Constructor(s.User, s.Transaction, s.Donation, s.Bank, s.Payment, s.TwoFactor, s.Security, s.Kyc, s.Identity, s.FaceMatcher, s.Blobs, s.PublicBlobs, s.Audit)
+	pc := controllers.PaymentConstructor(s.Payment, s.User, s.Transaction, s.Donation, s.Bank, s.TwoFactor, s.Limit, s.Audit, s.Wallet)
+	ac := controllers.AdminConstructor(s.User, s.Transaction, s.Donation, s.Security, s.Kyc, s.Limit, s.Audit, s.Wallet, s.Payment, s.TwoFactor)
+	fc := controllers.FileConstructor(s.User, s.Kyc, s.Blobs, s.PublicBlobs)
+
+	server := gin.New()
+	// Client IPs key the login throttle and the rate limits, so
+	// X-Forwarded-For is only read from the configured proxies.
+	if err := server.SetTrustedProxies(cfg.Server.Proxies()); err != nil {
+		return nil, err
+	}
+	// The error handler goes before the other middleware so it also renders
+	// their errors and panics, but after the tracing, logging and metrics
+	// so the status it writes is the one they record.
+	server.Use(
+		otelgin.Middleware(cfg.Telemetry.Service_Name),
+		middleware.RequestID(),
+		middleware.AccessLog(),
+		middleware.Metrics(),
+		api.ErrorHandler(),
+	)
+	// Define CORS configuration with specific allowed origins
+	corsConfig := cors.DefaultConfig()
+
+	// Allow specific origins
+	corsConfig.AllowAllOrigins = true
+
+	corsConfig.AllowHeaders = append(corsConfig.AllowHeaders, "token", logging.RequestIDHeader)
+	corsConfig.ExposeHeaders = append(corsConfig.ExposeHeaders, logging.RequestIDHeader)
+
+	// To be able to send tokens to the server.
+	corsConfig.AllowCredentials = true
+
+	// OPTIONS method for ReactJS
+	corsConfig.AddAllowMethods("OPTIONS")
+
+	// Register the middleware
+	server.Use(cors.New(corsConfig))
+	server.Use(middleware.RequestTimeout(cfg.Server.Request_Timeout))
+
+	rules, err := middleware.ApplyRateLimitOverrides(rateLimitRules(), cfg.Rate_Limit.Overrides)
+	if err != nil {
+		return nil, err
+	}
+	server.Use(middleware.RateLimiter(s.RateLimits, rules))
+
+	basepath := server.Group("/api/v1")
+	uc.UserRoutes(basepath)
+	ac.AdminRoute(basepath)
+	pc.PaymentRoute(basepath, cfg.Paystack.Secret_Key)
+	fc.FileRoute(basepath)
+	return server, nil
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+
+	"github.com/JayJosh846/donationPlatform/config"
+	"github.com/JayJosh846/donationPlatform/middleware"
+	"github.com/JayJosh846/donationPlatform/repository"
+	"github.com/JayJosh846/donationPlatform/services"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Services is everything the controllers depend on. Tests can build one
+// with fakes in place of any of the Mongo backed services.
+type Services struct {
+	User        services.UserService
+	Payment     services.PaymentService
+	Transaction services.TransactionService
+	Donation    services.DonationService
+	Bank        services.BankService
+	TwoFactor   services.TwoFactorService
+	Security    services.SecurityService
+	Otp         services.OtpService
+	Kyc         services.KycService
+	Limit       services.LimitService
+	Identity    services.IdentityVerifier
+	FaceMatcher services.FaceMatcher
+	Blobs       services.BlobStore
+	PublicBlobs *services.PublicBlobStore
+	Audit       services.AuditService
+	Ledger      services.LedgerService
+	Wallet      services.WalletService
+	RateLimits  middleware.RateLimitStore
+	Workers     *services.Workers
+}
+
+// Dependencies are the outside systems the services talk to. Tests can
+// fill them with fakes.
+type Dependencies struct {
+	Mailer      services.Mailer
+	SMS         services.SMSSender
+	Paystack    *services.ProviderClient
+	Identity    services.IdentityVerifier
+	FaceMatcher services.FaceMatcher
+	Blobs       services.BlobStore
+	PublicBlobs *services.PublicBlobStore
+	UnitOfWork  services.UnitOfWork
+	RateLimits  middleware.RateLimitStore
+}
+
+// NewMongoServices builds the services on top of db. Their indexes are
+// created by the migrations.
+func NewMongoServices(ctx context.Context, cfg *config.Config, db *mongo.Database) (*Services, error) {
+	blobs, err := services.NewBlobStore(ctx, db, cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	publicBlobs, err := services.NewPublicBlobStore(ctx, db, cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	unitOfWork, err := services.NewUnitOfWork(ctx, db.Client(), cfg.Server.Dev())
+	if err != nil {
+		return nil, err
+	}
+	deps := Dependencies{
+		Mailer:      services.NewMailer(cfg.Mail),
+		SMS:         services.NewSMSSender(cfg.SMS),
+		Paystack:    services.NewPaystackClient(cfg.Paystack, cfg.Providers),
+		Identity:    services.NewIdentityVerifier(cfg.Identity, cfg.Providers),
+		FaceMatcher: services.NewFaceMatcher(cfg.Face_Match),
+		Blobs:       blobs,
+		PublicBlobs: publicBlobs,
+		UnitOfWork:  unitOfWork,
+	}
+	// The in-memory store is the default; use the mongo backend when
+	// running several instances.
+	if cfg.Rate_Limit.Backend == "mongo" {
+		deps.RateLimits = middleware.NewMongoRateLimitStore(db.Collection("RateLimits"))
+	} else {
+		deps.RateLimits = middleware.NewMemoryRateLimitStore()
+	}
+	return NewServices(cfg, repository.NewMongo(db), deps)
+}
+
+// NewServices builds the services on repos and deps.
+func NewServices(cfg *config.Config, repos *repository.Repositories, deps Dependencies) (*Services, error) {
+	tierLimits, err := services.ParseTierLimits(cfg.Limits.Tier_Limits)
+	if err != nil {
+		return nil, err
+	}
+	otps := services.OtpConstructor(repos.Otps)
+	workers := services.NewWorkers()
+	s := &Services{
+		User:        services.Constructor(repos.Users, repos.Kycs, repos.Socials, repos.Donations, otps, deps.SMS, deps.Mailer),
+		Payment:     services.PaymentConstructor(repos.Users, deps.Paystack),
+		Transaction: services.TransactionConstructor(repos.Transactions),
+		Donation:    services.DonationConstructor(repos.Donations),
+		Bank:        services.BankConstructor(repos.Banks),
+		TwoFactor:   services.TwoFactorConstructor(repos.Users, repos.LoginAttempts, otps, deps.Mailer),
+		Security:    services.SecurityConstructor(repos.LoginAttempts, repos.SecurityEvents),
+		Otp:         otps,
+		Kyc:         services.KycConstructor(deps.UnitOfWork, repos.Kycs, repos.Users, deps.Blobs, deps.Mailer, workers),
+		Limit:       services.LimitConstructor(repos.LimitUsage, repos.Kycs, tierLimits),
+		Identity:    deps.Identity,
+		FaceMatcher: deps.FaceMatcher,
+		Audit:       services.AuditConstructor(repos.Audit),
+		Ledger:      services.LedgerConstructor(repos.Ledger),
+		Blobs:       deps.Blobs,
+		PublicBlobs: deps.PublicBlobs,
+		RateLimits:  deps.RateLimits,
+		Workers:     workers,
+	}
+	s.Wallet = services.WalletConstructor(deps.UnitOfWork, s.User, s.Transaction, s.Ledger, s.Limit)
+	return s, nil
+}
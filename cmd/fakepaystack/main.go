@@ -0,0 +1,50 @@
+// Command fakepaystack serves the fake Paystack API for local development.
+// Point the server at it with PAYSTACK_BASE_URL=http://localhost:9100 and
+// give both the same PAYSTACK_SEC_KEY.
+//
+// Opening the authorization_url a payin returns pays the donation and
+// sends charge.success to the webhook URL. Transfers are settled with
+// -transfer-event after -transfer-delay, and every ten digit account
+// number resolves unless -resolve-any=false.
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/paystackfake"
+)
+
+func main() {
+	addr := flag.String("addr", ":9100", "address to listen on")
+	secret := flag.String("secret", os.Getenv("PAYSTACK_SEC_KEY"), "secret key clients must send and webhooks are signed with")
+	webhookURL := flag.String("webhook-url", "http://localhost:9000/api/v1/payment/confirmation", "where webhooks are sent; empty sends none")
+	latency := flag.Duration("latency", 0, "delay before every API response")
+	failureRate := flag.Float64("failure-rate", 0, "fraction of API requests answered with a 500")
+	transferEvent := flag.String("transfer-event", paystackfake.EventTransferSuccess, "event sent for every transfer; empty leaves transfers pending")
+	transferDelay := flag.Duration("transfer-delay", 2*time.Second, "how long after a transfer its event is sent")
+	resolveAny := flag.Bool("resolve-any", true, "resolve every ten digit account number")
+	flag.Parse()
+
+	fake := paystackfake.New(paystackfake.Config{
+		Secret_Key:     *secret,
+		Webhook_URL:    *webhookURL,
+		Latency:        *latency,
+		Failure_Rate:   *failureRate,
+		Transfer_Event: *transferEvent,
+		Transfer_Delay: *transferDelay,
+		Resolve_Any:    *resolveAny,
+	})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.Info("request", "method", r.Method, "path", r.URL.Path)
+		fake.ServeHTTP(w, r)
+	})
+	slog.Info("fake paystack listening", "addr", *addr, "webhook_url", *webhookURL)
+	if err := http.ListenAndServe(*addr, handler); err != nil {
+		slog.Error("serving", "err", err)
+		os.Exit(1)
+	}
+}
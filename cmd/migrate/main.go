@@ -0,0 +1,139 @@
+// Command migrate applies and reverts database migrations.
+//
+//	migrate status            list migrations and whether they are applied
+//	migrate up [-to N]        apply pending migrations, up to version N
+//	migrate down [-steps N]   revert the last N applied migrations (default 1)
+//	migrate down -to N        revert every migration above version N
+//	migrate unlock            clear the lock left by a killed run
+//
+// It reads only the database and log settings, from the same sources as
+// the server.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/JayJosh846/donationPlatform/config"
+	"github.com/JayJosh846/donationPlatform/database"
+	"github.com/JayJosh846/donationPlatform/logging"
+	"github.com/JayJosh846/donationPlatform/migrations"
+)
+
+const usage = `usage: migrate <command> [flags]
+
+commands:
+  status            list migrations and whether they are applied
+  up [-to N]        apply pending migrations, up to version N
+  down [-steps N]   revert the last N applied migrations (default 1)
+  down -to N        revert every migration above version N
+  unlock            clear the lock left by a killed run
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "status", "up", "down", "unlock":
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	to := flags.Int("to", 0, "target version")
+	steps := flags.Int("steps", 0, "number of migrations to revert")
+	flags.Parse(args)
+
+	cfg, err := config.LoadDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logging.New(cfg.Log, os.Stderr))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg, command, *to, *steps); err != nil {
+		slog.Error("migrate "+command, "err", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg *config.Config, command string, to, steps int) error {
+	client, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer database.CloseMongoDBConnection(client)
+	m := migrations.New(client.Database(cfg.Database.Name))
+
+	switch command {
+	case "status":
+		return printStatus(ctx, m)
+	case "up":
+		applied, err := m.Up(ctx, to)
+		fmt.Printf("applied %d migration(s)\n", len(applied))
+		return err
+	case "down":
+		if to == 0 {
+			if to, err = stepsTarget(ctx, m, steps); err != nil {
+				return err
+			}
+		}
+		reverted, err := m.Down(ctx, to)
+		fmt.Printf("reverted %d migration(s)\n", len(reverted))
+		return err
+	}
+	return m.Unlock(ctx)
+}
+
+// stepsTarget is the version left applied after reverting the last steps
+// applied migrations.
+func stepsTarget(ctx context.Context, m *migrations.Migrator, steps int) (int, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var applied []int
+	for _, s := range statuses {
+		if s.Applied {
+			applied = append(applied, s.Version)
+		}
+	}
+	if steps >= len(applied) {
+		return 0, nil
+	}
+	return applied[len(applied)-steps-1], nil
+}
+
+func printStatus(ctx context.Context, m *migrations.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.Applied {
+			applied = s.Applied_At.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return w.Flush()
+}
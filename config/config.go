@@ -0,0 +1,318 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Config is everything the service needs to start. Each field can be set
+// in the YAML file named by CONFIG_FILE and overridden by the environment
+// variable in its env tag. Any variable can instead be read from a file by
+// setting <NAME>_FILE, which is how Docker and Kubernetes mount secrets.
+type Config struct {
+	Server     ServerConfig    `yaml:"server"`
+	Log        LogConfig       `yaml:"log"`
+	Telemetry  TelemetryConfig `yaml:"telemetry"`
+	Database   DatabaseConfig  `yaml:"database"`
+	Auth       AuthConfig      `yaml:"auth"`
+	Paystack   PaystackConfig  `yaml:"paystack"`
+	Providers  ProvidersConfig `yaml:"providers"`
+	Mail       MailConfig      `yaml:"mail"`
+	SMS        SMSConfig       `yaml:"sms"`
+	Identity   IdentityConfig  `yaml:"identity"`
+	Face_Match FaceMatchConfig `yaml:"face_match"`
+	Storage    StorageConfig   `yaml:"storage"`
+	Limits     LimitsConfig    `yaml:"limits"`
+	Rate_Limit RateLimitConfig `yaml:"rate_limit"`
+}
+
+type ServerConfig struct {
+	// Env is production, or development to allow the log, stub and
+	// non-transactional stand-ins meant for local use.
+	Env                 string        `yaml:"env" env:"APP_ENV" default:"production"`
+	Port                string        `yaml:"port" env:"PORT" default:"9000"`
+	Read_Timeout        time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT" default:"30s"`
+	Read_Header_Timeout time.Duration `yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" default:"10s"`
+	Write_Timeout       time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" default:"60s"`
+	Idle_Timeout        time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" default:"120s"`
+	// Request_Timeout is the deadline handlers and the services they call
+	// work under.
+	Request_Timeout time.Duration `yaml:"request_timeout" env:"SERVER_REQUEST_TIMEOUT" default:"30s"`
+	// Shutdown_Timeout bounds how long in-flight requests and background
+	// work are given to finish after SIGTERM.
+	Shutdown_Timeout time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"30s"`
+	// Check_Providers adds the payment and identity providers to /readyz.
+	Check_Providers bool `yaml:"check_providers" env:"READY_CHECK_PROVIDERS" default:"false"`
+	// Trusted_Proxies is a comma-separated list of the IPs or CIDRs of the
+	// load balancers in front of the server. Only their X-Forwarded-For is
+	// believed; with none the client IP is the peer address.
+	Trusted_Proxies string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+// Dev reports whether the server runs in development, where providers may
+// be stand-ins that do not reach anyone.
+func (s ServerConfig) Dev() bool {
+	return s.Env == "development"
+}
+
+// Proxies lists the trusted proxies, nil when there are none.
+func (s ServerConfig) Proxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(s.Trusted_Proxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// Addr is the address the HTTP server listens on.
+func (s ServerConfig) Addr() string {
+	return ":" + s.Port
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level" env:"LOG_LEVEL" default:"info"`
+	// Format is json, or text for local development.
+	Format string `yaml:"format" env:"LOG_FORMAT" default:"json"`
+}
+
+type TelemetryConfig struct {
+	// Metrics_Token, when set, must be sent as a bearer token to read
+	// /metrics.
+	Metrics_Enabled bool   `yaml:"metrics_enabled" env:"METRICS_ENABLED" default:"true"`
+	Metrics_Token   string `yaml:"metrics_token" env:"METRICS_TOKEN"`
+	// Tracing exports spans over OTLP/HTTP to a collector at
+	// Tracing_Endpoint.
+	Tracing_Enabled  bool   `yaml:"tracing_enabled" env:"TRACING_ENABLED" default:"false"`
+	Tracing_Endpoint string `yaml:"tracing_endpoint" env:"TRACING_ENDPOINT" default:"localhost:4318"`
+	Tracing_Insecure bool   `yaml:"tracing_insecure" env:"TRACING_INSECURE" default:"true"`
+	Service_Name     string `yaml:"service_name" env:"OTEL_SERVICE_NAME" default:"donation-platform"`
+}
+
+type DatabaseConfig struct {
+	URL  string `yaml:"url" env:"DATABASE_URL" required:"true"`
+	Name string `yaml:"name" env:"DATABASE_NAME" default:"Pocdonation"`
+	// Migrate_On_Start applies pending migrations before the server starts
+	// serving. Turn it off to run them with cmd/migrate instead.
+	Migrate_On_Start bool `yaml:"migrate_on_start" env:"MIGRATE_ON_START" default:"true"`
+}
+
+type AuthConfig struct {
+	// Secret_Key signs access tokens and file links.
+	Secret_Key string `yaml:"secret_key" env:"SECRETS" required:"true"`
+}
+
+type PaystackConfig struct {
+	Secret_Key string `yaml:"secret_key" env:"PAYSTACK_SEC_KEY" required:"true"`
+	Base_URL   string `yaml:"base_url" env:"PAYSTACK_BASE_URL" default:"https://api.paystack.co"`
+}
+
+// ProvidersConfig tunes the HTTP client shared by the payment and identity
+// providers.
+type ProvidersConfig struct {
+	// Timeout bounds a single attempt; the whole call, retries included, is
+	// still bounded by the request.
+	Timeout time.Duration `yaml:"timeout" env:"PROVIDER_TIMEOUT" default:"15s"`
+	// Max_Retries is how many times a safe call is repeated after a network
+	// error, a 429 or a 5xx. Calls that move money are never retried.
+	Max_Retries       int           `yaml:"max_retries" env:"PROVIDER_MAX_RETRIES" default:"2"`
+	Retry_Backoff     time.Duration `yaml:"retry_backoff" env:"PROVIDER_RETRY_BACKOFF" default:"200ms"`
+	Retry_Max_Backoff time.Duration `yaml:"retry_max_backoff" env:"PROVIDER_RETRY_MAX_BACKOFF" default:"2s"`
+	// The breaker opens after Breaker_Threshold failures in a row and lets
+	// a single call through again once Breaker_Cooldown has passed.
+	Breaker_Threshold int           `yaml:"breaker_threshold" env:"PROVIDER_BREAKER_THRESHOLD" default:"5"`
+	Breaker_Cooldown  time.Duration `yaml:"breaker_cooldown" env:"PROVIDER_BREAKER_COOLDOWN" default:"30s"`
+}
+
+type MailConfig struct {
+	// Provider is smtp, or log to only print messages in development.
+	Provider string `yaml:"provider" env:"MAIL_PROVIDER" default:"smtp"`
+	Host     string `yaml:"host" env:"SMTP_HOST" default:"smtp.gmail.com"`
+	Port     string `yaml:"port" env:"SMTP_PORT" default:"587"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD"`
+	// From defaults to Username.
+	From string `yaml:"from" env:"MAIL_FROM"`
+}
+
+type SMSConfig struct {
+	// Provider is termii, twilio, or empty to only log messages in
+	// development.
+	Provider           string `yaml:"provider" env:"SMS_PROVIDER"`
+	Termii_API_Key     string `yaml:"termii_api_key" env:"TERMII_API_KEY"`
+	Termii_Sender_ID   string `yaml:"termii_sender_id" env:"TERMII_SENDER_ID"`
+	Twilio_Account_SID string `yaml:"twilio_account_sid" env:"TWILIO_ACCOUNT_SID"`
+	Twilio_Auth_Token  string `yaml:"twilio_auth_token" env:"TWILIO_AUTH_TOKEN"`
+	Twilio_From        string `yaml:"twilio_from" env:"TWILIO_FROM"`
+}
+
+type IdentityConfig struct {
+	// Provider is checkid or fake.
+	Provider         string `yaml:"provider" env:"IDENTITY_PROVIDER" default:"checkid"`
+	CheckID_Base_URL string `yaml:"checkid_base_url" env:"CHECKID_BASE_URL" default:"https://sandbox.checkid.ng"`
+	CheckID_Sec_Key  string `yaml:"checkid_sec_key" env:"CHECKID_SEC_KEY"`
+}
+
+type FaceMatchConfig struct {
+	// Provider is http, or stub for local development. The stub only
+	// matches identical images.
+	Provider string `yaml:"provider" env:"FACE_MATCH_PROVIDER" default:"stub"`
+	URL      string `yaml:"url" env:"FACE_MATCH_URL"`
+	API_Key  string `yaml:"api_key" env:"FACE_MATCH_API_KEY"`
+}
+
+type StorageConfig struct {
+	// Backend holds KYC files: gridfs, gcs or local.
+	Backend              string `yaml:"backend" env:"BLOB_BACKEND" default:"gridfs"`
+	GCS_Bucket           string `yaml:"gcs_bucket" env:"GCS_BUCKET" default:"poc-donation-bucket1"`
+	GCS_Credentials_File string `yaml:"gcs_credentials_file" env:"GCS_CREDENTIALS_FILE"`
+	Local_Dir            string `yaml:"local_dir" env:"BLOB_LOCAL_DIR" default:"uploads"`
+	// Public_Backend holds files anyone may read, such as profile pictures.
+	Public_Backend    string `yaml:"public_backend" env:"PUBLIC_BLOB_BACKEND" default:"gridfs"`
+	GCS_Public_Bucket string `yaml:"gcs_public_bucket" env:"GCS_PUBLIC_BUCKET"`
+	Public_Local_Dir  string `yaml:"public_local_dir" env:"PUBLIC_BLOB_LOCAL_DIR" default:"uploads-public"`
+	// Public_Base_URL overrides where public files are served from.
+	Public_Base_URL string `yaml:"public_base_url" env:"PUBLIC_BLOB_BASE_URL"`
+}
+
+type LimitsConfig struct {
+	// Tier_Limits is a JSON object replacing tiers of the default limit
+	// table, e.g. {"1": {"max_single_donation": 20000, ...}}.
+	Tier_Limits string `yaml:"tier_limits" env:"TIER_LIMITS"`
+}
+
+type RateLimitConfig struct {
+	// Backend is memory, or mongo when running several instances.
+	Backend string `yaml:"backend" env:"RATE_LIMIT_BACKEND" default:"memory"`
+	// Overrides replaces the limit of a named rule, e.g. payin: 5/1m. Each
+	// can also be set with RATE_LIMIT_<NAME>.
+	Overrides map[string]string `yaml:"overrides" envPrefix:"RATE_LIMIT_"`
+}
+
+// Validate reports every missing or inconsistent setting at once, so a
+// misconfigured deployment fails at startup rather than on first use.
+func (c *Config) Validate() error {
+	var errs []error
+	require := func(value, name, reason string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required %s", name, reason))
+		}
+	}
+	oneOf := func(value, name string, allowed ...string) bool {
+		for _, a := range allowed {
+			if value == a {
+				return true
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s must be one of %s, got %q", name, strings.Join(allowed, ", "), value))
+		return false
+	}
+
+	oneOf(c.Server.Env, "APP_ENV", "production", "development")
+	for _, proxy := range c.Server.Proxies() {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES has %q, which is not an IP or CIDR", proxy))
+			}
+		}
+	}
+	oneOf(strings.ToLower(c.Log.Level), "LOG_LEVEL", "debug", "info", "warn", "error")
+	oneOf(c.Log.Format, "LOG_FORMAT", "json", "text")
+	if c.Telemetry.Tracing_Enabled {
+		require(c.Telemetry.Tracing_Endpoint, "TRACING_ENDPOINT", "when tracing is enabled")
+	}
+	if oneOf(c.Mail.Provider, "MAIL_PROVIDER", "smtp", "log") {
+		switch {
+		case c.Mail.Provider == "smtp":
+			require(c.Mail.Username, "SMTP_USERNAME", "for the smtp mail provider")
+			require(c.Mail.Password, "SMTP_PASSWORD", "for the smtp mail provider")
+		case !c.Server.Dev():
+			errs = append(errs, errors.New("MAIL_PROVIDER=log is only allowed with APP_ENV=development"))
+		}
+	}
+	if oneOf(c.SMS.Provider, "SMS_PROVIDER", "", "termii", "twilio") {
+		switch c.SMS.Provider {
+		case "":
+			// Verification codes would only reach the log.
+			if !c.Server.Dev() {
+				errs = append(errs, errors.New("SMS_PROVIDER must be set unless APP_ENV=development"))
+			}
+		case "termii":
+			require(c.SMS.Termii_API_Key, "TERMII_API_KEY", "for the termii sms provider")
+			require(c.SMS.Termii_Sender_ID, "TERMII_SENDER_ID", "for the termii sms provider")
+		case "twilio":
+			require(c.SMS.Twilio_Account_SID, "TWILIO_ACCOUNT_SID", "for the twilio sms provider")
+			require(c.SMS.Twilio_Auth_Token, "TWILIO_AUTH_TOKEN", "for the twilio sms provider")
+			require(c.SMS.Twilio_From, "TWILIO_FROM", "for the twilio sms provider")
+		}
+	}
+	if oneOf(c.Identity.Provider, "IDENTITY_PROVIDER", "checkid", "fake") && c.Identity.Provider == "checkid" {
+		require(c.Identity.CheckID_Sec_Key, "CHECKID_SEC_KEY", "for the checkid identity provider")
+	}
+	if oneOf(c.Face_Match.Provider, "FACE_MATCH_PROVIDER", "stub", "http") {
+		switch {
+		case c.Face_Match.Provider == "http":
+			require(c.Face_Match.URL, "FACE_MATCH_URL", "for the http face match provider")
+		case !c.Server.Dev():
+			errs = append(errs, errors.New("FACE_MATCH_PROVIDER=stub is only allowed with APP_ENV=development"))
+		}
+	}
+	if oneOf(c.Storage.Backend, "BLOB_BACKEND", "gridfs", "gcs", "local") && c.Storage.Backend == "gcs" {
+		require(c.Storage.GCS_Bucket, "GCS_BUCKET", "for the gcs blob backend")
+	}
+	if oneOf(c.Storage.Public_Backend, "PUBLIC_BLOB_BACKEND", "gridfs", "gcs", "local") && c.Storage.Public_Backend == "gcs" {
+		require(c.Storage.GCS_Public_Bucket, "GCS_PUBLIC_BUCKET", "for the gcs public blob backend")
+		if c.Storage.GCS_Public_Bucket == c.Storage.GCS_Bucket && c.Storage.Backend == "gcs" {
+			errs = append(errs, errors.New("GCS_PUBLIC_BUCKET must differ from GCS_BUCKET so KYC files are never public"))
+		}
+	}
+	if c.Storage.Backend == "local" && c.Storage.Public_Backend == "local" && overlaps(c.Storage.Local_Dir, c.Storage.Public_Local_Dir) {
+		errs = append(errs, errors.New("PUBLIC_BLOB_LOCAL_DIR and BLOB_LOCAL_DIR must be apart, neither inside the other, so KYC files are never public"))
+	}
+	oneOf(c.Rate_Limit.Backend, "RATE_LIMIT_BACKEND", "memory", "mongo")
+	positive := func(d time.Duration, name string) {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	positive(c.Server.Read_Timeout, "SERVER_READ_TIMEOUT")
+	positive(c.Server.Read_Header_Timeout, "SERVER_READ_HEADER_TIMEOUT")
+	positive(c.Server.Write_Timeout, "SERVER_WRITE_TIMEOUT")
+	positive(c.Server.Idle_Timeout, "SERVER_IDLE_TIMEOUT")
+	positive(c.Server.Request_Timeout, "SERVER_REQUEST_TIMEOUT")
+	positive(c.Server.Shutdown_Timeout, "SERVER_SHUTDOWN_TIMEOUT")
+	positive(c.Providers.Timeout, "PROVIDER_TIMEOUT")
+	positive(c.Providers.Retry_Backoff, "PROVIDER_RETRY_BACKOFF")
+	positive(c.Providers.Retry_Max_Backoff, "PROVIDER_RETRY_MAX_BACKOFF")
+	positive(c.Providers.Breaker_Cooldown, "PROVIDER_BREAKER_COOLDOWN")
+	if c.Providers.Max_Retries < 0 {
+		errs = append(errs, errors.New("PROVIDER_MAX_RETRIES must not be negative"))
+	}
+	if c.Providers.Breaker_Threshold <= 0 {
+		errs = append(errs, errors.New("PROVIDER_BREAKER_THRESHOLD must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// overlaps reports whether one directory is the other or inside it. A path
+// that cannot be resolved counts as overlapping.
+func overlaps(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return true
+	}
+	return within(a, b) || within(b, a)
+}
+
+// within reports whether dir is parent or below it; both are absolute.
+func within(dir, parent string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateLocalBlobDirs(t *testing.T) {
+	tests := []struct {
+		name            string
+		private, public string
+		want            bool
+	}{
+		{name: "apart", private: "uploads", public: "uploads-public"},
+		{name: "same", private: "uploads", public: "uploads", want: true},
+		{name: "same once cleaned", private: "uploads", public: "./uploads/", want: true},
+		{name: "public inside", private: "uploads", public: "uploads/public", want: true},
+		{name: "private inside", private: "data/private", public: "data", want: true},
+		{name: "sibling with a shared prefix", private: "data", public: "data-public"},
+		{name: "dotted sibling", private: "data", public: "..data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Storage: StorageConfig{
+				Backend:          "local",
+				Local_Dir:        tt.private,
+				Public_Backend:   "local",
+				Public_Local_Dir: tt.public,
+			}}
+			err := cfg.Validate()
+			if got := err != nil && strings.Contains(err.Error(), "PUBLIC_BLOB_LOCAL_DIR"); got != tt.want {
+				t.Fatalf("Validate rejects %q and %q = %v, want %v", tt.private, tt.public, got, tt.want)
+			}
+		})
+	}
+}
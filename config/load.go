@@ -0,0 +1,212 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration. Values are taken, from lowest to highest
+// precedence, from the defaults, the YAML file named by CONFIG_FILE
+// (config.yaml when it exists), a .env file and the environment. The result
+// is validated before it is returned.
+func Load() (*Config, error) {
+	cfg := &Config{}
+	problems, err := load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.Join(problems...); err != nil {
+		return nil, errors.Join(err, cfg.Validate())
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadDatabase loads only the log and database settings, from the same
+// sources as Load, for tools like the migrator that should not need the
+// server's secrets.
+func LoadDatabase() (*Config, error) {
+	var sections struct {
+		Log      LogConfig      `yaml:"log"`
+		Database DatabaseConfig `yaml:"database"`
+	}
+	problems, err := load(&sections)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.Join(problems...); err != nil {
+		return nil, err
+	}
+	return &Config{Log: sections.Log, Database: sections.Database}, nil
+}
+
+// load fills target, a pointer to a struct of config sections, from the
+// defaults, the YAML file, .env and the environment. Problems with single
+// variables are all returned together; err is for anything that stops
+// loading altogether.
+func load(target any) (problems []error, err error) {
+	// godotenv never overrides variables that are already set, so the real
+	// environment wins over .env.
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf(".env: %w", err)
+	}
+
+	fields := leafFields(reflect.ValueOf(target).Elem())
+	for _, f := range fields {
+		if def, ok := f.tag.Lookup("default"); ok {
+			if err := setValue(f.value, def); err != nil {
+				return nil, fmt.Errorf("default %q: %w", def, err)
+			}
+		}
+	}
+
+	path, explicit := os.LookupEnv("CONFIG_FILE")
+	if !explicit {
+		path = "config.yaml"
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(data, target); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	} else if explicit || !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for _, f := range fields {
+		if name := f.tag.Get("env"); name != "" {
+			names[name] = true
+		}
+	}
+	for _, f := range fields {
+		if prefix := f.tag.Get("envPrefix"); prefix != "" {
+			setPrefixed(f.value, prefix, names)
+			continue
+		}
+		name := f.tag.Get("env")
+		if name == "" {
+			continue
+		}
+		value, ok, err := lookup(name)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if ok {
+			if err := setValue(f.value, value); err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+		}
+		if f.tag.Get("required") == "true" && f.value.Kind() == reflect.String && strings.TrimSpace(f.value.String()) == "" {
+			problems = append(problems, fmt.Errorf("%s is required", name))
+		}
+	}
+	return problems, nil
+}
+
+// MustLoad loads the configuration and exits the process with every
+// problem listed when it is invalid.
+func MustLoad() *Config {
+	cfg, err := Load()
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	return cfg
+}
+
+// lookup reads name from the environment, or from the file named by
+// <name>_FILE. Setting both is an error rather than a silent choice.
+func lookup(name string) (string, bool, error) {
+	value, ok := os.LookupEnv(name)
+	file, fromFile := os.LookupEnv(name + "_FILE")
+	switch {
+	case ok && fromFile:
+		return "", false, fmt.Errorf("only one of %s and %s_FILE may be set", name, name)
+	case fromFile:
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+	return value, ok, nil
+}
+
+// setPrefixed adds every variable starting with prefix to a map field,
+// keyed by the lower-cased rest of the name. Variables that belong to a
+// field of their own are skipped.
+func setPrefixed(field reflect.Value, prefix string, skip map[string]bool) {
+	if field.IsNil() {
+		field.Set(reflect.MakeMap(field.Type()))
+	}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(name, prefix) || skip[name] || strings.HasSuffix(name, "_FILE") {
+			continue
+		}
+		key := strings.ToLower(strings.TrimPrefix(name, prefix))
+		field.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue parses value into a string, duration, integer or boolean field.
+func setValue(field reflect.Value, value string) error {
+	switch {
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	default:
+		field.SetString(value)
+	}
+	return nil
+}
+
+type leafField struct {
+	value reflect.Value
+	tag   reflect.StructTag
+}
+
+// leafFields lists the settable fields of nested structs.
+func leafFields(v reflect.Value) []leafField {
+	var fields []leafField
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		info := v.Type().Field(i)
+		switch field.Kind() {
+		case reflect.Struct:
+			fields = append(fields, leafFields(field)...)
+		case reflect.String, reflect.Map, reflect.Bool, reflect.Int, reflect.Int64:
+			fields = append(fields, leafField{value: field, tag: info.Tag})
+		}
+	}
+	return fields
+}
@@ -2,11 +2,13 @@ package controllers
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/JayJosh846/donationPlatform/api"
 	"github.com/JayJosh846/donationPlatform/middleware"
 	"github.com/JayJosh846/donationPlatform/models"
 	"github.com/JayJosh846/donationPlatform/services"
@@ -20,6 +22,21 @@ type AdminController struct {
 	UserService        services.UserService
 	TransactionService services.TransactionService
 	DonationService    services.DonationService
+	SecurityService    services.SecurityService
+	KycService         services.KycService
+	LimitService       services.LimitService
+	AuditService       services.AuditService
+	WalletService      services.WalletService
+	PaymentService     services.PaymentService
+	TwoFactorService   services.TwoFactorService
+}
+
+type KycReviewRequest struct {
+	Reason string `json:"reason"`
+}
+
+type UnlockAccountRequest struct {
+	Email string `json:"email" validate:"required,email"`
 }
 
 type AdminTransactions struct {
@@ -40,174 +57,151 @@ func AdminConstructor(
 	userService services.UserService,
 	transactionService services.TransactionService,
 	donationService services.DonationService,
+	securityService services.SecurityService,
+	kycService services.KycService,
+	limitService services.LimitService,
+	auditService services.AuditService,
+	walletService services.WalletService,
+	paymentService services.PaymentService,
+	twoFactorService services.TwoFactorService,
 ) AdminController {
 	return AdminController{
 		UserService:        userService,
 		TransactionService: transactionService,
 		DonationService:    donationService,
+		SecurityService:    securityService,
+		KycService:         kycService,
+		LimitService:       limitService,
+		AuditService:       auditService,
+		WalletService:      walletService,
+		PaymentService:     paymentService,
+		TwoFactorService:   twoFactorService,
 	}
 }
 
 func (ac *AdminController) AdminLogin(ctx *gin.Context) {
-	var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-	var user models.User
-	// var founduser models.User
-	if err := ctx.BindJSON(&user); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+	var user LoginRequest
+	if err := ctx.ShouldBindJSON(&user); err != nil {
+		api.Fail(ctx, api.InvalidBody(err))
 		return
 	}
-	foundUser, err := ac.UserService.GetAdmin(user.Email)
-	defer cancel()
+	if user.Email == nil || user.Password == nil {
+		api.Fail(ctx, api.BadRequest("Email and password are required"))
+		return
+	}
+	if !checkLoginThrottle(ctx, ac.SecurityService, *user.Email) {
+		return
+	}
+	foundUser, err := ac.UserService.GetAdmin(ctx.Request.Context(), user.Email)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "Account not an admin or does not exist",
-			"data":          "",
-		})
+		recordLoginFailure(ctx, ac.SecurityService, *user.Email, "", "unknown admin account")
+		api.Fail(ctx, invalidCredentials())
 		return
 	}
-	PasswordIsValid, msg := generate.VerifyPassword(*user.Password, *foundUser.Password)
-	defer cancel()
-	if !PasswordIsValid {
-		ctx.JSON(http.StatusBadRequest, msg)
-		fmt.Println("msg", msg)
+	if !generate.VerifyPassword(*user.Password, *foundUser.Password) {
+		recordLoginFailure(ctx, ac.SecurityService, *user.Email, foundUser.User_ID, "invalid admin password")
+		api.Fail(ctx, invalidCredentials())
 		return
 	}
-	token, refreshToken, _ := generate.TokenGenerator(foundUser.User_ID, *foundUser.Email)
-	defer cancel()
-	generate.UpdateAllTokens(token, refreshToken, foundUser.User_ID)
-	ctx.JSON(http.StatusFound, gin.H{
-		"error":         false,
-		"response code": 302,
-		"message":       "Login successfully",
-		"data":          foundUser,
+	if user.Use_Email && user.Code == "" && foundUser.Two_Factor.Method == services.TwoFactorMethodTOTP {
+		if err := ac.TwoFactorService.SendEmailCode(ctx.Request.Context(), foundUser, services.TwoFactorPurposeLogin); err != nil {
+			slog.ErrorContext(ctx.Request.Context(), "sending two-factor code", "user_id", foundUser.User_ID, "err", err)
+		}
+	}
+	if !requireTwoFactor(ctx, ac.TwoFactorService, foundUser, services.TwoFactorPurposeLogin, user.Code) {
+		if user.Code != "" {
+			recordLoginFailure(ctx, ac.SecurityService, *user.Email, foundUser.User_ID, "invalid admin two-factor code")
+		} else {
+			releaseLoginAttempt(ctx, ac.SecurityService, *user.Email, foundUser.User_ID)
+		}
+		return
+	}
+	recordLoginSuccess(ctx, ac.SecurityService, *user.Email, foundUser.User_ID)
+	recordAudit(ctx, ac.AuditService, models.AuditEvent{
+		Action:     services.AuditAdminLogin,
+		Actor_ID:   foundUser.User_ID,
+		Actor_Role: services.AuditActorAdmin,
+		Outcome:    services.AuditSucceeded,
+		Detail:     map[string]interface{}{"two_factor": foundUser.Two_Factor.Enabled},
 	})
+	token, refreshToken, err := generate.TokenGenerator(foundUser.User_ID, *foundUser.Email)
+	if err != nil {
+		api.Fail(ctx, err)
+		return
+	}
+	if err := ac.UserService.UpdateTokens(ctx.Request.Context(), token, refreshToken, foundUser.User_ID); err != nil {
+		slog.ErrorContext(ctx.Request.Context(), "saving tokens", "user_id", foundUser.User_ID, "err", err)
+	}
+	api.OK(ctx, "Login successfully", foundUser)
 }
 
 func (ac *AdminController) Dashboard(c *gin.Context) {
-	var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-	_, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	if _, ok := ac.requireAdmin(c); !ok {
 		return
 	}
 
-	userCount, err := ac.UserService.GetUserCount()
-	defer cancel()
+	userCount, err := ac.UserService.GetUserCount(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "Something went wrong while retrieving user count",
-			"data":          "",
-		})
+		api.Fail(c, api.Internal(err))
+		return
 	}
 
-	successfulTransactionCount, err := ac.TransactionService.GetSuccessfulTransactionCount()
-	defer cancel()
+	successfulTransactionCount, err := ac.TransactionService.GetSuccessfulTransactionCount(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "Something went wrong while retrieving successful transaction count",
-			"data":          "",
-		})
+		api.Fail(c, api.Internal(err))
 		return
 	}
 
-	failureTransactionCount, err := ac.TransactionService.GetFailureTransactionCount()
-	defer cancel()
+	failureTransactionCount, err := ac.TransactionService.GetFailureTransactionCount(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "Something went wrong while retrieving failure transaction count",
-			"data":          "",
-		})
+		api.Fail(c, api.Internal(err))
 		return
 	}
 
-	transactionCount, err := ac.TransactionService.GetTransactionCount()
-	defer cancel()
+	transactionCount, err := ac.TransactionService.GetTransactionCount(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "Something went wrong while retrieving transaction count",
-			"data":          "",
-		})
-		return
-	}
-
-	c.JSON(http.StatusFound, gin.H{
-		"error":         false,
-		"response code": 302,
-		"message":       "Data retrieved successfully",
-		"data": gin.H{
-			"userCount":                  userCount,
-			"transactionCount":           transactionCount,
-			"successfulTransactionCount": successfulTransactionCount,
-			"failureTransactionCount":    failureTransactionCount,
-		},
+		api.Fail(c, api.Internal(err))
+		return
+	}
+
+	api.OK(c, "Data retrieved successfully", gin.H{
+		"userCount":                  userCount,
+		"transactionCount":           transactionCount,
+		"successfulTransactionCount": successfulTransactionCount,
+		"failureTransactionCount":    failureTransactionCount,
 	})
 }
 
 func (ac *AdminController) GetAllUsersCount(c *gin.Context) {
-	var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-	_, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	if _, ok := ac.requireAdmin(c); !ok {
 		return
 	}
-	userCount, err := ac.UserService.GetUserCount()
-	defer cancel()
+	userCount, err := ac.UserService.GetUserCount(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "Something went wrong while retrieving user count",
-			"data":          "",
-		})
-		return
-	}
-	c.JSON(http.StatusFound, gin.H{
-		"error":         false,
-		"response code": 302,
-		"message":       "User count retrieved successfully",
-		"data":          userCount,
-	})
+		api.Fail(c, api.Internal(err))
+		return
+	}
+	api.OK(c, "User count retrieved successfully", userCount)
 
 }
 
 func (ac *AdminController) GetAllTransactions(c *gin.Context) {
-	var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-	_, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	admin, ok := ac.requireAdmin(c)
+	if !ok {
 		return
 	}
 	var adminTransactions []AdminTransactions
-	transactions, err := ac.TransactionService.GetTransactions()
-	defer cancel()
+	transactions, err := ac.TransactionService.GetTransactions(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "Something went wrong while retrieving transactions",
-			"data":          "",
-		})
+		api.Fail(c, api.Internal(err))
 		return
 	}
+	ac.auditView(c, admin, "", "transactions")
 	for _, transaction := range transactions {
 		// Fetch user data based on User_ID
-		user, err := ac.UserService.GetUserByID(transaction.User_ID)
+		user, err := ac.UserService.GetUserByID(c.Request.Context(), transaction.User_ID)
 		if err != nil {
-			log.Printf("Error fetching user data for User_ID %s: %v", transaction.User_ID, err)
+			slog.ErrorContext(c.Request.Context(), "fetching transaction user", "user_id", transaction.User_ID, "err", err)
 			continue // Skip to the next iteration if an error occurs
 		}
 
@@ -230,111 +224,371 @@ func (ac *AdminController) GetAllTransactions(c *gin.Context) {
 	}
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "User does not exist",
-			"data":          "",
-		})
-		return
-	}
-	c.JSON(http.StatusFound, gin.H{
-		"error":         false,
-		"response code": 302,
-		"message":       "Transactions retrieved successfully",
-		"data":          adminTransactions,
-	})
+		api.Fail(c, api.NotFoundOr(err, "User does not exist"))
+		return
+	}
+	api.OK(c, "Transactions retrieved successfully", adminTransactions)
 
 }
 
 func (ac *AdminController) GetTransactionsById(c *gin.Context) {
-	var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-	_, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	admin, ok := ac.requireAdmin(c)
+	if !ok {
 		return
 	}
 	id := c.Query("id")
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "An error occured"})
+		api.Fail(c, api.BadRequest("Invalid transaction id"))
 		return
 	}
-	transaction, err := ac.TransactionService.GetTransactionByID(objectID)
-	defer cancel()
+	transaction, err := ac.TransactionService.GetTransactionByID(c.Request.Context(), objectID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "Something went wrong while retrieving transaction",
-			"data":          "",
-		})
-		return
-	}
-	c.JSON(http.StatusFound, gin.H{
-		"error":         false,
-		"response code": 302,
-		"message":       "Transactions retrieved successfully",
-		"data":          transaction,
-	})
+		api.Fail(c, api.Internal(err))
+		return
+	}
+	ac.auditView(c, admin, transaction.User_ID, "transaction:"+id)
+	api.OK(c, "Transactions retrieved successfully", transaction)
 
 }
 
 func (ac *AdminController) GetSuccessTransactionsCount(c *gin.Context) {
-	var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-	_, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	if _, ok := ac.requireAdmin(c); !ok {
 		return
 	}
-	successfulTransactionCount, err := ac.TransactionService.GetSuccessfulTransactionCount()
-	defer cancel()
+	successfulTransactionCount, err := ac.TransactionService.GetSuccessfulTransactionCount(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "Something went wrong while retrieving successful transaction count",
-			"data":          "",
-		})
-		return
-	}
-	c.JSON(http.StatusFound, gin.H{
-		"error":         false,
-		"response code": 302,
-		"message":       "Successful transaction count retrieved successfully",
-		"data":          successfulTransactionCount,
-	})
+		api.Fail(c, api.Internal(err))
+		return
+	}
+	api.OK(c, "Successful transaction count retrieved successfully", successfulTransactionCount)
 
 }
 
 func (ac *AdminController) GetFailureTransactionsCount(c *gin.Context) {
-	var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-	_, exists := c.Get("user")
+	if _, ok := ac.requireAdmin(c); !ok {
+		return
+	}
+	failureTransactionCount, err := ac.TransactionService.GetFailureTransactionCount(c.Request.Context())
+	if err != nil {
+		api.Fail(c, api.Internal(err))
+		return
+	}
+	api.OK(c, "Failure transaction count retrieved successfully", failureTransactionCount)
+
+}
+
+// requireAdmin checks that the authenticated caller holds the admin role.
+func (ac *AdminController) requireAdmin(c *gin.Context) (*models.User, bool) {
+	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		api.Fail(c, api.Unauthorized("User not found"))
+		return nil, false
+	}
+	userStruct, ok := user.(middleware.User)
+	if !ok {
+		api.Fail(c, api.Unauthorized("User not a valid struct"))
+		return nil, false
+	}
+	admin, err := ac.UserService.GetAdmin(c.Request.Context(), userStruct.Email)
+	if err != nil {
+		api.Fail(c, api.Forbidden("Admin access required"))
+		return nil, false
+	}
+	return admin, true
+}
+
+// auditView records an admin reading other users' data.
+func (ac *AdminController) auditView(c *gin.Context, admin *models.User, subjectID, resource string) {
+	recordAudit(c, ac.AuditService, models.AuditEvent{
+		Action:     services.AuditAdminView,
+		Actor_ID:   admin.User_ID,
+		Actor_Role: services.AuditActorAdmin,
+		Subject_ID: subjectID,
+		Resource:   resource,
+		Outcome:    services.AuditSucceeded,
+	})
+}
+
+func (ac *AdminController) UnlockAccount(c *gin.Context) {
+	admin, ok := ac.requireAdmin(c)
+	if !ok {
 		return
 	}
-	failureTransactionCount, err := ac.TransactionService.GetFailureTransactionCount()
-	defer cancel()
+	var request UnlockAccountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		api.Fail(c, api.InvalidBody(err))
+		return
+	}
+	if validationErr := Validate.Struct(request); validationErr != nil {
+		api.Fail(c, api.Validation(validationErr))
+		return
+	}
+	err := ac.SecurityService.UnlockAccount(c.Request.Context(), request.Email)
+	recordAudit(c, ac.AuditService, models.AuditEvent{
+		Action:     services.AuditAccountUnlocked,
+		Actor_ID:   admin.User_ID,
+		Actor_Role: services.AuditActorAdmin,
+		Resource:   request.Email,
+		Outcome:    services.AuditOutcome(err),
+	})
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	ac.SecurityService.LogSecurityEvent(c.Request.Context(), &models.SecurityEvent{
+		Type:   services.SecurityEventAccountUnlocked,
+		Email:  request.Email,
+		IP:     c.ClientIP(),
+		Detail: "unlocked by admin " + admin.User_ID,
+	})
+	api.OK(c, "Account unlocked successfully", "")
+}
+
+func (ac *AdminController) GetSecurityEvents(c *gin.Context) {
+	admin, ok := ac.requireAdmin(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	events, err := ac.SecurityService.GetSecurityEvents(c.Request.Context(), c.Query("user_id"), limit)
+	if err != nil {
+		api.Fail(c, api.Internal(err))
+		return
+	}
+	ac.auditView(c, admin, c.Query("user_id"), "security_events")
+	api.OK(c, "Security events retrieved successfully", events)
+}
+
+func (ac *AdminController) GetAuditEvents(c *gin.Context) {
+	admin, ok := ac.requireAdmin(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	events, err := ac.AuditService.GetAuditEvents(c.Request.Context(), c.Query("user_id"), c.Query("action"), limit)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "Something went wrong while retrieving failure transaction count",
-			"data":          "",
-		})
-		return
-	}
-	c.JSON(http.StatusFound, gin.H{
-		"error":         false,
-		"response code": 302,
-		"message":       "Failure transaction count retrieved successfully",
-		"data":          failureTransactionCount,
+		api.Fail(c, api.Internal(err))
+		return
+	}
+	ac.auditView(c, admin, c.Query("user_id"), "audit_events")
+	api.OK(c, "Audit events retrieved successfully", events)
+}
+
+func (ac *AdminController) GetKycQueue(c *gin.Context) {
+	admin, ok := ac.requireAdmin(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	skip, err := strconv.ParseInt(c.DefaultQuery("skip", "0"), 10, 64)
+	if err != nil || skip < 0 {
+		skip = 0
+	}
+	queue, err := ac.KycService.GetReviewQueue(c.Request.Context(), limit, skip)
+	if err != nil {
+		api.Fail(c, api.Internal(err))
+		return
+	}
+	ac.auditView(c, admin, "", "kyc_queue")
+	api.OK(c, "KYC queue retrieved successfully", queue)
+}
+
+func (ac *AdminController) GetKycSubmission(c *gin.Context) {
+	admin, ok := ac.requireAdmin(c)
+	if !ok {
+		return
+	}
+	userID := c.Param("user_id")
+	kyc, err := ac.KycService.GetKycByUserID(c.Request.Context(), userID)
+	if err != nil {
+		api.Fail(c, api.NotFoundOr(err, "KYC submission not found"))
+		return
+	}
+	user, err := ac.UserService.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		api.Fail(c, api.NotFoundOr(err, "User does not exist"))
+		return
+	}
+	ac.auditView(c, admin, userID, "kyc")
+	api.OK(c, "KYC submission retrieved successfully", gin.H{
+		"kyc": kyc,
+		"user": gin.H{
+			"user_id":        user.User_ID,
+			"full_name":      user.Fullname,
+			"email":          user.Email,
+			"phone":          user.Phone,
+			"dob":            user.DOB,
+			"phone_verified": user.Phone_Verified,
+			"email_verified": user.Email_Verified,
+			"bvn_verified":   user.Bvn_Verified,
+		},
 	})
+}
 
+// GetKycDocument streams the uploaded ID document so a reviewer can view it.
+func (ac *AdminController) GetKycDocument(c *gin.Context) {
+	admin, ok := ac.requireAdmin(c)
+	if !ok {
+		return
+	}
+	kyc, err := ac.KycService.GetKycByUserID(c.Request.Context(), c.Param("user_id"))
+	if err != nil || kyc.Kyc_Docs == nil {
+		api.Fail(c, api.NotFound("KYC document not found"))
+		return
+	}
+	stream, err := ac.KycService.OpenDocument(c.Request.Context(), *kyc.Kyc_Docs)
+	if err != nil {
+		api.Fail(c, api.NotFoundOr(err, "KYC document not found"))
+		return
+	}
+	defer stream.Close()
+	ac.auditView(c, admin, kyc.User_ID, "kyc_document")
+	c.Header("Cache-Control", "no-store")
+	c.DataFromReader(http.StatusOK, -1, "application/octet-stream", stream, map[string]string{
+		"Content-Disposition": "inline",
+	})
+}
+
+func (ac *AdminController) ApproveKyc(c *gin.Context) {
+	ac.reviewKyc(c, services.KycActionApproved)
+}
+
+func (ac *AdminController) RejectKyc(c *gin.Context) {
+	ac.reviewKyc(c, services.KycActionRejected)
+}
+
+func (ac *AdminController) RequestKycResubmission(c *gin.Context) {
+	ac.reviewKyc(c, services.KycActionResubmissionRequested)
+}
+
+func (ac *AdminController) reviewKyc(c *gin.Context, action string) {
+	admin, ok := ac.requireAdmin(c)
+	if !ok {
+		return
+	}
+	var request KycReviewRequest
+	if action != services.KycActionApproved {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			api.Fail(c, api.InvalidBody(err))
+			return
+		}
+	}
+	userID := c.Param("user_id")
+	var (
+		kyc *models.KYC
+		err error
+	)
+	switch action {
+	case services.KycActionApproved:
+		kyc, err = ac.KycService.Approve(c.Request.Context(), userID, admin.User_ID)
+	case services.KycActionRejected:
+		kyc, err = ac.KycService.Reject(c.Request.Context(), userID, admin.User_ID, request.Reason)
+	default:
+		kyc, err = ac.KycService.RequestResubmission(c.Request.Context(), userID, admin.User_ID, request.Reason)
+	}
+	recordAudit(c, ac.AuditService, models.AuditEvent{
+		Action:     services.AuditKycReviewed,
+		Actor_ID:   admin.User_ID,
+		Actor_Role: services.AuditActorAdmin,
+		Subject_ID: userID,
+		Outcome:    services.AuditOutcome(err),
+		Detail:     map[string]interface{}{"decision": action},
+	})
+	if err != nil {
+		api.Fail(c, api.NotFoundOr(err, "KYC submission not found"))
+		return
+	}
+	api.OK(c, "KYC review recorded successfully", kyc)
+}
+
+// ReleaseHeldTransaction credits a donation that was held because it
+// breached the recipient's limits.
+func (ac *AdminController) ReleaseHeldTransaction(c *gin.Context) {
+	admin, ok := ac.requireAdmin(c)
+	if !ok {
+		return
+	}
+	reference := c.Param("reference")
+	transaction, balance, err := ac.WalletService.ReleaseHeld(c.Request.Context(), reference)
+	if errors.Is(err, services.ErrNoHeldTransaction) {
+		api.Fail(c, err)
+		return
+	}
+	event := models.AuditEvent{
+		Action:     services.AuditTransactionReleased,
+		Actor_ID:   admin.User_ID,
+		Actor_Role: services.AuditActorAdmin,
+		Resource:   reference,
+		Outcome:    services.AuditOutcome(err),
+	}
+	if transaction != nil {
+		event.Subject_ID = transaction.User_ID
+		event.Detail = map[string]interface{}{"amount": transaction.Amount, "balance": balance}
+	}
+	recordAudit(c, ac.AuditService, event)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	api.OK(c, "Transaction released successfully", "")
+}
+
+// RefundHeldTransaction returns a held donation to the donor instead of
+// crediting it. The transaction leaves the hold before Paystack is asked,
+// so it cannot be released meanwhile. It goes back on hold only if Paystack
+// answered and refused the refund. When the outcome is unknown, as on a
+// timeout or outage, it stays refunded for reconciliation with Paystack to
+// settle, since releasing a donation Paystack did refund would pay it twice.
+func (ac *AdminController) RefundHeldTransaction(c *gin.Context) {
+	admin, ok := ac.requireAdmin(c)
+	if !ok {
+		return
+	}
+	reference := c.Param("reference")
+	transaction, err := ac.TransactionService.RefundHeldTransaction(c.Request.Context(), &reference)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	// The refund is settled on a deadline of its own once Paystack has
+	// been asked, even if the client hangs up.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), webhookTimeout)
+	defer cancel()
+	_, err = ac.PaymentService.RefundCharge(ctx, reference)
+	if err != nil && transferNotSent(err) {
+		if restoreErr := ac.TransactionService.RestoreHeldTransaction(ctx, &reference, transaction.Hold_Reason); restoreErr != nil {
+			slog.ErrorContext(ctx, "restoring held transaction", "reference", reference, "err", restoreErr)
+		}
+	}
+	recordAudit(c, ac.AuditService, models.AuditEvent{
+		Action:     services.AuditTransactionRefunded,
+		Actor_ID:   admin.User_ID,
+		Actor_Role: services.AuditActorAdmin,
+		Subject_ID: transaction.User_ID,
+		Resource:   reference,
+		Outcome:    services.AuditOutcome(err),
+		Detail:     map[string]interface{}{"amount": transaction.Amount, "reason": transaction.Hold_Reason},
+	})
+	if err != nil {
+		if !transferNotSent(err) {
+			slog.WarnContext(ctx, "refund outcome unknown", "reference", reference, "err", err)
+			api.Accepted(c, "Refund is being processed", transaction)
+			return
+		}
+		api.Fail(c, err)
+		return
+	}
+	api.OK(c, "Transaction refunded to the donor", transaction)
 }
 
 func (ac *AdminController) AdminRoute(rg *gin.RouterGroup) {
@@ -367,5 +621,49 @@ func (ac *AdminController) AdminRoute(rg *gin.RouterGroup) {
 		middleware.Authentication,
 		ac.GetFailureTransactionsCount,
 	)
+	adminRoute.POST("/transactions/:reference/release",
+		middleware.Authentication,
+		ac.ReleaseHeldTransaction,
+	)
+	adminRoute.POST("/transactions/:reference/refund",
+		middleware.Authentication,
+		ac.RefundHeldTransaction,
+	)
+	adminRoute.POST("/unlock-account",
+		middleware.Authentication,
+		ac.UnlockAccount,
+	)
+	adminRoute.GET("/security-events",
+		middleware.Authentication,
+		ac.GetSecurityEvents,
+	)
+	adminRoute.GET("/audit-events",
+		middleware.Authentication,
+		ac.GetAuditEvents,
+	)
+	adminRoute.GET("/kyc/queue",
+		middleware.Authentication,
+		ac.GetKycQueue,
+	)
+	adminRoute.GET("/kyc/:user_id",
+		middleware.Authentication,
+		ac.GetKycSubmission,
+	)
+	adminRoute.GET("/kyc/:user_id/document",
+		middleware.Authentication,
+		ac.GetKycDocument,
+	)
+	adminRoute.POST("/kyc/:user_id/approve",
+		middleware.Authentication,
+		ac.ApproveKyc,
+	)
+	adminRoute.POST("/kyc/:user_id/reject",
+		middleware.Authentication,
+		ac.RejectKyc,
+	)
+	adminRoute.POST("/kyc/:user_id/request-resubmission",
+		middleware.Authentication,
+		ac.RequestKycResubmission,
+	)
 	// }
 }
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"log/slog"
+
+	"github.com/JayJosh846/donationPlatform/logging"
+	"github.com/JayJosh846/donationPlatform/middleware"
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/services"
+	"github.com/gin-gonic/gin"
+)
+
+// recordAudit adds event to the audit log, filling in the request details
+// and, unless set, the authenticated caller as actor. The action has
+// already happened by the time it is recorded, so a failed write is
+// logged rather than failing the request.
+func recordAudit(c *gin.Context, as services.AuditService, event models.AuditEvent) {
+	if as == nil {
+		return
+	}
+	if event.Actor_ID == "" {
+		if user, ok := c.Get("user"); ok {
+			if u, ok := user.(middleware.User); ok && u.Id != nil {
+				event.Actor_ID = *u.Id
+			}
+		}
+	}
+	if event.Actor_Role == "" {
+		event.Actor_Role = services.AuditActorUser
+	}
+	event.IP = c.ClientIP()
+	event.Request_ID = logging.RequestID(c.Request.Context())
+	if err := as.Record(c.Request.Context(), &event); err != nil {
+		slog.ErrorContext(c.Request.Context(), "recording audit event", "action", event.Action, "actor_id", event.Actor_ID, "err", err)
+	}
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/api"
+	"github.com/JayJosh846/donationPlatform/middleware"
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/services"
+	helper "github.com/JayJosh846/donationPlatform/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// kycFileURLTTL is how long a link to a private KYC file stays valid.
+const kycFileURLTTL = 5 * time.Minute
+
+type FileController struct {
+	UserService services.UserService
+	KycService  services.KycService
+	BlobStore   services.BlobStore
+	PublicStore *services.PublicBlobStore
+}
+
+func FileConstructor(
+	userService services.UserService,
+	kycService services.KycService,
+	blobStore services.BlobStore,
+	publicStore *services.PublicBlobStore,
+) FileController {
+	return FileController{
+		UserService: userService,
+		KycService:  kycService,
+		BlobStore:   blobStore,
+		PublicStore: publicStore,
+	}
+}
+
+// canAccessKyc reports whether caller may see the KYC files of userID:
+// only the owner and admins can.
+func canAccessKyc(caller *models.User, userID string) bool {
+	return caller.User_ID == userID || caller.Role == "admin"
+}
+
+// PublicFile serves files from the public store, such as profile pictures,
+// when the public store is not a public bucket of its own.
+func (fc *FileController) PublicFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	file, err := fc.PublicStore.Get(c.Request.Context(), key)
+	if err != nil {
+		api.Fail(c, api.NotFound("File not found"))
+		return
+	}
+	defer file.Close()
+	c.DataFromReader(http.StatusOK, -1, contentTypeFor(key), file, map[string]string{
+		"Cache-Control":          "public, max-age=86400",
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// KycFileURL mints a short-lived link to the caller's own KYC selfie or
+// document; admins may ask for any user's.
+func (fc *FileController) KycFileURL(c *gin.Context) {
+	caller, ok := authenticatedUser(c, fc.UserService)
+	if !ok {
+		return
+	}
+	userID := c.DefaultQuery("user_id", caller.User_ID)
+	if !canAccessKyc(caller, userID) {
+		api.Fail(c, api.Forbidden("You can only view your own KYC files"))
+		return
+	}
+	kyc, err := fc.KycService.GetKycByUserID(c.Request.Context(), userID)
+	var key *string
+	if err == nil {
+		switch c.Query("file") {
+		case "selfie":
+			key = kyc.Kyc_Image
+		case "document":
+			key = kyc.Kyc_Docs
+		default:
+			api.Fail(c, api.BadRequest("file must be selfie or document"))
+			return
+		}
+	}
+	if key == nil {
+		api.Fail(c, api.NotFound("File not found"))
+		return
+	}
+	expires := time.Now().Add(kycFileURLTTL)
+	query := url.Values{}
+	query.Set("key", *key)
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("sig", helper.SignFileURL(*key, expires.Unix()))
+	api.OK(c, "File link generated successfully", gin.H{
+		"url":        "/api/v1/kyc/files?" + query.Encode(),
+		"expires_at": expires,
+	})
+}
+
+// KycFile streams a private KYC file to the holder of a link from
+// KycFileURL.
+func (fc *FileController) KycFile(c *gin.Context) {
+	key := c.Query("key")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !helper.VerifyFileURL(key, expires, c.Query("sig")) {
+		api.Fail(c, api.Forbidden("This link is invalid or has expired"))
+		return
+	}
+	file, err := fc.BlobStore.Get(c.Request.Context(), key)
+	if err != nil {
+		if !errors.Is(err, services.ErrBlobNotFound) {
+			slog.ErrorContext(c.Request.Context(), "opening KYC file", "err", err)
+		}
+		api.Fail(c, api.NotFound("File not found"))
+		return
+	}
+	defer file.Close()
+	c.DataFromReader(http.StatusOK, -1, contentTypeFor(key), file, map[string]string{
+		"Cache-Control":          "private, no-store",
+		"Content-Disposition":    "inline",
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func contentTypeFor(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+func (fc *FileController) FileRoute(rg *gin.RouterGroup) {
+	rg.GET("/public/*key", fc.PublicFile)
+	rg.GET("/kyc/files/url",
+		middleware.Authentication,
+		fc.KycFileURL,
+	)
+	rg.GET("/kyc/files", fc.KycFile)
+}
@@ -3,38 +3,37 @@ package controllers
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log/slog"
 	"strconv"
 	"time"
 
 	// "io"
-	"log"
 	"net/http"
 
+	"github.com/JayJosh846/donationPlatform/api"
 	"github.com/JayJosh846/donationPlatform/middleware"
 	"github.com/JayJosh846/donationPlatform/models"
 	"github.com/JayJosh846/donationPlatform/services"
+	"github.com/JayJosh846/donationPlatform/telemetry"
 	helper "github.com/JayJosh846/donationPlatform/utils"
 	"github.com/go-playground/validator/v10"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/gin-gonic/gin"
 )
 
-type PaymentError struct {
-	Error        bool
-	ResponseCode int
-	Message      string
-	Data         string
-}
-
 type PaymentController struct {
 	PaymentService     services.PaymentService
 	UserService        services.UserService
 	TransactionService services.TransactionService
 	DonationService    services.DonationService
 	BankService        services.BankService
+	TwoFactorService   services.TwoFactorService
+	LimitService       services.LimitService
+	AuditService       services.AuditService
+	WalletService      services.WalletService
 }
 
 func PaymentConstructor(
@@ -43,7 +42,10 @@ func PaymentConstructor(
 	transactionService services.TransactionService,
 	donationService services.DonationService,
 	bankService services.BankService,
-
+	twoFactorService services.TwoFactorService,
+	limitService services.LimitService,
+	auditService services.AuditService,
+	walletService services.WalletService,
 ) PaymentController {
 	return PaymentController{
 		PaymentService:     paymentService,
@@ -51,6 +53,10 @@ func PaymentConstructor(
 		TransactionService: transactionService,
 		DonationService:    donationService,
 		BankService:        bankService,
+		TwoFactorService:   twoFactorService,
+		LimitService:       limitService,
+		AuditService:       auditService,
+		WalletService:      walletService,
 	}
 }
 
@@ -60,89 +66,43 @@ type PaymentRequest struct {
 	Amount      string `json:"amount" validate:"required"`
 }
 
-type PaymentResponse struct {
-	Status  bool                `json:"status"`
-	Message string              `json:"message"`
-	Data    PaymentResponseData `json:"data"`
-}
-
-type PaymentResponseData struct {
-	AccessCode       string `json:"access_code"`
-	Reference        string `json:"reference"`
-	AuthorizationURL string `json:"authorization_url"`
-}
-
-type BankResponse struct {
-	Status  bool               `json:"status"`
-	Message string             `json:"message"`
-	Data    []BankResponseData `json:"data"`
-}
-
-type BankResponseData struct {
-	Name string `json:"name"`
-	Slug string `json:"slug"`
-	Code string `json:"code"`
-}
-
 type PayoutRequest struct {
-	Amount int `json:"amount" validate:"required"`
-}
-
-type TransferRecipientResponse struct {
-	Status  bool                          `json:"status"`
-	Message string                        `json:"message"`
-	Data    TransferRecipientResponseData `json:"data"`
-}
-
-type TransferRecipientResponseData struct {
-	Active         bool   `json:"active"`
-	Recipient_code string `json:"recipient_code"`
-}
-type Transfer struct {
-	Status bool `json:"status"`
+	Amount int    `json:"amount" validate:"required,min=1"`
+	Code   string `json:"code"`
 }
 
 var ValidatePaymentBody = validator.New()
 
 func (pc *PaymentController) Payin(c *gin.Context) {
-	var ct, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
 
 	var (
-		paymentRequest  PaymentRequest
-		paymentResponse PaymentResponse
-		createTrans     models.Transaction
-		createDonor     models.User
+		paymentRequest PaymentRequest
+		createTrans    models.Transaction
+		createDonor    models.User
 	)
 	if err := c.ShouldBindJSON(&paymentRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		api.Fail(c, api.InvalidBody(err))
 		return
 	}
 	validationErr := ValidatePaymentBody.Struct(paymentRequest)
 	if validationErr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 500,
-			"message":       "Enter the required fields",
-			"data":          "",
-		})
+		api.Fail(c, api.Validation(validationErr))
 		return
 	}
-	foundUser, err := pc.PaymentService.PaymentGetUser(&paymentRequest.Email)
+	foundUser, err := pc.PaymentService.PaymentGetUser(c.Request.Context(), &paymentRequest.Email)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":         true,
-			"response code": 404,
-			"message":       "Failed to retrieve user details",
-			"data":          "",
-		})
+		api.Fail(c, api.NotFoundOr(err, "Failed to retrieve user details"))
 		return
 	}
 	amount := paymentRequest.Amount
 	// Parse the string to an integer
 	amountInt, err := strconv.Atoi(amount)
 	if err != nil {
-		fmt.Println("Error:", err)
+		api.Fail(c, api.BadRequest("Amount must be a whole number"))
+		return
+	}
+	if !checkLimit(c, pc.LimitService.CheckInflow(c.Request.Context(), foundUser, amountInt)) {
+		telemetry.Payins.WithLabelValues(telemetry.OutcomeRejected).Inc()
 		return
 	}
 	// Multiply by 100
@@ -150,13 +110,10 @@ func (pc *PaymentController) Payin(c *gin.Context) {
 	// Convert the integer back to a string
 	newAmountStr := strconv.Itoa(newAmountInt)
 
-	payIn, err := pc.PaymentService.Payin(newAmountStr, *foundUser)
+	paymentResponse, err := pc.PaymentService.Payin(c.Request.Context(), newAmountStr, *foundUser)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-	}
-	e := json.Unmarshal([]byte(payIn), &paymentResponse)
-	if e != nil {
-		log.Println("Error:", e)
+		telemetry.Payins.WithLabelValues(telemetry.OutcomeFailed).Inc()
+		api.Fail(c, err)
 		return
 	}
 	createTrans.ID = primitive.NewObjectID()
@@ -166,255 +123,386 @@ func (pc *PaymentController) Payin(c *gin.Context) {
 	createTrans.User_Full_name = foundUser.Fullname
 	createTrans.Amount = paymentRequest.Amount
 	createTrans.Status = "pending"
-	createErr := pc.TransactionService.CreateTransaction(&createTrans)
+	createErr := pc.TransactionService.CreateTransaction(c.Request.Context(), &createTrans)
 	if createErr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       createErr.Error(),
-			"data":          "",
-		})
+		telemetry.Payins.WithLabelValues(telemetry.OutcomeFailed).Inc()
+		api.Fail(c, createErr)
 		return
 	}
-	count, err := UserCollection.CountDocuments(ct, bson.M{"email": paymentRequest.Donor_Email})
+	telemetry.Payins.WithLabelValues(telemetry.OutcomeSucceeded).Inc()
+	exists, err := pc.UserService.EmailExists(c.Request.Context(), paymentRequest.Donor_Email)
 	if err != nil {
-		log.Panic(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		api.Fail(c, err)
 		return
 	}
-	if count > 0 {
-		c.JSON(http.StatusFound, gin.H{
-			"error":         false,
-			"response code": 200,
-			"message":       "Payment link generated successfully",
-			"data":          paymentResponse,
-		})
+	if exists {
+		api.OK(c, "Payment link generated successfully", paymentResponse)
 		return
 	}
 
+	createDonor.ID = primitive.NewObjectID()
+	createDonor.User_ID = createDonor.ID.Hex()
 	userName, err := helper.ExtractUsernameFromEmail(paymentRequest.Donor_Email)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		api.Fail(c, api.BadRequest("Invalid email format"))
+		return
+	}
+	userName, err = pc.UserService.AvailableUsername(c.Request.Context(), userName, createDonor.User_ID)
+	if err != nil {
+		api.Fail(c, err)
 		return
 	}
 	password, ranPassErr := helper.GenerateRandomPassword(12)
 	if ranPassErr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": ranPassErr})
+		api.Fail(c, ranPassErr)
+		return
+	}
+	hashedPassword, err := helper.HashPassword(password)
+	if err != nil {
+		api.Fail(c, err)
 		return
 	}
-	hashedPassword := helper.HashPassword(password)
 
 	createDonor.Password = &hashedPassword
-	createDonor.ID = primitive.NewObjectID()
-	createDonor.User_ID = createDonor.ID.Hex()
 	createDonor.Email = &paymentRequest.Donor_Email
 	createDonor.Username = &userName
 	createDonor.Role = "donor"
 	createDonor.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	createDonor.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	token, refreshtoken, _ := helper.TokenGenerator(createDonor.User_ID, paymentRequest.Email)
+	token, refreshtoken, err := helper.TokenGenerator(createDonor.User_ID, paymentRequest.Email)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
 	createDonor.Token = &token
 	createDonor.Refresh_Token = &refreshtoken
 
-	createDonorErr := pc.UserService.CreateUser(&createDonor)
-	if createDonorErr != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"message": createErr.Error()})
+	// A concurrent payin from the same donor may have created the account
+	// first, which is as good.
+	createDonorErr := pc.UserService.CreateUser(c.Request.Context(), &createDonor)
+	if createDonorErr != nil && !errors.Is(createDonorErr, services.ErrEmailTaken) {
+		api.Fail(c, createDonorErr)
 		return
 	}
 
-	c.JSON(http.StatusFound, gin.H{
-		"error":         false,
-		"response code": 200,
-		"message":       "Payment link generated successfully",
-		"data":          paymentResponse,
-	})
+	api.OK(c, "Payment link generated successfully", paymentResponse)
 
 }
 
+// webhookTimeout bounds the work done for one Paystack event.
+const webhookTimeout = 30 * time.Second
+
 func (pc *PaymentController) ConfirmWebhook(c *gin.Context) {
+	// Once a credit starts it has to finish even if Paystack hangs up, so
+	// it runs on its own deadline rather than the request's.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), webhookTimeout)
+	defer cancel()
 	var eventData map[string]interface{}
 	if err := c.ShouldBindJSON(&eventData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeInvalidPayload).Inc()
+		api.Fail(c, api.InvalidBody(err))
 		return
 	}
 	jsonData, err := json.Marshal(eventData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		api.Fail(c, err)
 		return
 	}
-	verifyRes, verifyErr := pc.PaymentService.VerifyDeposit(jsonData)
+	verifyRes, verifyErr := pc.PaymentService.VerifyDeposit(ctx, jsonData)
 	if verifyErr != nil {
-		fmt.Println("verifyErr", verifyErr)
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeInvalidPayload).Inc()
+		slog.ErrorContext(ctx, "decoding paystack webhook", "err", verifyErr)
+		return
+	}
+	reference := verifyRes.Data.Reference
+	switch verifyRes.Event {
+	case "charge.success":
+		pc.creditDeposit(ctx, c, reference, verifyRes.Data.Amount/100)
+	case "transfer.success":
+		pc.completePayout(ctx, reference)
+	case "transfer.failed", "transfer.reversed":
+		pc.refundPayout(ctx, c, reference, verifyRes.Event)
+	default:
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeIgnored).Inc()
+	}
+}
+
+// creditDeposit credits a paid donation to its recipient, or holds it when
+// it would breach their limits. Paystack may deliver the same event more
+// than once; only the first delivery finds the donation pending.
+func (pc *PaymentController) creditDeposit(ctx context.Context, c *gin.Context, reference string, amount int) {
+	transaction, err := pc.TransactionService.GetTransactionByReference(ctx, &reference)
+	if err != nil {
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeUnknownReference).Inc()
+		slog.ErrorContext(ctx, "finding transaction for deposit", "reference", reference, "err", err)
+		return
+	}
+	if transaction.Status != services.TransactionPending {
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeDuplicate).Inc()
+		return
 	}
-	paidUser, err := pc.UserService.GetUser(&verifyRes.Data.Customer.Email)
+	paidUser, err := pc.UserService.GetUserByID(ctx, transaction.User_ID)
 	if err != nil {
-		fmt.Println("err", err)
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeUnknownUser).Inc()
+		slog.ErrorContext(ctx, "finding user for deposit", "reference", reference, "err", err)
+		return
 	}
-	newAmount := verifyRes.Data.Amount / 100
-	updateErr := pc.UserService.UpdateUserBalance(paidUser, newAmount, "add")
-	if updateErr != nil {
-		fmt.Println("updateErr", updateErr)
+	// Donations that would take the account over its limits are held
+	// instead of credited.
+	_, balance, creditErr := pc.WalletService.CreditDeposit(ctx, reference, amount)
+	if errors.Is(creditErr, services.ErrTransactionNotPending) {
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeDuplicate).Inc()
+		return
+	}
+	var breach *services.LimitError
+	if errors.As(creditErr, &breach) {
+		countDonation(telemetry.OutcomeHeld, amount)
+		recordAudit(c, pc.AuditService, models.AuditEvent{
+			Action:     services.AuditDepositHeld,
+			Actor_Role: services.AuditActorSystem,
+			Subject_ID: paidUser.User_ID,
+			Resource:   reference,
+			Outcome:    services.AuditSucceeded,
+			Detail:     map[string]interface{}{"amount": amount, "reason": breach.Error()},
+		})
+		return
 	}
-	updateTransErr := pc.TransactionService.UpdateTransactionStatus(&verifyRes.Data.Reference)
-	if updateTransErr != nil {
-		fmt.Println("updateTransErr", updateTransErr)
+	if creditErr != nil {
+		slog.ErrorContext(ctx, "crediting deposit", "user_id", paidUser.User_ID, "reference", reference, "err", creditErr)
+		countDonation(telemetry.OutcomeFailed, amount)
+		// A 500 makes Paystack deliver the event again later.
+		c.Status(http.StatusInternalServerError)
+	} else {
+		countDonation(telemetry.OutcomeCredited, amount)
 	}
+	recordAudit(c, pc.AuditService, models.AuditEvent{
+		Action:     services.AuditDepositCredited,
+		Actor_Role: services.AuditActorSystem,
+		Subject_ID: paidUser.User_ID,
+		Resource:   reference,
+		Outcome:    services.AuditOutcome(creditErr),
+		Detail:     map[string]interface{}{"amount": amount, "balance": balance},
+	})
+}
 
+// completePayout settles a payout Paystack reports as paid.
+func (pc *PaymentController) completePayout(ctx context.Context, reference string) {
+	_, err := pc.WalletService.CompletePayout(ctx, reference)
+	switch {
+	case errors.Is(err, services.ErrTransactionNotPending):
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeDuplicate).Inc()
+	case err != nil:
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeUnknownReference).Inc()
+		slog.ErrorContext(ctx, "completing payout", "reference", reference, "err", err)
+	default:
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeSucceeded).Inc()
+		telemetry.Payouts.WithLabelValues(telemetry.OutcomeSucceeded).Inc()
+	}
+}
+
+// refundPayout returns a payout Paystack could not pay to the user's
+// balance.
+func (pc *PaymentController) refundPayout(ctx context.Context, c *gin.Context, reference, event string) {
+	transaction, err := pc.WalletService.RefundPayout(ctx, reference)
+	switch {
+	case errors.Is(err, services.ErrTransactionNotPending):
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeDuplicate).Inc()
+		return
+	case err != nil:
+		telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeFailed).Inc()
+		slog.ErrorContext(ctx, "refunding payout", "reference", reference, "event", event, "err", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeRefunded).Inc()
+	telemetry.Payouts.WithLabelValues(telemetry.OutcomeRefunded).Inc()
+	recordAudit(c, pc.AuditService, models.AuditEvent{
+		Action:     services.AuditPayoutRefunded,
+		Actor_Role: services.AuditActorSystem,
+		Subject_ID: transaction.User_ID,
+		Resource:   reference,
+		Outcome:    services.AuditSucceeded,
+		Detail:     map[string]interface{}{"amount": transaction.Amount, "event": event},
+	})
+}
+
+func countDonation(outcome string, amount int) {
+	telemetry.WebhookEvents.WithLabelValues(outcome).Inc()
+	telemetry.Donations.WithLabelValues(outcome).Inc()
+	telemetry.DonationVolume.WithLabelValues(outcome).Add(float64(amount))
 }
 
 func (pc *PaymentController) GetBanks(c *gin.Context) {
-	var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
 
-	var bankResponse BankResponse
-	banks, err := pc.PaymentService.GetBanks()
+	bankResponse, err := pc.PaymentService.GetBanks(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-	}
-	e := json.Unmarshal([]byte(banks), &bankResponse)
-	if e != nil {
-		log.Println("Error:", e)
+		api.Fail(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"error":         false,
-		"response code": 200,
-		"message":       "List of banks generated successfully",
-		"data":          bankResponse,
-	})
-	return
+	api.OK(c, "List of banks generated successfully", bankResponse)
 }
 
 func (pc *PaymentController) Payout(c *gin.Context) {
-	var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		api.Fail(c, api.Unauthorized("User not found"))
 		return
 	}
 	userStruct, ok := user.(middleware.User)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not a valid struct"})
+		api.Fail(c, api.Unauthorized("User not a valid struct"))
+		return
 	}
-	var (
-		payout            PayoutRequest
-		transferRecipient TransferRecipientResponse
-		transfer          Transfer
-	)
-	if err := c.BindJSON(&payout); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+	var payout PayoutRequest
+	if err := c.ShouldBindJSON(&payout); err != nil {
+		api.Fail(c, api.InvalidBody(err))
 		return
 	}
 	validationErr := Validate.Struct(payout)
 	if validationErr != nil {
-		fmt.Println(validationErr)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       validationErr.Error(),
-			"data":          "",
-		})
+		api.Fail(c, api.Validation(validationErr))
 		return
 	}
-	foundBank, err := pc.BankService.GetUserBankByID(*userStruct.Id)
-	defer cancel()
+	foundBank, err := pc.BankService.GetUserBankByID(c.Request.Context(), *userStruct.Id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "User's bank does not exist",
-			"data":          "",
-		})
+		api.Fail(c, api.NotFoundOr(err, "User's bank does not exist"))
 		return
 	}
-	foundUser, err := pc.PaymentService.PaymentGetUser(userStruct.Email)
-	defer cancel()
+	foundUser, err := pc.PaymentService.PaymentGetUser(c.Request.Context(), userStruct.Email)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         true,
-			"response code": 400,
-			"message":       "Account does not exist",
-			"data":          "",
-		})
+		api.Fail(c, api.NotFoundOr(err, "Account does not exist"))
+		return
+	}
+	if !requireTwoFactor(c, pc.TwoFactorService, foundUser, services.TwoFactorPurposePayout, payout.Code) {
 		return
 	}
 	if foundUser.Balance < payout.Amount {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error":         true,
-			"response code": 403,
-			"message":       "You cannot withdraw an amount greater than your balance",
-			"data":          "",
-		})
+		api.Fail(c, services.ErrInsufficientBalance)
 		return
 	}
-	transferReciept, err := pc.PaymentService.TransferRecipientCreation(
+	if !checkLimit(c, pc.LimitService.CheckPayout(c.Request.Context(), foundUser, payout.Amount)) {
+		return
+	}
+	transferRecipient, err := pc.PaymentService.TransferRecipientCreation(
+		c.Request.Context(),
 		*foundUser.Fullname,
 		*foundBank.Account_Number,
 		*foundBank.Bank_Name,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		api.Fail(c, err)
 		return
 	}
-	e := json.Unmarshal([]byte(transferReciept), &transferRecipient)
-	if e != nil {
-		log.Println("Error:", e)
+	if !transferRecipient.Data.Active {
+		api.Fail(c, api.NewError(http.StatusBadGateway, api.CodeProviderError, "Your bank account cannot receive transfers yet. Please try again later."))
 		return
 	}
-	fmt.Println("tranferRecipient", transferRecipient)
-	if transferRecipient.Status && transferRecipient.Data.Active == true {
-		newAmount := payout.Amount * 100
-		transferResponse, err := pc.PaymentService.InitiateTransfer(newAmount, transferRecipient.Data.Recipient_code)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		e := json.Unmarshal([]byte(transferResponse), &transfer)
-		if e != nil {
-			log.Println("Error:", e)
+
+	// The amount leaves the balance before the transfer is sent and comes
+	// back if Paystack refuses it, so concurrent payouts cannot overdraw.
+	reference := uuid.NewString()
+	if _, err := pc.WalletService.ReservePayout(c.Request.Context(), foundUser, payout.Amount, reference); err != nil {
+		api.Fail(c, err)
+		return
+	}
+	// From here on the transfer may have been sent, so the outcome is
+	// settled on a deadline of its own even if the client hangs up.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), webhookTimeout)
+	defer cancel()
+	transfer, err := pc.PaymentService.InitiateTransfer(ctx, payout.Amount*100, transferRecipient.Data.Recipient_Code, reference)
+	event := models.AuditEvent{
+		Action:   services.AuditPayout,
+		Resource: reference,
+		Outcome:  services.AuditOutcome(err),
+		Detail:   map[string]interface{}{"amount": payout.Amount, "recipient_code": transferRecipient.Data.Recipient_Code},
+	}
+	if transfer != nil {
+		event.Detail["transfer_code"] = transfer.Data.Transfer_Code
+	}
+	recordAudit(c, pc.AuditService, event)
+
+	if err != nil {
+		if !transferNotSent(err) {
+			// Paystack may have the transfer; its webhook settles it.
+			slog.WarnContext(ctx, "payout outcome unknown", "reference", reference, "err", err)
+			telemetry.Payouts.WithLabelValues(telemetry.OutcomePending).Inc()
+			api.Accepted(c, "Withdrawal is being processed", gin.H{"reference": reference, "status": services.TransactionPending})
 			return
 		}
-		fmt.Println("transfer", transfer)
-
-		if !transfer.Status {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":         true,
-				"response code": 400,
-				"message":       nil,
-				"data":          "Failed to complete transaction. Please try again.",
-			})
-			return
+		telemetry.Payouts.WithLabelValues(telemetry.OutcomeFailed).Inc()
+		if _, refundErr := pc.WalletService.RefundPayout(ctx, reference); refundErr != nil {
+			slog.ErrorContext(ctx, "refunding refused payout", "reference", reference, "err", refundErr)
 		}
-		updateErr := pc.UserService.UpdateUserBalance(foundUser, payout.Amount, "subtract")
-		if updateErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":         true,
-				"response code": 400,
-				"message":       updateErr.Error(),
-				"data":          "Failed to update user balance. Please try again.",
-			})
-			return
+		api.Fail(c, err)
+		return
+	}
+	telemetry.PayoutVolume.Add(float64(payout.Amount))
+	status := services.TransactionPending
+	if transfer.Data.Status == "success" {
+		if _, err := pc.WalletService.CompletePayout(ctx, reference); err != nil && !errors.Is(err, services.ErrTransactionNotPending) {
+			slog.ErrorContext(ctx, "completing payout", "reference", reference, "err", err)
+		} else {
+			status = services.TransactionComplete
+			telemetry.Payouts.WithLabelValues(telemetry.OutcomeSucceeded).Inc()
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"error":         false,
-			"response code": 200,
-			"message":       "Withdrawal operation successful",
-			"data":          "",
-		})
+	} else {
+		telemetry.Payouts.WithLabelValues(telemetry.OutcomePending).Inc()
+	}
+	api.OK(c, "Withdrawal operation successful", gin.H{"reference": reference, "status": status})
+}
+
+// transferNotSent reports whether a failed transfer call certainly did not
+// move money: Paystack answered and refused it. Timeouts and outages leave
+// the outcome unknown.
+func transferNotSent(err error) bool {
+	var providerErr *services.ProviderError
+	if !errors.As(err, &providerErr) {
+		return false
+	}
+	return errors.Is(err, services.ErrProviderRejected) ||
+		errors.Is(err, services.ErrProviderNotFound) ||
+		errors.Is(err, services.ErrProviderAuth) ||
+		errors.Is(err, services.ErrProviderRateLimited)
+}
+
+func (pc *PaymentController) GetLimits(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		api.Fail(c, api.Unauthorized("User not found"))
 		return
 	}
-	c.JSON(http.StatusBadRequest, gin.H{
-		"error":         true,
-		"response code": 403,
-		"message":       nil,
-		"data":          "Something went wrong. Please try again.",
-	})
-	return
+	userStruct, ok := user.(middleware.User)
+	if !ok {
+		api.Fail(c, api.Unauthorized("User not a valid struct"))
+		return
+	}
+	foundUser, err := pc.PaymentService.PaymentGetUser(c.Request.Context(), userStruct.Email)
+	if err != nil {
+		api.Fail(c, api.NotFoundOr(err, "Account does not exist"))
+		return
+	}
+	summary, err := pc.LimitService.GetLimitSummary(c.Request.Context(), foundUser)
+	if err != nil {
+		api.Fail(c, api.Internal(err))
+		return
+	}
+	api.OK(c, "Limits retrieved successfully", summary)
 }
 
-func (pc *PaymentController) PaymentRoute(rg *gin.RouterGroup) {
+// checkLimit fails the request when a limit check did not pass and
+// reports whether the request may continue.
+func checkLimit(c *gin.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	api.Fail(c, err)
+	return false
+}
+
+// PaymentRoute registers the payment routes. Webhooks are only accepted
+// when signed with paystackSecret.
+func (pc *PaymentController) PaymentRoute(rg *gin.RouterGroup, paystackSecret string) {
 	paymentRoute := rg.Group("/payment")
 	// {
 	// 	paymentRoute.Use(middleware.CORSMiddleware())
@@ -422,11 +510,15 @@ func (pc *PaymentController) PaymentRoute(rg *gin.RouterGroup) {
 	paymentRoute.POST("/payin", pc.Payin)
 	paymentRoute.GET("/banks", pc.GetBanks)
 	paymentRoute.POST("/confirmation",
-		// middleware.PaystackWebhook(),
+		middleware.PaystackWebhook(paystackSecret),
 		pc.ConfirmWebhook)
 	paymentRoute.POST("/payouts",
 		middleware.Authentication,
 		pc.Payout,
 	)
+	paymentRoute.GET("/limits",
+		middleware.Authentication,
+		pc.GetLimits,
+	)
 	// }
 }
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/JayJosh846/donationPlatform/api"
+	"github.com/JayJosh846/donationPlatform/middleware"
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/services"
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorEmailCodeRequest struct {
+	Purpose string `json:"purpose" validate:"required,oneof=login payout bank_change disable_2fa"`
+}
+
+// requireTwoFactor enforces the second factor for users who enabled it.
+// When no code is supplied to an email 2FA user a fresh code is mailed
+// out. It writes the error response itself and reports whether the caller
+// may proceed.
+func requireTwoFactor(c *gin.Context, tfs services.TwoFactorService, user *models.User, purpose, code string) bool {
+	if !user.Two_Factor.Enabled {
+		return true
+	}
+	if code == "" && user.Two_Factor.Method == services.TwoFactorMethodEmail {
+		if err := tfs.SendEmailCode(c.Request.Context(), user, purpose); err != nil {
+			slog.ErrorContext(c.Request.Context(), "sending two-factor code", "user_id", user.User_ID, "err", err)
+		}
+	}
+	err := tfs.VerifyCode(c.Request.Context(), user, purpose, code)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, services.ErrTwoFactorRequired) {
+		api.Fail(c, api.NewError(http.StatusUnauthorized, api.CodeTwoFactorRequired, "Two-factor code required").WithData(gin.H{
+			"two_factor_required": true,
+			"method":              user.Two_Factor.Method,
+		}))
+		return false
+	}
+	api.Fail(c, err)
+	return false
+}
+
+func (uc *UserController) checkTwoFactor(c *gin.Context, user *models.User, purpose, code string) bool {
+	return requireTwoFactor(c, uc.TwoFactorService, user, purpose, code)
+}
+
+func (uc *UserController) authenticatedUser(c *gin.Context) (*models.User, bool) {
+	return authenticatedUser(c, uc.UserService)
+}
+
+// authenticatedUser loads the caller set by middleware.Authentication and
+// writes the error response when there is none.
+func authenticatedUser(c *gin.Context, userService services.UserService) (*models.User, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		api.Fail(c, api.Unauthorized("User not found"))
+		return nil, false
+	}
+	userStruct, ok := user.(middleware.User)
+	if !ok {
+		api.Fail(c, api.Unauthorized("User not a valid struct"))
+		return nil, false
+	}
+	foundUser, err := userService.GetUserByID(c.Request.Context(), *userStruct.Id)
+	if err != nil {
+		api.Fail(c, api.NotFoundOr(err, "User does not exist"))
+		return nil, false
+	}
+	return foundUser, true
+}
+
+func (uc *UserController) SetupTOTP(c *gin.Context) {
+	foundUser, ok := uc.authenticatedUser(c)
+	if !ok {
+		return
+	}
+	secret, uri, err := uc.TwoFactorService.BeginTOTPEnrollment(c.Request.Context(), foundUser)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	api.OK(c, "Scan the QR code with your authenticator app and confirm with a code", gin.H{
+		"secret":           secret,
+		"provisioning_uri": uri,
+	})
+}
+
+func (uc *UserController) ConfirmTOTP(c *gin.Context) {
+	foundUser, ok := uc.authenticatedUser(c)
+	if !ok {
+		return
+	}
+	var request TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		api.Fail(c, api.InvalidBody(err))
+		return
+	}
+	if validationErr := Validate.Struct(request); validationErr != nil {
+		api.Fail(c, api.Validation(validationErr))
+		return
+	}
+	recoveryCodes, err := uc.TwoFactorService.ConfirmTOTPEnrollment(c.Request.Context(), foundUser, request.Code)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	recordAudit(c, uc.AuditService, models.AuditEvent{
+		Action:  services.AuditTwoFactorEnabled,
+		Outcome: services.AuditSucceeded,
+		Detail:  map[string]interface{}{"method": services.TwoFactorMethodTOTP},
+	})
+	api.OK(c, "Two-factor authentication enabled. Store your recovery codes safely, they will not be shown again", gin.H{
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+func (uc *UserController) EnableEmailTwoFactor(c *gin.Context) {
+	foundUser, ok := uc.authenticatedUser(c)
+	if !ok {
+		return
+	}
+	recoveryCodes, err := uc.TwoFactorService.EnableEmailTwoFactor(c.Request.Context(), foundUser)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	recordAudit(c, uc.AuditService, models.AuditEvent{
+		Action:  services.AuditTwoFactorEnabled,
+		Outcome: services.AuditSucceeded,
+		Detail:  map[string]interface{}{"method": services.TwoFactorMethodEmail},
+	})
+	api.OK(c, "Two-factor authentication enabled. Store your recovery codes safely, they will not be shown again", gin.H{
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+func (uc *UserController) DisableTwoFactor(c *gin.Context) {
+	foundUser, ok := uc.authenticatedUser(c)
+	if !ok {
+		return
+	}
+	var request TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		api.Fail(c, api.InvalidBody(err))
+		return
+	}
+	err := uc.TwoFactorService.DisableTwoFactor(c.Request.Context(), foundUser, request.Code)
+	recordAudit(c, uc.AuditService, models.AuditEvent{
+		Action:  services.AuditTwoFactorDisabled,
+		Outcome: services.AuditOutcome(err),
+	})
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	api.OK(c, "Two-factor authentication disabled", "")
+}
+
+// SendTwoFactorEmailCode lets a signed-in user request an emailed code for a
+// step-up action, including TOTP users who no longer have their device.
+func (uc *UserController) SendTwoFactorEmailCode(c *gin.Context) {
+	foundUser, ok := uc.authenticatedUser(c)
+	if !ok {
+		return
+	}
+	var request TwoFactorEmailCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		api.Fail(c, api.InvalidBody(err))
+		return
+	}
+	if validationErr := Validate.Struct(request); validationErr != nil {
+		api.Fail(c, api.Validation(validationErr))
+		return
+	}
+	if !foundUser.Two_Factor.Enabled {
+		api.Fail(c, services.ErrTwoFactorNotEnabled)
+		return
+	}
+	if err := uc.TwoFactorService.SendEmailCode(c.Request.Context(), foundUser, request.Purpose); err != nil {
+		api.Fail(c, api.Internal(err))
+		return
+	}
+	api.OK(c, "Security code sent successfully", "")
+}
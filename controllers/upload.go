@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/JayJosh846/donationPlatform/api"
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/services"
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead allows for the form fields and boundaries around the
+// file when capping the request body.
+const multipartOverhead = 1 << 20
+
+// storeUpload validates the multipart file in field against the upload
+// policy for kind, saves it in store under a new key for the user and
+// returns the key. It writes the error response itself, so callers only
+// need to return when ok is false.
+func storeUpload(c *gin.Context, store services.BlobStore, user *models.User, kind, field string) (string, bool) {
+	policy := services.UploadPolicies[kind]
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, policy.Max_Size+multipartOverhead)
+
+	file, header, err := c.Request.FormFile(field)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || (err == nil && header.Size > policy.Max_Size) {
+		if file != nil {
+			file.Close()
+		}
+		api.Fail(c, &services.UploadError{
+			Code:     services.UploadFileTooLarge,
+			Message:  fmt.Sprintf("File is larger than the %d MB limit", policy.Max_Size>>20),
+			Max_Size: policy.Max_Size,
+		})
+		return "", false
+	}
+	if err != nil {
+		api.Fail(c, &services.UploadError{
+			Code:    services.UploadFileRequired,
+			Message: fmt.Sprintf("A file is required in the %q field", field),
+		})
+		return "", false
+	}
+	defer file.Close()
+
+	upload, err := services.ProcessUpload(kind, file)
+	var uploadErr *services.UploadError
+	if errors.As(err, &uploadErr) {
+		api.Fail(c, uploadErr)
+		return "", false
+	}
+	if err != nil {
+		writeUploadStoreError(c, err)
+		return "", false
+	}
+
+	ctx := c.Request.Context()
+	key := services.NewBlobKey(user.User_ID, kind, upload.Ext)
+	if err := store.Put(ctx, key, bytes.NewReader(upload.Data), upload.Content_Type); err != nil {
+		writeUploadStoreError(c, err)
+		return "", false
+	}
+	if upload.Thumbnail != nil {
+		err := store.Put(ctx, services.ThumbnailKey(key), bytes.NewReader(upload.Thumbnail), "image/jpeg")
+		if err != nil {
+			writeUploadStoreError(c, err)
+			return "", false
+		}
+	}
+	return key, true
+}
+
+func writeUploadStoreError(c *gin.Context, err error) {
+	api.Fail(c, api.NewError(http.StatusInternalServerError, api.CodeInternal, "Something went wrong while saving your file").WithCause(err))
+}
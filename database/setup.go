@@ -3,61 +3,45 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"time"
 
-	// "go.mongodb.org/mongo-driver/bson"
-	"github.com/joho/godotenv"
+	"github.com/JayJosh846/donationPlatform/config"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
-func ConnectToMongoDB() *mongo.Client {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
-	}
-
-	uri := os.Getenv("DATABASE_URL")
+// Connect opens a client for the configured database and checks that the
+// server is reachable.
+func Connect(ctx context.Context, cfg config.DatabaseConfig) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
 
-	// Define MongoDB connection options.
-	clientOptions := options.Client().ApplyURI(uri)
+	// Define MongoDB connection options. The monitor traces every command
+	// as a child of the span in its context.
+	clientOptions := options.Client().ApplyURI(cfg.URL).SetMonitor(otelmongo.NewMonitor())
 
 	// Create a MongoDB client.
-	client, err := mongo.Connect(context.Background(), clientOptions)
+	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, err
 	}
 
 	// Check if the connection was successful.
-	err = client.Ping(context.Background(), nil)
-	if err != nil {
-		return nil
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("ping mongodb: %w", err)
 	}
 
-	fmt.Println("Connected to MongoDB!")
-
-	return client
-}
-
-var Client *mongo.Client = ConnectToMongoDB()
-
-func GetUserCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	// Get a reference to the database and collection.
-	database := client.Database("Pocdonation")
-	collection := database.Collection(collectionName)
-
-	return collection
-}
+	slog.Info("connected to MongoDB")
 
-func GetDBInstance(client *mongo.Client) *mongo.Database {
-	database := client.Database("Pocdonation")
-	return database
+	return client, nil
 }
 
 func CloseMongoDBConnection(client *mongo.Client) {
 	if client != nil {
 		client.Disconnect(context.Background())
-		fmt.Println("Disconnected from MongoDB.")
+		slog.Info("disconnected from MongoDB")
 	}
 }
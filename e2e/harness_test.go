@@ -0,0 +1,444 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/app"
+	"github.com/JayJosh846/donationPlatform/config"
+	"github.com/JayJosh846/donationPlatform/middleware"
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/paystackfake"
+	"github.com/JayJosh846/donationPlatform/repository"
+	"github.com/JayJosh846/donationPlatform/services"
+	helper "github.com/JayJosh846/donationPlatform/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	authSecret     = "e2e-auth-secret"
+	paystackSecret = "sk_test_e2e"
+	password       = "correct horse"
+	dob            = "1990-12-10"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// env is the server under test, running on in-memory repositories with
+// the fake Paystack, mailer, SMS sender, identity provider and blob store.
+type env struct {
+	t        *testing.T
+	server   *httptest.Server
+	paystack *paystackfake.Server
+	services *app.Services
+	repos    *repository.Repositories
+	mail     *services.LogMailer
+	sms      *services.LogSMSSender
+	identity *services.FakeIdentityVerifier
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	// The server's address is needed for the fake's webhook URL before the
+	// server can be built on top of the fake.
+	server := httptest.NewUnstartedServer(nil)
+	fake := paystackfake.New(paystackfake.Config{
+		Secret_Key:  paystackSecret,
+		Webhook_URL: "http://" + server.Listener.Addr().String() + "/api/v1/payment/confirmation",
+	})
+	paystack := httptest.NewServer(fake)
+	t.Cleanup(paystack.Close)
+
+	cfg := &config.Config{
+		Server:   config.ServerConfig{Request_Timeout: 10 * time.Second},
+		Auth:     config.AuthConfig{Secret_Key: authSecret},
+		Paystack: config.PaystackConfig{Secret_Key: paystackSecret, Base_URL: paystack.URL},
+		Providers: config.ProvidersConfig{
+			Timeout:           5 * time.Second,
+			Max_Retries:       1,
+			Retry_Backoff:     time.Millisecond,
+			Retry_Max_Backoff: time.Millisecond,
+			Breaker_Threshold: 100,
+			Breaker_Cooldown:  time.Second,
+		},
+		// Every request comes from the same address.
+		Rate_Limit: config.RateLimitConfig{Overrides: map[string]string{
+			"signup":  "100/1h",
+			"payin":   "100/1m",
+			"payouts": "100/1m",
+		}},
+	}
+	blobs, err := services.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicBlobs, err := services.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mail := &services.LogMailer{}
+	sms := &services.LogSMSSender{}
+	identity := services.NewFakeIdentityVerifier()
+	repos := repository.NewMemory()
+	s, err := app.NewServices(cfg, repos, app.Dependencies{
+		Mailer:      mail,
+		SMS:         sms,
+		Paystack:    services.NewPaystackClient(cfg.Paystack, cfg.Providers),
+		Identity:    identity,
+		FaceMatcher: services.StubFaceMatcher{},
+		Blobs:       blobs,
+		PublicBlobs: &services.PublicBlobStore{BlobStore: publicBlobs, BaseURL: "http://files.test"},
+		UnitOfWork:  &services.SerialUnitOfWork{},
+		RateLimits:  middleware.NewMemoryRateLimitStore(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := app.NewWithServices(cfg, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Config.Handler = a.Router
+	server.Start()
+	t.Cleanup(server.Close)
+	return &env{t: t, server: server, paystack: fake, services: s, repos: repos, mail: mail, sms: sms, identity: identity}
+}
+
+// response is the envelope every endpoint answers with.
+type response struct {
+	Status  int
+	Error   bool            `json:"error"`
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// decode unmarshals the response data into v.
+func (r response) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Data, v); err != nil {
+		t.Fatalf("decoding %s: %v", r.Data, err)
+	}
+}
+
+// call sends body as JSON, with token in the header middleware.Authentication
+// reads when it is set.
+func (e *env) call(method, path, token string, body interface{}) response {
+	e.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, e.server.URL+"/api/v1"+path, reader)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return e.send(req, token)
+}
+
+// upload posts file as the multipart field named field, alongside fields.
+func (e *env) upload(path, token string, fields map[string]string, field string, file []byte) response {
+	e.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	part, err := form.CreateFormFile(field, "upload")
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	part.Write(file)
+	form.Close()
+	req, err := http.NewRequest(http.MethodPost, e.server.URL+"/api/v1"+path, &body)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return e.send(req, token)
+}
+
+func (e *env) send(req *http.Request, token string) response {
+	e.t.Helper()
+	if token != "" {
+		req.Header.Set("token", token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	defer res.Body.Close()
+	out := response{Status: res.StatusCode}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil && err != io.EOF {
+		e.t.Fatalf("%s %s: decoding response: %v", req.Method, req.URL.Path, err)
+	}
+	return out
+}
+
+// expect fails the test unless res has the wanted status.
+func (e *env) expect(res response, status int, what string) {
+	e.t.Helper()
+	if res.Status != status {
+		e.t.Fatalf("%s = %d %s %s, want %d", what, res.Status, res.Code, res.Message, status)
+	}
+}
+
+// account is a user driven through the API.
+type account struct {
+	ID    string
+	Email string
+	Name  string
+	Phone string
+	Token string
+}
+
+// signup creates an account through the API and logs into it.
+func (e *env) signup(name, email, phone string) *account {
+	e.t.Helper()
+	res := e.call(http.MethodPost, "/user/signup", "", map[string]string{
+		"full_name": name,
+		"email":     email,
+		"phone":     phone,
+		"dob":       dob,
+		"gender":    "female",
+		"password":  password,
+		"country":   "Nigeria",
+	})
+	e.expect(res, http.StatusCreated, "signup")
+
+	res = e.call(http.MethodPost, "/user/login", "", map[string]string{"email": email, "password": password})
+	e.expect(res, http.StatusOK, "login")
+	var user models.User
+	res.decode(e.t, &user)
+	if user.Token == nil || user.User_ID == "" {
+		e.t.Fatalf("login returned no token: %s", res.Data)
+	}
+	return &account{ID: user.User_ID, Email: email, Name: name, Phone: phone, Token: *user.Token}
+}
+
+var codePattern = regexp.MustCompile(`\b\d{6}\b`)
+
+// verifyEmail requests a code and confirms it with the one that was mailed.
+func (e *env) verifyEmail(a *account) {
+	e.t.Helper()
+	res := e.call(http.MethodPut, "/user/email-verification-request", a.Token, map[string]string{"email": a.Email, "phone": a.Phone})
+	e.expect(res, http.StatusOK, "email verification request")
+	message, ok := e.mail.Last()
+	if !ok || message.To != a.Email {
+		e.t.Fatalf("last email = %+v, want a code for %s", message, a.Email)
+	}
+	code := codePattern.FindString(message.Body)
+	res = e.call(http.MethodPut, "/user/email-verification", a.Token, map[string]string{"code": code})
+	e.expect(res, http.StatusOK, "email verification")
+}
+
+// pdf is the smallest document the KYC upload accepts.
+var pdf = []byte("%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\ntrailer << /Root 1 0 R >>\n%%EOF\n")
+
+// selfie is a small PNG the selfie upload accepts.
+func selfie(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// verifyPhone requests a code and confirms it with the one that was texted.
+func (e *env) verifyPhone(a *account) {
+	e.t.Helper()
+	res := e.call(http.MethodPut, "/user/phone-verification-request", a.Token, nil)
+	e.expect(res, http.StatusOK, "phone verification request")
+	message, ok := e.sms.Last()
+	if !ok || message.To != a.Phone {
+		e.t.Fatalf("last SMS = %+v, want a code for %s", message, a.Phone)
+	}
+	code := codePattern.FindString(message.Message)
+	res = e.call(http.MethodPut, "/user/phone-verification", a.Token, map[string]string{"code": code})
+	e.expect(res, http.StatusOK, "phone verification")
+}
+
+// matchFace uploads a selfie and verifies a BVN whose photo on record is
+// that selfie, which the stub face matcher accepts.
+func (e *env) matchFace(a *account) {
+	e.t.Helper()
+	ctx := context.Background()
+	res := e.upload("/user/kyc/selfie", a.Token, nil, "image", selfie(e.t))
+	e.expect(res, http.StatusOK, "selfie upload")
+	// The upload is re-encoded, so the record holds the stored copy.
+	kyc, err := e.services.Kyc.GetKycByUserID(ctx, a.ID)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	stored, err := e.services.Blobs.Get(ctx, *kyc.Kyc_Image)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	photo, err := io.ReadAll(stored)
+	stored.Close()
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	first, last, _ := strings.Cut(a.Name, " ")
+	e.identity.Add(services.IdentityBVN, "22222222222", services.IdentityResult{
+		First_Name: first,
+		Last_Name:  last,
+		DOB:        dob,
+		Photo:      base64.StdEncoding.EncodeToString(photo),
+	})
+	res = e.call(http.MethodPost, "/user/verify-bvn", a.Token, map[string]string{"bvn": "22222222222"})
+	e.expect(res, http.StatusOK, "BVN verification")
+}
+
+// verifyIdentity verifies the phone and face, submits a KYC document and
+// has an admin approve it, which raises the account to the top tier.
+func (e *env) verifyIdentity(a *account, admin string) {
+	e.t.Helper()
+	e.verifyPhone(a)
+	e.matchFace(a)
+	res := e.upload("/user/file-upload", a.Token, map[string]string{"document_type": "passport"}, "document", pdf)
+	e.expect(res, http.StatusOK, "KYC upload")
+	res = e.call(http.MethodPost, "/admin/kyc/"+a.ID+"/approve", admin, nil)
+	e.expect(res, http.StatusOK, "KYC approval")
+}
+
+// addBank adds a Guaranty Trust Bank account the fake resolves to the
+// account holder's name.
+func (e *env) addBank(a *account, number string) {
+	e.t.Helper()
+	e.paystack.AddAccount("058", number, a.Name)
+	res := e.call(http.MethodPost, "/user/add-bank", a.Token, map[string]string{
+		"account_bank":   "Guaranty Trust Bank",
+		"account_number": number,
+	})
+	e.expect(res, http.StatusCreated, "add bank")
+}
+
+// admin stores an admin account and returns a token for it.
+func (e *env) admin() string {
+	e.t.Helper()
+	id := primitive.NewObjectID()
+	email := "admin@example.com"
+	err := e.repos.Users.Create(context.Background(), &models.User{
+		ID:      id,
+		User_ID: id.Hex(),
+		Email:   &email,
+		Role:    "admin",
+	})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	token, _, err := helper.TokenGenerator(id.Hex(), email)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return token
+}
+
+// donate starts a payin to a and returns its reference. The donor has not
+// paid until the fake is told to.
+func (e *env) donate(a *account, donor string, amount int) string {
+	e.t.Helper()
+	res := e.call(http.MethodPost, "/payment/payin", "", map[string]string{
+		"email":       a.Email,
+		"donor_email": donor,
+		"amount":      strconv.Itoa(amount),
+	})
+	e.expect(res, http.StatusOK, "payin")
+	var payin services.PaystackInitializeResponse
+	res.decode(e.t, &payin)
+	return payin.Data.Reference
+}
+
+// pay has the donor pay and waits for the webhook to be handled.
+func (e *env) pay(reference string) paystackfake.Delivery {
+	e.t.Helper()
+	delivery, err := e.paystack.Pay(context.Background(), reference)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if delivery.Err != nil || delivery.Status != http.StatusOK {
+		e.t.Fatalf("charge.success webhook = %d %v", delivery.Status, delivery.Err)
+	}
+	return delivery
+}
+
+// payout asks for a withdrawal and returns the response.
+func (e *env) payout(a *account, amount int) response {
+	e.t.Helper()
+	return e.call(http.MethodPost, "/payment/payouts", a.Token, map[string]int{"amount": amount})
+}
+
+// funded signs up a verified account with a bank and amount donated to it.
+func (e *env) funded(name, email, phone string, amount int) *account {
+	e.t.Helper()
+	a := e.signup(name, email, phone)
+	e.verifyEmail(a)
+	e.verifyIdentity(a, e.admin())
+	e.addBank(a, "0123456789")
+	e.pay(e.donate(a, "donor@example.com", amount))
+	return a
+}
+
+func (e *env) balance(a *account) int {
+	e.t.Helper()
+	user, err := e.services.User.GetUserByID(context.Background(), a.ID)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return user.Balance
+}
+
+func (e *env) transaction(reference string) *models.Transaction {
+	e.t.Helper()
+	transaction, err := e.services.Transaction.GetTransactionByReference(context.Background(), &reference)
+	if err != nil {
+		e.t.Fatalf("transaction %s: %v", reference, err)
+	}
+	return transaction
+}
+
+// ledger lists the kinds and amounts of a's ledger entries, sorted so
+// entries made within the same millisecond compare equal.
+func (e *env) ledger(a *account) []string {
+	e.t.Helper()
+	entries, err := e.services.Ledger.GetUserLedger(context.Background(), a.ID, 0)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	var out []string
+	for _, entry := range entries {
+		out = append(out, entry.Kind+" "+strconv.Itoa(entry.Amount))
+	}
+	sort.Strings(out)
+	return out
+}
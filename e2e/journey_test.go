@@ -0,0 +1,403 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/api"
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/paystackfake"
+	"github.com/JayJosh846/donationPlatform/services"
+	helper "github.com/JayJosh846/donationPlatform/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDonateAndWithdraw(t *testing.T) {
+	ctx := context.Background()
+	e := newEnv(t)
+	admin := e.admin()
+
+	ada := e.signup("Ada Lovelace", "ada@example.com", "08012345678")
+	e.verifyEmail(ada)
+	user, err := e.services.User.GetUserByID(ctx, ada.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.Email_Verified {
+		t.Fatal("email is not verified")
+	}
+
+	// Payouts wait for the KYC review.
+	e.verifyIdentity(ada, admin)
+	kyc, err := e.services.Kyc.GetKycByUserID(ctx, ada.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kyc.Status != services.KycStatusApproved || kyc.Tier != services.KycDocumentTier {
+		t.Fatalf("KYC = %s at tier %d, want approved at tier %d", kyc.Status, kyc.Tier, services.KycDocumentTier)
+	}
+	if err := e.services.Workers.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if message, _ := e.mail.Last(); message.To != ada.Email || !strings.Contains(message.Subject, "identity verification") {
+		t.Fatalf("last email = %+v, want the KYC decision", message)
+	}
+	e.addBank(ada, "0123456789")
+
+	reference := e.donate(ada, "grace@example.com", 20000)
+	if payin, _ := e.paystack.Transaction(reference); payin.Amount != 2000000 || payin.Email != ada.Email {
+		t.Fatalf("paystack transaction = %+v, want 2000000 kobo for %s", payin, ada.Email)
+	}
+	if transaction := e.transaction(reference); transaction.Status != services.TransactionPending || *transaction.Donor_Email != "grace@example.com" {
+		t.Fatalf("donation = %+v, want pending from grace@example.com", transaction)
+	}
+	if exists, _ := e.services.User.EmailExists(ctx, "grace@example.com"); !exists {
+		t.Fatal("no donor account was created")
+	}
+
+	delivery := e.pay(reference)
+	if got := e.balance(ada); got != 20000 {
+		t.Fatalf("balance after the donation = %d, want 20000", got)
+	}
+	if status := e.transaction(reference).Status; status != services.TransactionComplete {
+		t.Fatalf("donation status = %s, want complete", status)
+	}
+	// Paystack delivering the event again credits nothing.
+	if again, err := e.paystack.Redeliver(ctx, delivery); err != nil || again.Status != http.StatusOK {
+		t.Fatalf("redelivery = %d %v", again.Status, err)
+	}
+	if got := e.balance(ada); got != 20000 {
+		t.Fatalf("balance after a redelivery = %d, want 20000", got)
+	}
+
+	res := e.payout(ada, 5000)
+	e.expect(res, http.StatusOK, "payout")
+	var paid struct{ Reference, Status string }
+	res.decode(t, &paid)
+	if paid.Status != services.TransactionPending {
+		t.Fatalf("payout status = %s, want pending", paid.Status)
+	}
+	if got := e.balance(ada); got != 15000 {
+		t.Fatalf("balance while the payout is pending = %d, want 15000", got)
+	}
+	transfer, ok := e.paystack.Transfer(paid.Reference)
+	if !ok || transfer.Amount != 500000 {
+		t.Fatalf("paystack transfer = %+v, want 500000 kobo", transfer)
+	}
+	if _, err := e.paystack.SettleTransfer(ctx, paid.Reference, paystackfake.EventTransferSuccess); err != nil {
+		t.Fatal(err)
+	}
+	if status := e.transaction(paid.Reference).Status; status != services.TransactionComplete {
+		t.Fatalf("payout status after transfer.success = %s, want complete", status)
+	}
+
+	// A payout the bank cannot pay comes back to the balance.
+	res = e.payout(ada, 3000)
+	e.expect(res, http.StatusOK, "second payout")
+	var failed struct{ Reference string }
+	res.decode(t, &failed)
+	if _, err := e.paystack.SettleTransfer(ctx, failed.Reference, paystackfake.EventTransferFailed); err != nil {
+		t.Fatal(err)
+	}
+	if status := e.transaction(failed.Reference).Status; status != services.TransactionFailed {
+		t.Fatalf("payout status after transfer.failed = %s, want failed", status)
+	}
+	if got := e.balance(ada); got != 15000 {
+		t.Fatalf("balance after the refund = %d, want 15000", got)
+	}
+
+	want := []string{"deposit 20000", "payout -3000", "payout -5000", "payout_refund 3000"}
+	if got := e.ledger(ada); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("ledger = %v, want %v", got, want)
+	}
+	res = e.call(http.MethodGet, "/payment/limits", ada.Token, nil)
+	e.expect(res, http.StatusOK, "limits")
+	var limits services.LimitSummary
+	res.decode(t, &limits)
+	if limits.Daily_Inflow != 20000 || limits.Daily_Payout != 5000 || limits.Balance != 15000 {
+		t.Fatalf("limits = %+v, want 20000 in, 5000 out and 15000 left", limits)
+	}
+}
+
+func TestPayoutsBeforeVerification(t *testing.T) {
+	e := newEnv(t)
+	ada := e.signup("Ada Lovelace", "ada@example.com", "08012345678")
+	e.addBank(ada, "0123456789")
+	e.pay(e.donate(ada, "grace@example.com", 5000))
+
+	res := e.payout(ada, 1000)
+	e.expect(res, http.StatusForbidden, "payout at tier 0")
+	if res.Code != api.CodeLimitExceeded {
+		t.Fatalf("payout at tier 0 failed with %s, want %s", res.Code, api.CodeLimitExceeded)
+	}
+	if got := e.balance(ada); got != 5000 {
+		t.Fatalf("balance = %d, want 5000", got)
+	}
+	if transfers := e.paystack.Transfers(); len(transfers) != 0 {
+		t.Fatalf("transfers = %+v, want none", transfers)
+	}
+
+	// Donations over the tier 0 limit are refused before the donor pays.
+	res = e.call(http.MethodPost, "/payment/payin", "", map[string]string{
+		"email": ada.Email, "donor_email": "grace@example.com", "amount": "50000",
+	})
+	e.expect(res, http.StatusForbidden, "payin over the limit")
+	if res.Code != api.CodeLimitExceeded {
+		t.Fatalf("payin over the limit failed with %s, want %s", res.Code, api.CodeLimitExceeded)
+	}
+}
+
+func TestConcurrentPayouts(t *testing.T) {
+	e := newEnv(t)
+	ada := e.funded("Ada Lovelace", "ada@example.com", "08012345678", 10000)
+
+	// Only three of these fit in the balance.
+	const payouts = 8
+	statuses := make(chan int, payouts)
+	var wg sync.WaitGroup
+	for i := 0; i < payouts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- e.payout(ada, 3000).Status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	var ok int
+	for status := range statuses {
+		switch status {
+		case http.StatusOK:
+			ok++
+		case http.StatusForbidden:
+		default:
+			t.Errorf("payout = %d, want 200 or 403", status)
+		}
+	}
+	if ok != 3 {
+		t.Fatalf("%d payouts went through, want 3", ok)
+	}
+	if got := e.balance(ada); got != 1000 {
+		t.Fatalf("balance = %d, want 1000", got)
+	}
+	if transfers := e.paystack.Transfers(); len(transfers) != 3 {
+		t.Fatalf("%d transfers sent, want 3", len(transfers))
+	}
+	want := []string{"deposit 10000", "payout -3000", "payout -3000", "payout -3000"}
+	if got := e.ledger(ada); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("ledger = %v, want %v", got, want)
+	}
+}
+
+func TestConcurrentDuplicateWebhooks(t *testing.T) {
+	ctx := context.Background()
+	e := newEnv(t)
+	ada := e.signup("Ada Lovelace", "ada@example.com", "08012345678")
+	reference := e.donate(ada, "grace@example.com", 4000)
+	delivery := e.pay(reference)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			again, err := e.paystack.Redeliver(ctx, delivery)
+			if err != nil || again.Status != http.StatusOK {
+				t.Errorf("redelivery = %d %v", again.Status, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := e.balance(ada); got != 4000 {
+		t.Fatalf("balance = %d, want 4000", got)
+	}
+	if got := e.ledger(ada); len(got) != 1 || got[0] != "deposit 4000" {
+		t.Fatalf("ledger = %v, want a single deposit", got)
+	}
+	events, err := e.services.Audit.GetAuditEvents(ctx, ada.ID, services.AuditDepositCredited, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("%d deposits audited, want 1", len(events))
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	e := newEnv(t)
+	ada := e.signup("Ada Lovelace", "ada@example.com", "08012345678")
+	reference := e.donate(ada, "grace@example.com", 4000)
+	body := []byte(`{"event":"charge.success","data":{"reference":"` + reference + `","amount":400000,"status":"success"}}`)
+
+	tests := []struct {
+		name, signature string
+	}{
+		{"unsigned", ""},
+		{"signed with another key", paystackfake.Sign("sk_test_other", body)},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodPost, e.server.URL+"/api/v1/payment/confirmation", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if tt.signature != "" {
+			req.Header.Set(paystackfake.SignatureHeader, tt.signature)
+		}
+		e.expect(e.send(req, ""), http.StatusBadRequest, tt.name)
+	}
+	if got := e.balance(ada); got != 0 {
+		t.Fatalf("balance = %d, want 0", got)
+	}
+	if status := e.transaction(reference).Status; status != services.TransactionPending {
+		t.Fatalf("donation status = %s, want pending", status)
+	}
+}
+
+func TestRefundHeldDonation(t *testing.T) {
+	e := newEnv(t)
+	admin := e.admin()
+	ada := e.signup("Ada Lovelace", "ada@example.com", "08012345678")
+
+	// Tier 0 takes 20000 a day; the fifth and sixth donations are paid but
+	// held.
+	var references []string
+	for i := 0; i < 6; i++ {
+		references = append(references, e.donate(ada, "grace@example.com", 4500))
+	}
+	for _, reference := range references {
+		e.pay(reference)
+	}
+	held, lost := references[4], references[5]
+	for _, reference := range []string{held, lost} {
+		if transaction := e.transaction(reference); transaction.Status != services.TransactionHeld {
+			t.Fatalf("donation over the limit = %s, want held", transaction.Status)
+		}
+	}
+	if got := e.balance(ada); got != 18000 {
+		t.Fatalf("balance = %d, want 18000", got)
+	}
+
+	res := e.call(http.MethodPost, "/admin/transactions/"+held+"/refund", ada.Token, nil)
+	e.expect(res, http.StatusForbidden, "refund by the recipient")
+
+	// A refund Paystack refuses leaves the donation held.
+	e.paystack.Inject(http.MethodPost, "/refund", 1, paystackfake.Fault{Status: http.StatusBadRequest, Message: "Refund refused"})
+	res = e.call(http.MethodPost, "/admin/transactions/"+held+"/refund", admin, nil)
+	if res.Status == http.StatusOK {
+		t.Fatal("refund went through although Paystack refused it")
+	}
+	if status := e.transaction(held).Status; status != services.TransactionHeld {
+		t.Fatalf("donation after a refused refund = %s, want held", status)
+	}
+
+	res = e.call(http.MethodPost, "/admin/transactions/"+held+"/refund", admin, nil)
+	e.expect(res, http.StatusOK, "refund")
+	if status := e.transaction(held).Status; status != services.TransactionRefunded {
+		t.Fatalf("donation after the refund = %s, want refunded", status)
+	}
+	if payin, _ := e.paystack.Transaction(held); payin.Status != "reversed" {
+		t.Fatalf("paystack transaction = %s, want reversed", payin.Status)
+	}
+	if got := e.balance(ada); got != 18000 {
+		t.Fatalf("balance after the refund = %d, want 18000", got)
+	}
+
+	// A refunded donation can be neither refunded again nor released.
+	res = e.call(http.MethodPost, "/admin/transactions/"+held+"/refund", admin, nil)
+	e.expect(res, http.StatusNotFound, "second refund")
+	res = e.call(http.MethodPost, "/admin/transactions/"+held+"/release", admin, nil)
+	e.expect(res, http.StatusNotFound, "release after the refund")
+	if got := e.balance(ada); got != 18000 {
+		t.Fatalf("balance after the release attempt = %d, want 18000", got)
+	}
+
+	// A refund whose answer is lost may have gone through, so the donation
+	// is not put back on hold where it could be released as well.
+	e.paystack.Inject(http.MethodPost, "/refund", 1, paystackfake.Fault{After: true})
+	res = e.call(http.MethodPost, "/admin/transactions/"+lost+"/refund", admin, nil)
+	e.expect(res, http.StatusAccepted, "refund with a lost answer")
+	if status := e.transaction(lost).Status; status != services.TransactionRefunded {
+		t.Fatalf("donation after a refund with a lost answer = %s, want refunded", status)
+	}
+	if payin, _ := e.paystack.Transaction(lost); payin.Status != "reversed" {
+		t.Fatalf("paystack transaction = %s, want reversed", payin.Status)
+	}
+	res = e.call(http.MethodPost, "/admin/transactions/"+lost+"/release", admin, nil)
+	e.expect(res, http.StatusNotFound, "release after a refund with a lost answer")
+	if got := e.balance(ada); got != 18000 {
+		t.Fatalf("balance after the release attempt = %d, want 18000", got)
+	}
+}
+
+func TestAdminLoginTwoFactor(t *testing.T) {
+	e := newEnv(t)
+	secret, err := helper.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := helper.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := primitive.NewObjectID()
+	email := "admin@example.com"
+	err = e.repos.Users.Create(context.Background(), &models.User{
+		ID:         id,
+		User_ID:    id.Hex(),
+		Email:      &email,
+		Password:   &hash,
+		Role:       "admin",
+		Two_Factor: models.TwoFactor{Enabled: true, Method: services.TwoFactorMethodTOTP, Totp_Secret: &secret},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := func(code string) response {
+		return e.call(http.MethodPost, "/admin/login", "", map[string]string{"email": email, "password": password, "code": code})
+	}
+
+	res := login("")
+	e.expect(res, http.StatusUnauthorized, "admin login without a code")
+	if res.Code != api.CodeTwoFactorRequired {
+		t.Fatalf("admin login without a code = %s, want %s", res.Code, api.CodeTwoFactorRequired)
+	}
+	if res := login("000000"); res.Status == http.StatusOK {
+		t.Fatal("admin logged in with a wrong code")
+	}
+	code, err := helper.GenerateTOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.expect(login(code), http.StatusOK, "admin login with a code")
+}
+
+func TestAdminReadsNeedAdmin(t *testing.T) {
+	e := newEnv(t)
+	admin := e.admin()
+	ada := e.signup("Ada Lovelace", "ada@example.com", "08012345678")
+	reference := e.donate(ada, "grace@example.com", 1000)
+	e.pay(reference)
+	id := e.transaction(reference).ID.Hex()
+
+	for _, path := range []string{
+		"/admin/dashboard",
+		"/admin/transactions",
+		"/admin/transaction?id=" + id,
+		"/admin/no-of-users",
+		"/admin/successful-transaction",
+		"/admin/failure-transaction",
+	} {
+		res := e.call(http.MethodGet, path, ada.Token, nil)
+		e.expect(res, http.StatusForbidden, "GET "+path+" by a user")
+		res = e.call(http.MethodGet, path, admin, nil)
+		e.expect(res, http.StatusOK, "GET "+path+" by an admin")
+	}
+}
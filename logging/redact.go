@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// sensitiveKeys are log attributes and JSON fields whose values never
+// reach the logs.
+var sensitiveKeys = map[string]bool{
+	"email":           true,
+	"donor_email":     true,
+	"phone":           true,
+	"phone_number":    true,
+	"account_number":  true,
+	"account_name":    true,
+	"name":            true,
+	"first_name":      true,
+	"middle_name":     true,
+	"last_name":       true,
+	"dob":             true,
+	"date_of_birth":   true,
+	"bvn":             true,
+	"nin":             true,
+	"license_number":  true,
+	"passport_number": true,
+	"photo":           true,
+	"image":           true,
+	"authorization":   true,
+	"access_code":     true,
+	"password":        true,
+	"token":           true,
+	"refresh_token":   true,
+	"otp":             true,
+}
+
+// RedactJSON returns data with the values of sensitive fields masked at
+// any depth. Bodies that are not JSON are dropped entirely.
+func RedactJSON(data []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(data))
+	}
+	masked, _ := json.Marshal(redactValue(value))
+	return string(masked)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if sensitiveKeys[strings.ToLower(key)] {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(field)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+	return value
+}
+
+// MaskEmail keeps enough of an address to tell accounts apart in the logs,
+// e.g. j***@example.com.
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return redacted
+	}
+	return local[:1] + "***@" + domain
+}
+
+// MaskPhone keeps only the last four digits of a phone number.
+func MaskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return redacted
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
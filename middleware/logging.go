@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxRequestIDLength bounds the client supplied IDs that are kept.
+const maxRequestIDLength = 64
+
+// RequestID tags every request with an ID, taken from the X-Request-ID
+// header when the client sent a usable one. The ID is echoed in the
+// response, stored in the request context for logging and forwarded on
+// calls to providers.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(logging.RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Header(logging.RequestIDHeader, id)
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request.id", id))
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// validRequestID accepts short IDs of letters, digits and -_. so a client
+// cannot smuggle anything else into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLog logs one line per request. It logs the route rather than the
+// path, and never the query string, since both can hold personal data or
+// signed links.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		attrs := []any{
+			"method", c.Request.Method,
+			"route", route,
+			"status", c.Writer.Status(),
+			"duration", time.Since(start).Round(time.Microsecond),
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		}
+		if user, ok := c.Get("user"); ok {
+			if u, ok := user.(User); ok && u.Id != nil {
+				attrs = append(attrs, "user_id", *u.Id)
+			}
+		}
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		slog.Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
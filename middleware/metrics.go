@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/telemetry"
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics counts requests and their durations by route. Requests that
+// match no route share one label so scanners cannot blow up the number of
+// series.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		telemetry.HTTPInFlight.Inc()
+		defer telemetry.HTTPInFlight.Dec()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		telemetry.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		telemetry.HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	// "log"
 	"net/http"
 
+	"github.com/JayJosh846/donationPlatform/api"
 	token "github.com/JayJosh846/donationPlatform/utils"
 
 	"github.com/gin-gonic/gin"
@@ -17,19 +18,12 @@ type User struct {
 func Authentication(c *gin.Context) {
 	ClientToken := c.Request.Header.Get("token")
 	if ClientToken == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No Authorization Header Provided"})
-		c.Abort()
+		api.Fail(c, api.Unauthorized("No Authorization Header Provided"))
 		return
 	}
 	claims, msg, err := token.ValidateToken(ClientToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":         true,
-			"response code": 401,
-			"message":       msg,
-			"data":          "",
-		})
-		c.Abort()
+		api.Fail(c, api.NewError(http.StatusUnauthorized, api.CodeInvalidToken, msg))
 		return
 	}
 	user := User{
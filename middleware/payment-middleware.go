@@ -1,29 +1,39 @@
 package middleware
 
 import (
+	"bytes"
 	"crypto/hmac"
 	"crypto/sha512"
 	"encoding/hex"
-	"net/http"
-	"os"
+	"io"
+	"log/slog"
 
-	// Import your utils package
+	"github.com/JayJosh846/donationPlatform/api"
+	"github.com/JayJosh846/donationPlatform/telemetry"
 	"github.com/gin-gonic/gin"
 )
 
 // PaystackWebhook is a middleware function to verify Paystack webhook signature.
-func PaystackWebhook() gin.HandlerFunc {
+// Without a secret key every event is refused, since anyone could sign with
+// the empty key.
+func PaystackWebhook(secKey string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if secKey == "" {
+			slog.ErrorContext(c.Request.Context(), "refusing Paystack webhook: no secret key is configured")
+			telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeInvalidSignature).Inc()
+			api.Fail(c, api.BadRequest("Invalid Paystack signature"))
+			return
+		}
 		// Read the request body.
 		body, err := c.GetRawData()
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Error reading request body"})
-			c.Abort()
+			api.Fail(c, api.BadRequest("Error reading request body"))
 			return
 		}
+		// Put it back for the handler.
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
 
 		// Calculate the expected signature.
-		secKey := os.Getenv("PAYSTACK_SEC_KEY")
 		secretKey := []byte(secKey)
 		h := hmac.New(sha512.New, secretKey)
 		h.Write(body)
@@ -32,10 +42,10 @@ func PaystackWebhook() gin.HandlerFunc {
 		// Get the signature from the request header.
 		signature := c.GetHeader("x-paystack-signature")
 
-		// Compare the signatures.
-		if expectedSignature != signature {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Paystack signature"})
-			c.Abort()
+		// Compare the signatures in constant time.
+		if !hmac.Equal([]byte(expectedSignature), []byte(signature)) {
+			telemetry.WebhookEvents.WithLabelValues(telemetry.OutcomeInvalidSignature).Inc()
+			api.Fail(c, api.BadRequest("Invalid Paystack signature"))
 			return
 		}
 
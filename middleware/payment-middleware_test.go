@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JayJosh846/donationPlatform/api"
+	"github.com/gin-gonic/gin"
+)
+
+func sign(key string, body []byte) string {
+	h := hmac.New(sha512.New, []byte(key))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestPaystackWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := []byte(`{"event":"transfer.reversed","data":{"reference":"ref"}}`)
+	tests := []struct {
+		name, secret, signature string
+		want                    int
+	}{
+		{"signed", "sk_test_key", sign("sk_test_key", body), http.StatusOK},
+		{"unsigned", "sk_test_key", "", http.StatusBadRequest},
+		{"signed with another key", "sk_test_key", sign("sk_test_other", body), http.StatusBadRequest},
+		{"no secret configured", "", sign("", body), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled []byte
+			server := gin.New()
+			server.Use(api.ErrorHandler())
+			server.POST("/confirmation", PaystackWebhook(tt.secret), func(c *gin.Context) {
+				handled, _ = c.GetRawData()
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/confirmation", bytes.NewReader(body))
+			if tt.signature != "" {
+				req.Header.Set("x-paystack-signature", tt.signature)
+			}
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, req)
+			if res.Code != tt.want {
+				t.Fatalf("status = %d, want %d", res.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && !bytes.Equal(handled, body) {
+				t.Fatalf("handler read %q, want the signed body", handled)
+			}
+			if tt.want != http.StatusOK && handled != nil {
+				t.Fatal("the handler ran for an unverified event")
+			}
+		})
+	}
+}
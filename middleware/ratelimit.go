@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/api"
+	token "github.com/JayJosh846/donationPlatform/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit is a token bucket holding up to Requests tokens that refills
+// completely every Per.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (r RateLimit) refillPerSecond() float64 {
+	return float64(r.Requests) / r.Per.Seconds()
+}
+
+// ParseRateLimit reads limits written as "<requests>/<duration>", for
+// example "10/1m" or "100/1h".
+func ParseRateLimit(value string) (RateLimit, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+	per, err := time.ParseDuration(parts[1])
+	if err != nil || per <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+	return RateLimit{Requests: requests, Per: per}, nil
+}
+
+type RateLimitResult struct {
+	Allowed     bool
+	Remaining   int
+	Reset       time.Duration
+	Retry_After time.Duration
+}
+
+// RateLimitStore takes one token for key from its bucket. Implementations
+// must be safe for concurrent use.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+type KeyFunc func(*gin.Context) string
+
+// KeyByIP keys on the client IP. The engine must only trust the
+// X-Forwarded-For of known proxies, see gin.Engine.SetTrustedProxies, or any
+// client can take a fresh bucket by sending the header.
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUser keys on the authenticated user, falling back to the client IP
+// for anonymous requests. It reads the token itself because global
+// middleware runs before the route's Authentication handler.
+func KeyByUser(c *gin.Context) string {
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(User); ok && u.Id != nil {
+			return "user:" + *u.Id
+		}
+	}
+	if clientToken := c.Request.Header.Get("token"); clientToken != "" {
+		if claims, _, err := token.ValidateToken(clientToken); err == nil {
+			return "user:" + claims.Id
+		}
+	}
+	return KeyByIP(c)
+}
+
+// KeyByRoute shares a single bucket between every caller of the route.
+func KeyByRoute(c *gin.Context) string {
+	return "route"
+}
+
+type RateLimitRule struct {
+	Name  string
+	Route string
+	Limit RateLimit
+	Key   KeyFunc
+}
+
+// ApplyRateLimitOverrides replaces the limit of each rule named in
+// overrides, e.g. {"payin": "5/1m"}. Unknown names are rejected so a typo
+// does not silently leave a limit at its default.
+func ApplyRateLimitOverrides(rules []RateLimitRule, overrides map[string]string) ([]RateLimitRule, error) {
+	byName := make(map[string]int, len(rules))
+	for i, rule := range rules {
+		byName[strings.ToLower(rule.Name)] = i
+	}
+	for name, value := range overrides {
+		i, ok := byName[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("rate limit override for unknown rule %q", name)
+		}
+		limit, err := ParseRateLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		rules[i].Limit = limit
+	}
+	return rules, nil
+}
+
+// RateLimiter applies the rule matching the request's route. Routes without
+// a rule are not limited. Store errors are logged and the request is let
+// through rather than failing closed.
+func RateLimiter(store RateLimitStore, rules []RateLimitRule) gin.HandlerFunc {
+	byRoute := make(map[string]RateLimitRule, len(rules))
+	for _, rule := range rules {
+		byRoute[rule.Route] = rule
+	}
+	return func(c *gin.Context) {
+		rule, ok := byRoute[c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+		keyFunc := rule.Key
+		if keyFunc == nil {
+			keyFunc = KeyByIP
+		}
+		result, err := store.Take(c.Request.Context(), rule.Name+"|"+keyFunc(c), rule.Limit)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "applying rate limit", "rule", rule.Name, "err", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(rule.Limit.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rule.Limit.Requests, ceilSeconds(rule.Limit.Per)))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.Retry_After)))
+			api.Fail(c, api.NewError(http.StatusTooManyRequests, api.CodeRateLimited, "Too many requests. Please try again later"))
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// bucketResult turns the bucket level left after a take into the values
+// reported to the client.
+func bucketResult(allowed bool, tokens float64, limit RateLimit) RateLimitResult {
+	rate := limit.refillPerSecond()
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(limit.Requests) - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		result.Retry_After = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	per     time.Duration
+}
+
+// MemoryRateLimitStore keeps buckets in process memory. It is only correct
+// when a single instance serves the traffic.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (m *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updated: now, per: limit.Per}
+		m.buckets[key] = b
+	}
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(limit.Requests), b.tokens+elapsed*limit.refillPerSecond())
+	b.updated = now
+	b.per = limit.Per
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return bucketResult(allowed, b.tokens, limit), nil
+}
+
+// sweep drops buckets that have had time to refill completely, since they
+// are indistinguishable from new ones.
+func (m *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if now.Sub(b.updated) > b.per {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// MongoRateLimitStore keeps buckets in a collection so every instance
+// behind the load balancer shares them. Each take is a single atomic
+// pipeline update.
+type MongoRateLimitStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRateLimitStore(collection *mongo.Collection) *MongoRateLimitStore {
+	return &MongoRateLimitStore{
+		collection: collection,
+	}
+}
+
+func (s *MongoRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	// The limiter fails open, so a slow store must not hold up the request.
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	now := time.Now()
+	capacity := float64(limit.Requests)
+	refilled := bson.M{"$min": bson.A{
+		capacity,
+		bson.M{"$add": bson.A{
+			bson.M{"$ifNull": bson.A{"$tokens", capacity}},
+			bson.M{"$multiply": bson.A{
+				bson.M{"$divide": bson.A{
+					bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated_at", now}}}},
+					1000,
+				}},
+				limit.refillPerSecond(),
+			}},
+		}},
+	}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"tokens": refilled, "updated_at": now}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$cond": bson.A{
+				"$allowed",
+				bson.M{"$subtract": bson.A{"$tokens", 1}},
+				"$tokens",
+			}},
+			"expires_at": now.Add(limit.Per),
+		}}},
+	}
+	var doc struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"key": key}, pipeline, opts).Decode(&doc)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return bucketResult(doc.Allowed, doc.Tokens, limit), nil
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/api"
+	"github.com/gin-gonic/gin"
+)
+
+func TestKeyByIPIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		proxies []string
+		// peer is the address the request arrives from and forwarded the
+		// X-Forwarded-For each request carries.
+		peer      string
+		forwarded []string
+		want      []int
+	}{
+		{
+			name:      "no proxy",
+			peer:      "203.0.113.7:4000",
+			forwarded: []string{"198.51.100.1", "198.51.100.2"},
+			want:      []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:      "spoofed behind a trusted proxy",
+			proxies:   []string{"10.0.0.1"},
+			peer:      "10.0.0.1:4000",
+			forwarded: []string{"198.51.100.1, 203.0.113.7", "198.51.100.2, 203.0.113.7"},
+			want:      []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:      "different clients behind a trusted proxy",
+			proxies:   []string{"10.0.0.1"},
+			peer:      "10.0.0.1:4000",
+			forwarded: []string{"203.0.113.7", "203.0.113.8"},
+			want:      []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:      "untrusted proxy",
+			proxies:   []string{"10.0.0.1"},
+			peer:      "10.0.0.2:4000",
+			forwarded: []string{"203.0.113.7", "203.0.113.8"},
+			want:      []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := gin.New()
+			if err := server.SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatal(err)
+			}
+			server.Use(api.ErrorHandler(), RateLimiter(NewMemoryRateLimitStore(), []RateLimitRule{
+				{Name: "signup", Route: "/signup", Limit: RateLimit{Requests: 1, Per: time.Hour}, Key: KeyByIP},
+			}))
+			server.POST("/signup", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			for i, forwarded := range tt.forwarded {
+				req := httptest.NewRequest(http.MethodPost, "/signup", nil)
+				req.RemoteAddr = tt.peer
+				req.Header.Set("X-Forwarded-For", forwarded)
+				res := httptest.NewRecorder()
+				server.ServeHTTP(res, req)
+				if res.Code != tt.want[i] {
+					t.Fatalf("request %d with X-Forwarded-For %q = %d, want %d", i+1, forwarded, res.Code, tt.want[i])
+				}
+			}
+		})
+	}
+}
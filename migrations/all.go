@@ -0,0 +1,184 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All is every migration, in the order they are applied. Add new ones at
+// the end with the next version; never renumber or edit one that has
+// shipped.
+var All = []Migration{
+	{
+		Version: 1,
+		Name:    "otp_indexes",
+		Up:      otpIndexesUp,
+		Down:    dropIndexes(otpIndexes),
+	},
+	{
+		Version: 2,
+		Name:    "limit_audit_rate_limit_indexes",
+		Up:      createIndexes(limitAuditRateLimitIndexes...),
+		Down:    dropIndexes(limitAuditRateLimitIndexes...),
+	},
+	{
+		Version: 3,
+		Name:    "dedupe_usernames",
+		Up:      dedupeUsernamesUp,
+		Down:    dedupeUsernamesDown,
+	},
+	{
+		Version: 4,
+		Name:    "user_unique_indexes",
+		Up:      userIndexesUp,
+		Down:    dropIndexes(userIndexes),
+	},
+	{
+		Version: 5,
+		Name:    "transaction_indexes",
+		Up:      transactionIndexesUp,
+		Down:    dropIndexes(transactionIndexes),
+	},
+	{
+		Version: 6,
+		Name:    "lookup_indexes",
+		Up:      lookupIndexesUp,
+		Down:    dropIndexes(lookupIndexes...),
+	},
+	{
+		Version: 7,
+		Name:    "backfill_kyc_status",
+		Up:      backfillKycStatusUp,
+		Down:    leaveInPlace,
+	},
+	{
+		Version: 8,
+		Name:    "clear_signed_profile_pictures",
+		Up:      clearSignedProfilePicturesUp,
+		Down:    leaveInPlace,
+	},
+	{
+		Version: 9,
+		Name:    "ledger_indexes",
+		Up:      createIndexes(ledgerIndexes),
+		Down:    dropIndexes(ledgerIndexes),
+	},
+}
+
+// leaveInPlace is the down step of backfills whose data older code simply
+// ignores.
+func leaveInPlace(context.Context, *mongo.Database) error {
+	return nil
+}
+
+// otpIndexes expire codes at expires_at and make the resend cooldown
+// atomic, as there can only be one code per user and purpose.
+var otpIndexes = indexes("Otps",
+	mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	},
+	mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	},
+)
+
+// otpIndexesUp first drops codes stored in plaintext before they were
+// hashed; they would also break the unique index.
+func otpIndexesUp(ctx context.Context, db *mongo.Database) error {
+	if _, err := db.Collection("Otps").DeleteMany(ctx, bson.M{"code_hash": bson.M{"$exists": false}}); err != nil {
+		return err
+	}
+	return createIndexes(otpIndexes)(ctx, db)
+}
+
+var limitAuditRateLimitIndexes = []indexSet{
+	indexes("LimitUsage",
+		mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "kind", Value: 1}, {Key: "created_at", Value: -1}}},
+	),
+	indexes("AuditLog",
+		mongo.IndexModel{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "subject_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "action", Value: 1}, {Key: "created_at", Value: -1}}},
+	),
+	// Only used with RATE_LIMIT_BACKEND=mongo, and harmless otherwise.
+	indexes("RateLimits",
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	),
+}
+
+var userIndexes = indexes("Users",
+	uniqueString("email"),
+	uniqueString("phone"),
+	uniqueString("username"),
+	uniqueString("user_id"),
+)
+
+func userIndexesUp(ctx context.Context, db *mongo.Database) error {
+	for _, field := range []string{"email", "phone", "username", "user_id"} {
+		if err := checkUnique(ctx, db, "Users", field); err != nil {
+			return err
+		}
+	}
+	return createIndexes(userIndexes)(ctx, db)
+}
+
+var transactionIndexes = indexes("Transactions",
+	uniqueString("reference"),
+	mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+)
+
+func transactionIndexesUp(ctx context.Context, db *mongo.Database) error {
+	if err := checkUnique(ctx, db, "Transactions", "reference"); err != nil {
+		return err
+	}
+	return createIndexes(transactionIndexes)(ctx, db)
+}
+
+// lookupIndexes cover the per-user lookups and the admin queues. KYC
+// records and login attempts are upserted, so only a unique index stops
+// two racing upserts from both inserting.
+var lookupIndexes = []indexSet{
+	indexes("Kycs",
+		uniqueString("user_id"),
+		mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "submitted_at", Value: 1}}},
+	),
+	indexes("LoginAttempts", uniqueString("key")),
+	indexes("SecurityEvents",
+		mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	),
+	indexes("Banks", mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}}),
+	indexes("Socials", mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}}),
+	indexes("Donations", mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}}),
+}
+
+func lookupIndexesUp(ctx context.Context, db *mongo.Database) error {
+	if err := checkUnique(ctx, db, "Kycs", "user_id"); err != nil {
+		return err
+	}
+	if err := checkUnique(ctx, db, "LoginAttempts", "key"); err != nil {
+		return err
+	}
+	return createIndexes(lookupIndexes...)(ctx, db)
+}
+
+// ledgerIndexes make an entry unique per reference and kind, so a deposit
+// or refund cannot be entered twice.
+var ledgerIndexes = indexes("Ledger",
+	mongo.IndexModel{
+		Keys:    bson.D{{Key: "reference", Value: 1}, {Key: "kind", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	},
+	mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+)
@@ -0,0 +1,173 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Usernames were the part of the email before the @, so john@gmail.com
+// and john@yahoo.com shared one. dedupeUsernamesUp keeps the oldest
+// account's username and gives the others a suffix from their user ID,
+// updating their profile link to match. The old name is kept in
+// previous_username for the down step.
+func dedupeUsernamesUp(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection("Users")
+	cursor, err := users.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"username": bson.M{"$type": "string"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$username",
+			"count": bson.M{"$sum": 1},
+			"users": bson.M{"$push": bson.M{"_id": "$_id", "user_id": "$user_id", "link": "$link"}},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var group struct {
+			Username string `bson:"_id"`
+			Users    []struct {
+				ID      primitive.ObjectID `bson:"_id"`
+				User_ID string             `bson:"user_id"`
+				Link    *string            `bson:"link"`
+			} `bson:"users"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return err
+		}
+		for _, user := range group.Users[1:] {
+			username := group.Username + "-" + suffix(user.User_ID, user.ID)
+			set := bson.M{"username": username, "previous_username": group.Username}
+			if user.Link != nil {
+				set["link"] = relink(*user.Link, group.Username, username)
+			}
+			if _, err := users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": set}); err != nil {
+				return err
+			}
+		}
+	}
+	return cursor.Err()
+}
+
+func dedupeUsernamesDown(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection("Users")
+	cursor, err := users.Find(ctx, bson.M{"previous_username": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"username": 1, "previous_username": 1, "link": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var user struct {
+			ID                primitive.ObjectID `bson:"_id"`
+			Username          string             `bson:"username"`
+			Previous_Username string             `bson:"previous_username"`
+			Link              *string            `bson:"link"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+		set := bson.M{"username": user.Previous_Username}
+		if user.Link != nil {
+			set["link"] = relink(*user.Link, user.Username, user.Previous_Username)
+		}
+		update := bson.M{"$set": set, "$unset": bson.M{"previous_username": ""}}
+		if _, err := users.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// suffix is the tail of the user's ID, which is unique.
+func suffix(userID string, id primitive.ObjectID) string {
+	if userID == "" {
+		userID = id.Hex()
+	}
+	if len(userID) > 6 {
+		userID = userID[len(userID)-6:]
+	}
+	return userID
+}
+
+// relink swaps the username at the end of a profile link.
+func relink(link, from, to string) string {
+	if !strings.HasSuffix(link, "/"+from) {
+		return link
+	}
+	return strings.TrimSuffix(link, from) + to
+}
+
+// KYC records from before the review queue have no status, so they never
+// show up in it. backfillKycStatusUp marks them approved when the user was
+// already verified, pending review when documents were uploaded and
+// ongoing otherwise.
+func backfillKycStatusUp(ctx context.Context, db *mongo.Database) error {
+	kycs := db.Collection("Kycs")
+	users := db.Collection("Users")
+	cursor, err := kycs.Find(ctx, bson.M{"status": bson.M{"$in": bson.A{nil, ""}}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var kyc struct {
+			ID           primitive.ObjectID `bson:"_id"`
+			User_ID      string             `bson:"user_id"`
+			Kyc_Docs     *string            `bson:"kyc_docs"`
+			Submitted_At primitive.DateTime `bson:"submitted_at"`
+			Updated_At   primitive.DateTime `bson:"updated_at"`
+		}
+		if err := cursor.Decode(&kyc); err != nil {
+			return err
+		}
+		var user struct {
+			Kyc_Status bool `bson:"kyc_status"`
+		}
+		err := users.FindOne(ctx, bson.M{"user_id": kyc.User_ID}, options.FindOne().SetProjection(bson.M{"kyc_status": 1})).Decode(&user)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+
+		update := bson.M{}
+		switch {
+		case user.Kyc_Status:
+			// Approval used to grant the document tier.
+			update["$set"] = bson.M{"status": "approved"}
+			update["$max"] = bson.M{"tier": 3}
+		case kyc.Kyc_Docs != nil && *kyc.Kyc_Docs != "":
+			set := bson.M{"status": "pending_review"}
+			if kyc.Submitted_At <= 0 {
+				set["submitted_at"] = kyc.Updated_At
+			}
+			update["$set"] = set
+		default:
+			update["$set"] = bson.M{"status": "ongoing"}
+		}
+		if _, err := kycs.UpdateOne(ctx, bson.M{"_id": kyc.ID}, update); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Before KYC files were made private, the BVN selfie was published as the
+// profile picture through a signed Cloud Storage link valid for 100 years.
+// clearSignedProfilePicturesUp removes those links; users upload a profile
+// picture of their choosing instead.
+func clearSignedProfilePicturesUp(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("Users").UpdateMany(ctx,
+		bson.M{"profile_picture": primitive.Regex{Pattern: `[?&](GoogleAccessId|X-Goog-Credential)=`}},
+		bson.M{"$unset": bson.M{"profile_picture": "", "profile_thumbnail": ""}},
+	)
+	return err
+}
@@ -0,0 +1,115 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Server error codes for a missing collection and a missing index, both of
+// which dropping treats as already done.
+const (
+	codeNamespaceNotFound = 26
+	codeIndexNotFound     = 27
+)
+
+// indexSet is indexes to create on one collection. Indexes keep the names
+// the server generates, so the ones created before migrations existed are
+// recognised as the same index.
+type indexSet struct {
+	collection string
+	indexes    []mongo.IndexModel
+}
+
+func indexes(collection string, models ...mongo.IndexModel) indexSet {
+	return indexSet{collection: collection, indexes: models}
+}
+
+// uniqueString is a unique index on field over the documents where it is
+// a string, so documents without it, such as donors without a phone, do
+// not collide on null.
+func uniqueString(field string) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys: bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{field: bson.M{"$type": "string"}}),
+	}
+}
+
+func createIndexes(sets ...indexSet) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, set := range sets {
+			if _, err := db.Collection(set.collection).Indexes().CreateMany(ctx, set.indexes); err != nil {
+				return fmt.Errorf("%s: %w", set.collection, err)
+			}
+		}
+		return nil
+	}
+}
+
+func dropIndexes(sets ...indexSet) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, set := range sets {
+			for _, model := range set.indexes {
+				name := indexName(model.Keys.(bson.D))
+				_, err := db.Collection(set.collection).Indexes().DropOne(ctx, name)
+				if err != nil && !isMissing(err) {
+					return fmt.Errorf("%s: dropping %s: %w", set.collection, name, err)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// indexName is the name the server gives an index on keys, e.g.
+// user_id_1_created_at_-1.
+func indexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+func isMissing(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == codeNamespaceNotFound || cmdErr.Code == codeIndexNotFound)
+}
+
+// checkUnique fails with a count, rather than the values, when field is
+// shared by several documents where it is a string, so the duplicates can
+// be resolved before a unique index is built. The values themselves are
+// personal data and stay out of the logs.
+func checkUnique(ctx context.Context, db *mongo.Database, collection, field string) error {
+	cursor, err := db.Collection(collection).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{field: bson.M{"$type": "string"}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$count", Value: "duplicates"}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	var result struct {
+		Duplicates int `bson:"duplicates"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if result.Duplicates > 0 {
+		return fmt.Errorf("%s: %d %s values are used by more than one document; resolve them and run the migration again", collection, result.Duplicates, field)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Package migrations versions the database schema: indexes and the
+// backfills that bring existing documents in line with the models. Each
+// migration has an up and, where the change can be undone, a down step.
+// Applied versions are recorded in the SchemaMigrations collection.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is one versioned change. Up must be safe to run again after a
+// partial failure. Down is nil when the change cannot be undone.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(context.Context, *mongo.Database) error
+	Down    func(context.Context, *mongo.Database) error
+}
+
+// Status is a migration and when it was applied, if it has been.
+type Status struct {
+	Version    int
+	Name       string
+	Applied    bool
+	Applied_At time.Time
+}
+
+var (
+	ErrLocked       = errors.New("another migration run holds the lock")
+	ErrIrreversible = errors.New("migration cannot be reverted")
+)
+
+const (
+	// lockTTL is how long a lock is honoured. A run that crashes leaves its
+	// lock behind; after this it can be taken over.
+	lockTTL = 15 * time.Minute
+	lockID  = "migrations"
+)
+
+type record struct {
+	Version    int       `bson:"_id"`
+	Name       string    `bson:"name"`
+	Applied_At time.Time `bson:"applied_at"`
+}
+
+// Migrator applies and reverts migrations against one database.
+type Migrator struct {
+	db         *mongo.Database
+	migrations []Migration
+	applied    *mongo.Collection
+	lock       *mongo.Collection
+	owner      string
+}
+
+// New returns a Migrator for the migrations in All.
+func New(db *mongo.Database) *Migrator {
+	return NewWith(db, All)
+}
+
+// NewWith returns a Migrator for the given migrations, which need not be
+// sorted.
+func NewWith(db *mongo.Database, migrations []Migration) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	host, _ := os.Hostname()
+	return &Migrator{
+		db:         db,
+		migrations: sorted,
+		applied:    db.Collection("SchemaMigrations"),
+		lock:       db.Collection("SchemaMigrationLock"),
+		owner:      fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano()),
+	}
+}
+
+// Status lists every known migration in order, followed by any applied
+// version this build does not know about.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.appliedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []Status
+	for _, migration := range m.migrations {
+		s := Status{Version: migration.Version, Name: migration.Name}
+		if r, ok := applied[migration.Version]; ok {
+			s.Applied, s.Applied_At = true, r.Applied_At
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	var unknown []Status
+	for _, r := range applied {
+		unknown = append(unknown, Status{Version: r.Version, Name: r.Name, Applied: true, Applied_At: r.Applied_At})
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+	return append(statuses, unknown...), nil
+}
+
+// Pending lists the migrations that have not been applied.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.appliedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies, in order, every pending migration up to and including
+// target, or all of them when target is 0. It stops at the first failure.
+func (m *Migrator) Up(ctx context.Context, target int) (applied []Migration, err error) {
+	if err := m.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer m.release()
+
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, migration := range pending {
+		if target > 0 && migration.Version > target {
+			break
+		}
+		start := time.Now()
+		slog.InfoContext(ctx, "applying migration", "version", migration.Version, "name", migration.Name)
+		if err := migration.Up(ctx, m.db); err != nil {
+			return applied, fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		r := record{Version: migration.Version, Name: migration.Name, Applied_At: time.Now()}
+		if _, err := m.applied.InsertOne(ctx, r); err != nil {
+			return applied, fmt.Errorf("recording migration %d: %w", migration.Version, err)
+		}
+		slog.InfoContext(ctx, "applied migration", "version", migration.Version, "duration", time.Since(start).Round(time.Millisecond))
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// Down reverts, newest first, every applied migration above target. It
+// refuses to start when one of them cannot be reverted.
+func (m *Migrator) Down(ctx context.Context, target int) (reverted []Migration, err error) {
+	if err := m.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer m.release()
+
+	applied, err := m.appliedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var revert []Migration
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version <= target {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == nil {
+			return nil, fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, ErrIrreversible)
+		}
+		revert = append(revert, migration)
+	}
+	for _, migration := range revert {
+		slog.InfoContext(ctx, "reverting migration", "version", migration.Version, "name", migration.Name)
+		if err := migration.Down(ctx, m.db); err != nil {
+			return reverted, fmt.Errorf("reverting migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		if _, err := m.applied.DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+			return reverted, fmt.Errorf("recording revert of migration %d: %w", migration.Version, err)
+		}
+		reverted = append(reverted, migration)
+	}
+	return reverted, nil
+}
+
+// Unlock removes the lock whoever holds it, for use after a run was
+// killed and before its lock expires.
+func (m *Migrator) Unlock(ctx context.Context) error {
+	_, err := m.lock.DeleteOne(ctx, bson.M{"_id": lockID})
+	return err
+}
+
+func (m *Migrator) appliedRecords(ctx context.Context) (map[int]record, error) {
+	cursor, err := m.applied.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	applied := make(map[int]record)
+	for cursor.Next(ctx) {
+		var r record
+		if err := cursor.Decode(&r); err != nil {
+			return nil, err
+		}
+		applied[r.Version] = r
+	}
+	return applied, cursor.Err()
+}
+
+// acquire takes the lock so that several instances starting at once do
+// not run the same migration twice. The upsert only matches an expired
+// lock; a live one makes it insert a second document with the same ID,
+// which fails.
+func (m *Migrator) acquire(ctx context.Context) error {
+	now := time.Now()
+	_, err := m.lock.UpdateOne(ctx,
+		bson.M{"_id": lockID, "expires_at": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"owner": m.owner, "locked_at": now, "expires_at": now.Add(lockTTL)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrLocked
+	}
+	return err
+}
+
+// release drops the lock even when ctx has been cancelled, so an
+// interrupted run does not block the next one until the lock expires.
+func (m *Migrator) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := m.lock.DeleteOne(ctx, bson.M{"_id": lockID, "owner": m.owner}); err != nil {
+		slog.Error("releasing migration lock", "err", err)
+	}
+}
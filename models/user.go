@@ -7,35 +7,38 @@ import (
 )
 
 type User struct {
-	ID              primitive.ObjectID `json:"_id" bson:"_id"`
-	User_ID         string             `json:"user_id"`
-	Fullname        *string            `json:"full_name" validate:"required,min=2,max=30"`
-	Email           *string            `json:"email" validate:"required"`
-	Phone           *string            `json:"phone" validate:"required"`
-	DOB             *string            `json:"dob" validate:"required"`
-	Gender          *string            `json:"gender" validate:"required"`
-	Password        *string            `json:"password" validate:"required,min=6"`
-	Country         *string            `json:"country" validate:"required"`
-	Role            string             `json:"role"`
-	Bio             *string            `json:"bio"`
-	Username        *string            `json:"username"`
-	Balance         int                `json:"balance"`
-	Donations       bool               `json:"donations"`
-	Email_Verified  bool               `json:"email_verified"`
-	Selfie_Upload   bool               `json:"selfie_upload"`
-	Bvn_Verified    bool               `json:"bvn_verified"`
-	ID_Upload       bool               `json:"id_upload"`
-	Kyc_Status      bool               `json:"kyc_status"`
-	Link            *string            `json:"link"`
-	Profile_Picture *string            `json:"profile_picture"`
-	Identification  *string            `json:"identification"`
-	Token           *string            `json:"token"`
-	Refresh_Token   *string            `json:"refresh_token"`
-	Created_At      time.Time          `json:"created_at"`
-	Updated_At      time.Time          `json:"updated_at"`
-	Transactions    []Transaction      `json:"transaction" bson:"transaction"`
-	Banks           Bank               `json:"bank" bson:"bank"`
-	Social          Social             `json:"socials" bson:"socials"`
+	ID                primitive.ObjectID `json:"_id" bson:"_id"`
+	User_ID           string             `json:"user_id"`
+	Fullname          *string            `json:"full_name" validate:"required,min=2,max=30"`
+	Email             *string            `json:"email" validate:"required"`
+	Phone             *string            `json:"phone" validate:"required"`
+	DOB               *string            `json:"dob" validate:"required"`
+	Gender            *string            `json:"gender" validate:"required"`
+	Password          *string            `json:"password" validate:"required,min=6,max=72"`
+	Country           *string            `json:"country" validate:"required"`
+	Role              string             `json:"role"`
+	Bio               *string            `json:"bio"`
+	Username          *string            `json:"username"`
+	Balance           int                `json:"balance"`
+	Donations         bool               `json:"donations"`
+	Email_Verified    bool               `json:"email_verified"`
+	Phone_Verified    bool               `json:"phone_verified"`
+	Selfie_Upload     bool               `json:"selfie_upload"`
+	Bvn_Verified      bool               `json:"bvn_verified"`
+	ID_Upload         bool               `json:"id_upload"`
+	Kyc_Status        bool               `json:"kyc_status"`
+	Two_Factor        TwoFactor          `json:"two_factor" bson:"two_factor"`
+	Link              *string            `json:"link"`
+	Profile_Picture   *string            `json:"profile_picture"`
+	Profile_Thumbnail *string            `json:"profile_thumbnail"`
+	Identification    *string            `json:"identification"`
+	Token             *string            `json:"token"`
+	Refresh_Token     *string            `json:"refresh_token"`
+	Created_At        time.Time          `json:"created_at"`
+	Updated_At        time.Time          `json:"updated_at"`
+	Transactions      []Transaction      `json:"transaction" bson:"transaction"`
+	Banks             Bank               `json:"bank" bson:"bank"`
+	Social            Social             `json:"socials" bson:"socials"`
 }
 
 type Transaction struct {
@@ -46,6 +49,8 @@ type Transaction struct {
 	User_Full_name *string            `json:"user_full_name" validate:"required,min=2,max=30"`
 	Amount         string             `json:"amount"`
 	Status         string             `json:"status"`
+	Hold_Reason    string             `json:"hold_reason,omitempty" bson:"hold_reason,omitempty"`
+	Type           string             `json:"type,omitempty" bson:"type,omitempty"`
 	Created_At     time.Time          `json:"created_at"`
 	Updated_At     time.Time          `json:"updated_at"`
 }
@@ -79,18 +84,119 @@ type Social struct {
 }
 
 type Otp struct {
-	User_ID    string    `json:"user_id"`
-	Token      string    `json:"token"`
-	Expires_At time.Time `json:"expires_at"`
+	ID           primitive.ObjectID `json:"_id" bson:"_id"`
+	User_ID      string             `json:"user_id" bson:"user_id"`
+	Purpose      string             `json:"purpose" bson:"purpose"`
+	Code_Hash    string             `json:"-" bson:"code_hash"`
+	Attempts     int                `json:"attempts" bson:"attempts"`
+	Expires_At   time.Time          `json:"expires_at" bson:"expires_at"`
+	Last_Sent_At time.Time          `json:"last_sent_at" bson:"last_sent_at"`
+	Created_At   time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type TwoFactor struct {
+	Enabled        bool      `json:"enabled" bson:"enabled"`
+	Method         string    `json:"method" bson:"method"`
+	Totp_Secret    *string   `json:"-" bson:"totp_secret"`
+	Recovery_Codes []string  `json:"-" bson:"recovery_codes"`
+	Pending_Until  time.Time `json:"-" bson:"pending_until"`
+	Enabled_At     time.Time `json:"enabled_at" bson:"enabled_at"`
+	// Totp_Last_Step is the time step of the last TOTP code accepted.
+	Totp_Last_Step int64 `json:"-" bson:"totp_last_step"`
 }
 
 type KYC struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	User_ID    string             `json:"user_id"`
-	Kyc_Image  *string            `json:"kyc_image" bson:"kyc_image"`
-	Kyc_Docs   *string            `json:"kyc_docs" bson:"kyc_docs"`
-	Tier       int                `json:"tier" bson:"tier"`
-	Status     string             `json:"status" bson:"status"`
-	Created_At time.Time          `json:"created_at"`
-	Updated_At time.Time          `json:"updated_at"`
+	ID               primitive.ObjectID `bson:"_id"`
+	User_ID          string             `json:"user_id"`
+	Kyc_Image        *string            `json:"kyc_image" bson:"kyc_image"`
+	Kyc_Docs         *string            `json:"kyc_docs" bson:"kyc_docs"`
+	Document_Type    string             `json:"document_type" bson:"document_type"`
+	Tier             int                `json:"tier" bson:"tier"`
+	Status           string             `json:"status" bson:"status"`
+	Rejection_Reason string             `json:"rejection_reason" bson:"rejection_reason"`
+	Submitted_At     time.Time          `json:"submitted_at" bson:"submitted_at"`
+	Review_History   []KycReview        `json:"review_history" bson:"review_history"`
+	Identity_Checks  []IdentityCheck    `json:"identity_checks" bson:"identity_checks"`
+	Face_Match_Score float64            `json:"face_match_score" bson:"face_match_score"`
+	Face_Matched     bool               `json:"face_matched" bson:"face_matched"`
+	Face_Matched_At  time.Time          `json:"face_matched_at" bson:"face_matched_at"`
+	Created_At       time.Time          `json:"created_at"`
+	Updated_At       time.Time          `json:"updated_at"`
+}
+
+type KycReview struct {
+	Action        string    `json:"action" bson:"action"`
+	Status        string    `json:"status" bson:"status"`
+	Reason        string    `json:"reason" bson:"reason"`
+	Reviewer_ID   string    `json:"reviewer_id" bson:"reviewer_id"`
+	Previous_Tier int       `json:"previous_tier" bson:"previous_tier"`
+	New_Tier      int       `json:"new_tier" bson:"new_tier"`
+	Created_At    time.Time `json:"created_at" bson:"created_at"`
+}
+
+type IdentityCheck struct {
+	Type       string    `json:"type" bson:"type"`
+	Provider   string    `json:"provider" bson:"provider"`
+	Name_Score float64   `json:"name_score" bson:"name_score"`
+	DOB_Match  bool      `json:"dob_match" bson:"dob_match"`
+	Matched    bool      `json:"matched" bson:"matched"`
+	Created_At time.Time `json:"created_at" bson:"created_at"`
+}
+
+type LoginAttempt struct {
+	Key          string    `json:"key" bson:"key"`
+	Failures     int       `json:"failures" bson:"failures"`
+	Lockouts     int       `json:"lockouts" bson:"lockouts"`
+	Last_Failure time.Time `json:"last_failure" bson:"last_failure"`
+	Last_Attempt time.Time `json:"last_attempt" bson:"last_attempt"`
+	Locked_Until time.Time `json:"locked_until" bson:"locked_until"`
+}
+
+type SecurityEvent struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id"`
+	Type       string             `json:"type" bson:"type"`
+	User_ID    string             `json:"user_id" bson:"user_id"`
+	Email      string             `json:"email" bson:"email"`
+	IP         string             `json:"ip" bson:"ip"`
+	Detail     string             `json:"detail" bson:"detail"`
+	Created_At time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// AuditEvent is one entry in the audit log of security and money
+// relevant actions. Entries are only ever inserted.
+type AuditEvent struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id"`
+	Action     string             `json:"action" bson:"action"`
+	Actor_ID   string             `json:"actor_id" bson:"actor_id"`
+	Actor_Role string             `json:"actor_role" bson:"actor_role"`
+	// Subject_ID is the user the action was about, when not the actor.
+	Subject_ID string                 `json:"subject_id,omitempty" bson:"subject_id,omitempty"`
+	Resource   string                 `json:"resource,omitempty" bson:"resource,omitempty"`
+	Outcome    string                 `json:"outcome" bson:"outcome"`
+	IP         string                 `json:"ip" bson:"ip"`
+	Request_ID string                 `json:"request_id" bson:"request_id"`
+	Detail     map[string]interface{} `json:"detail,omitempty" bson:"detail,omitempty"`
+	Created_At time.Time              `json:"created_at" bson:"created_at"`
+}
+
+type LimitUsage struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id"`
+	User_ID    string             `json:"user_id" bson:"user_id"`
+	Kind       string             `json:"kind" bson:"kind"`
+	Amount     int                `json:"amount" bson:"amount"`
+	Reference  string             `json:"reference" bson:"reference"`
+	Created_At time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// LedgerEntry is one change to a user's balance, written in the same
+// database transaction as the change itself. Amount is negative for
+// debits and Balance is the balance after the change.
+type LedgerEntry struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id"`
+	User_ID    string             `json:"user_id" bson:"user_id"`
+	Kind       string             `json:"kind" bson:"kind"`
+	Reference  string             `json:"reference" bson:"reference"`
+	Amount     int                `json:"amount" bson:"amount"`
+	Balance    int                `json:"balance" bson:"balance"`
+	Created_At time.Time          `json:"created_at" bson:"created_at"`
 }
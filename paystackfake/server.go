@@ -0,0 +1,781 @@
+// Package paystackfake is an in-memory stand-in for the parts of the
+// Paystack API the platform uses. It serves transaction initialize and
+// verify, refunds, the bank list, account resolution, transfer recipients
+// and transfers, can be told to fail or slow down, and sends webhooks signed
+// the way Paystack signs them.
+//
+// In tests, serve it with httptest and point the Paystack client at it:
+//
+//	fake := paystackfake.New(paystackfake.Config{
+//		Secret_Key:  "sk_test",
+//		Webhook_URL: app.URL + "/api/v1/payment/confirmation",
+//	})
+//	server := httptest.NewServer(fake)
+//	defer server.Close()
+//
+// cmd/fakepaystack runs it as a standalone server for local development.
+package paystackfake
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownReference = errors.New("paystackfake: unknown reference")
+	ErrAlreadyPaid      = errors.New("paystackfake: transaction already paid")
+	ErrTransferSettled  = errors.New("paystackfake: transfer already settled")
+	ErrUnknownEvent     = errors.New("paystackfake: unknown transfer event")
+)
+
+// Webhook events the fake sends.
+const (
+	EventTransferSuccess  = "transfer.success"
+	EventTransferFailed   = "transfer.failed"
+	EventTransferReversed = "transfer.reversed"
+	EventChargeSuccess    = "charge.success"
+)
+
+// SignatureHeader carries the webhook signature.
+const SignatureHeader = "x-paystack-signature"
+
+type Config struct {
+	// Secret_Key must be sent as a bearer token and signs webhooks. When
+	// empty any key is accepted.
+	Secret_Key string
+	// Webhook_URL receives the events. When empty none are sent.
+	Webhook_URL string
+	// Latency delays every API response.
+	Latency time.Duration
+	// Failure_Rate is the fraction of API requests answered with a 500.
+	Failure_Rate float64
+	// Transfer_Event, when set, is sent for every transfer Transfer_Delay
+	// after it is made. Otherwise transfers stay pending until
+	// SettleTransfer is called.
+	Transfer_Event string
+	Transfer_Delay time.Duration
+	// Resolve_Any resolves every ten digit account number, not only those
+	// added with AddAccount.
+	Resolve_Any bool
+}
+
+type Bank struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+	Code string `json:"code"`
+}
+
+// Banks is the bank list the fake serves.
+var Banks = []Bank{
+	{ID: 1, Name: "Access Bank", Slug: "access-bank", Code: "044"},
+	{ID: 2, Name: "First Bank of Nigeria", Slug: "first-bank-of-nigeria", Code: "011"},
+	{ID: 3, Name: "Guaranty Trust Bank", Slug: "guaranty-trust-bank", Code: "058"},
+	{ID: 4, Name: "Kuda Bank", Slug: "kuda-bank", Code: "50211"},
+	{ID: 5, Name: "United Bank For Africa", Slug: "united-bank-for-africa", Code: "033"},
+	{ID: 6, Name: "Wema Bank", Slug: "wema-bank", Code: "035"},
+	{ID: 7, Name: "Zenith Bank", Slug: "zenith-bank", Code: "057"},
+}
+
+// Transaction is a payment started with /transaction/initialize. Amount is
+// in kobo.
+type Transaction struct {
+	Reference   string
+	Access_Code string
+	Email       string
+	Amount      int64
+	Currency    string
+	// Status is abandoned until the payment is made, then success, and
+	// reversed once it is refunded.
+	Status  string
+	Paid_At time.Time
+}
+
+type Recipient struct {
+	Recipient_Code string
+	Name           string
+	Account_Number string
+	Bank_Code      string
+}
+
+// Transfer is a payout made with /transfer. Status is pending, success,
+// failed or reversed.
+type Transfer struct {
+	Reference      string
+	Transfer_Code  string
+	Recipient_Code string
+	Amount         int64
+	Status         string
+}
+
+// Fault is a scripted failure for the next requests to one endpoint.
+type Fault struct {
+	// Status answers the request; it defaults to 500.
+	Status  int
+	Message string
+	// Drop closes the connection without answering.
+	Drop bool
+	// After carries out the request before failing it, as when the
+	// response is lost on its way back.
+	After bool
+}
+
+// Delivery is a webhook the fake sent. Status is zero when it was not
+// answered, or when there is no Webhook_URL.
+type Delivery struct {
+	Event     string
+	Reference string
+	Body      []byte
+	Status    int
+	Err       error
+}
+
+type fault struct {
+	method, path string
+	remaining    int
+	fault        Fault
+}
+
+// Server is the fake Paystack API. It is an http.Handler and is safe for
+// concurrent use.
+type Server struct {
+	cfg    Config
+	client *http.Client
+
+	mu           sync.Mutex
+	sequence     int
+	accounts     map[string]string
+	transactions map[string]*Transaction
+	accessCodes  map[string]string
+	recipients   map[string]*Recipient
+	transfers    map[string]*Transfer
+	faults       []*fault
+	deliveries   []Delivery
+	pending      sync.WaitGroup
+}
+
+func New(cfg Config) *Server {
+	return &Server{
+		cfg:          cfg,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		accounts:     make(map[string]string),
+		transactions: make(map[string]*Transaction),
+		accessCodes:  make(map[string]string),
+		recipients:   make(map[string]*Recipient),
+		transfers:    make(map[string]*Transfer),
+	}
+}
+
+// Sign is the signature Paystack sends with a webhook body: its HMAC-SHA512
+// under the secret key, hex encoded.
+func Sign(secretKey string, body []byte) string {
+	h := hmac.New(sha512.New, []byte(secretKey))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AddAccount makes an account number at the bank with the given code
+// resolve to name.
+func (s *Server) AddAccount(bankCode, accountNumber, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[bankCode+"/"+accountNumber] = name
+}
+
+// Inject fails the next times requests to method and path. The path is the
+// endpoint without its parameters, e.g. /transaction/verify.
+func (s *Server) Inject(method, path string, times int, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{method: method, path: path, remaining: times, fault: f})
+}
+
+func (s *Server) Transaction(reference string) (Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transactions[reference]
+	if !ok {
+		return Transaction{}, false
+	}
+	return *t, true
+}
+
+func (s *Server) Transfer(reference string) (Transfer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transfers[reference]
+	if !ok {
+		return Transfer{}, false
+	}
+	return *t, true
+}
+
+// Transfers lists every transfer made, in no particular order.
+func (s *Server) Transfers() []Transfer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	transfers := make([]Transfer, 0, len(s.transfers))
+	for _, t := range s.transfers {
+		transfers = append(transfers, *t)
+	}
+	return transfers
+}
+
+// Deliveries lists the webhooks sent so far, oldest first.
+func (s *Server) Deliveries() []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Delivery(nil), s.deliveries...)
+}
+
+// Wait blocks until the webhooks sent in the background have been
+// delivered.
+func (s *Server) Wait() {
+	s.pending.Wait()
+}
+
+// Pay completes the payment for reference as if the customer had paid and
+// sends charge.success.
+func (s *Server) Pay(ctx context.Context, reference string) (Delivery, error) {
+	s.mu.Lock()
+	t, ok := s.transactions[reference]
+	if !ok {
+		s.mu.Unlock()
+		return Delivery{}, ErrUnknownReference
+	}
+	if t.Status == "success" {
+		s.mu.Unlock()
+		return Delivery{}, ErrAlreadyPaid
+	}
+	t.Status = "success"
+	t.Paid_At = time.Now().UTC()
+	data := transactionData(t)
+	s.mu.Unlock()
+	return s.send(ctx, EventChargeSuccess, reference, data)
+}
+
+// SettleTransfer moves a transfer to the outcome of event, one of the
+// transfer events, and sends it. A pending transfer can take any outcome;
+// a successful one can still be reversed.
+func (s *Server) SettleTransfer(ctx context.Context, reference, event string) (Delivery, error) {
+	var status string
+	switch event {
+	case EventTransferSuccess:
+		status = "success"
+	case EventTransferFailed:
+		status = "failed"
+	case EventTransferReversed:
+		status = "reversed"
+	default:
+		return Delivery{}, ErrUnknownEvent
+	}
+	s.mu.Lock()
+	t, ok := s.transfers[reference]
+	if !ok {
+		s.mu.Unlock()
+		return Delivery{}, ErrUnknownReference
+	}
+	if t.Status != "pending" && !(t.Status == "success" && status == "reversed") {
+		s.mu.Unlock()
+		return Delivery{}, ErrTransferSettled
+	}
+	t.Status = status
+	data := s.transferData(t)
+	s.mu.Unlock()
+	return s.send(ctx, event, reference, data)
+}
+
+// Redeliver sends a webhook again, as Paystack does when it is not sure
+// the first one arrived.
+func (s *Server) Redeliver(ctx context.Context, d Delivery) (Delivery, error) {
+	return s.post(ctx, d.Event, d.Reference, d.Body)
+}
+
+func (s *Server) send(ctx context.Context, event, reference string, data interface{}) (Delivery, error) {
+	body, err := json.Marshal(map[string]interface{}{"event": event, "data": data})
+	if err != nil {
+		return Delivery{}, err
+	}
+	return s.post(ctx, event, reference, body)
+}
+
+// post delivers a webhook body. A response of any status is a delivery;
+// only failing to get one is an error.
+func (s *Server) post(ctx context.Context, event, reference string, body []byte) (Delivery, error) {
+	d := Delivery{Event: event, Reference: reference, Body: body}
+	if s.cfg.Webhook_URL == "" {
+		return d, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Webhook_URL, bytes.NewReader(body))
+	if err != nil {
+		return d, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(s.cfg.Secret_Key, body))
+	res, err := s.client.Do(req)
+	if err != nil {
+		d.Err = err
+	} else {
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		d.Status = res.StatusCode
+	}
+	s.mu.Lock()
+	s.deliveries = append(s.deliveries, d)
+	s.mu.Unlock()
+	return d, d.Err
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/checkout/") {
+		s.checkout(w, r)
+		return
+	}
+	path, handler := s.route(r)
+	if handler == nil {
+		refuse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if s.cfg.Secret_Key != "" && r.Header.Get("Authorization") != "Bearer "+s.cfg.Secret_Key {
+		refuse(w, http.StatusUnauthorized, "Invalid key")
+		return
+	}
+	if s.cfg.Latency > 0 {
+		timer := time.NewTimer(s.cfg.Latency)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+
+	f := s.nextFault(r.Method, path)
+	if f == nil && s.cfg.Failure_Rate > 0 && rand.Float64() < s.cfg.Failure_Rate {
+		f = &Fault{}
+	}
+	if f == nil {
+		handler(w, r)
+		return
+	}
+	if f.After {
+		handler(httptest.NewRecorder(), r)
+	}
+	if f.Drop {
+		panic(http.ErrAbortHandler)
+	}
+	status, message := f.Status, f.Message
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	refuse(w, status, message)
+}
+
+// route picks the handler for an API request, along with the path faults
+// are injected on.
+func (s *Server) route(r *http.Request) (string, http.HandlerFunc) {
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodPost && path == "/transaction/initialize":
+		return path, s.initialize
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/transaction/verify/"):
+		return "/transaction/verify", s.verify
+	case r.Method == http.MethodPost && path == "/refund":
+		return path, s.refund
+	case r.Method == http.MethodGet && path == "/bank":
+		return path, s.banks
+	case r.Method == http.MethodGet && path == "/bank/resolve":
+		return path, s.resolve
+	case r.Method == http.MethodPost && path == "/transferrecipient":
+		return path, s.createRecipient
+	case r.Method == http.MethodPost && path == "/transfer":
+		return path, s.transfer
+	}
+	return "", nil
+}
+
+func (s *Server) nextFault(method, path string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.faults {
+		if f.method != method || f.path != path {
+			continue
+		}
+		f.remaining--
+		if f.remaining <= 0 {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+		}
+		return &f.fault
+	}
+	return nil
+}
+
+// next returns a new code with the given prefix. It must be called with
+// s.mu held.
+func (s *Server) next(prefix string) string {
+	s.sequence++
+	return fmt.Sprintf("%s_%08d", prefix, s.sequence)
+}
+
+func (s *Server) initialize(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		// Paystack takes the amount as a number or a string.
+		Amount    json.Number `json:"amount"`
+		Email     string      `json:"email"`
+		Currency  string      `json:"currency"`
+		Reference string      `json:"reference"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		refuse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	amount, err := body.Amount.Int64()
+	if err != nil || amount <= 0 {
+		refuse(w, http.StatusBadRequest, "Invalid Amount Sent")
+		return
+	}
+	if !strings.Contains(body.Email, "@") {
+		refuse(w, http.StatusBadRequest, "Invalid Email Address Passed")
+		return
+	}
+	if body.Currency == "" {
+		body.Currency = "NGN"
+	}
+
+	s.mu.Lock()
+	if body.Reference == "" {
+		body.Reference = s.next("T")
+	}
+	if _, ok := s.transactions[body.Reference]; ok {
+		s.mu.Unlock()
+		refuse(w, http.StatusBadRequest, "Duplicate Transaction Reference")
+		return
+	}
+	t := &Transaction{
+		Reference:   body.Reference,
+		Access_Code: s.next("ACS"),
+		Email:       body.Email,
+		Amount:      amount,
+		Currency:    body.Currency,
+		Status:      "abandoned",
+	}
+	s.transactions[t.Reference] = t
+	s.accessCodes[t.Access_Code] = t.Reference
+	s.mu.Unlock()
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	respond(w, http.StatusOK, "Authorization URL created", map[string]interface{}{
+		"authorization_url": scheme + "://" + r.Host + "/checkout/" + t.Access_Code,
+		"access_code":       t.Access_Code,
+		"reference":         t.Reference,
+	})
+}
+
+func (s *Server) verify(w http.ResponseWriter, r *http.Request) {
+	reference := strings.TrimPrefix(r.URL.Path, "/transaction/verify/")
+	s.mu.Lock()
+	t, ok := s.transactions[reference]
+	var data map[string]interface{}
+	if ok {
+		data = transactionData(t)
+	}
+	s.mu.Unlock()
+	if !ok {
+		refuse(w, http.StatusBadRequest, "Transaction reference not found")
+		return
+	}
+	respond(w, http.StatusOK, "Verification successful", data)
+}
+
+// refund returns a paid transaction in full to the customer. Like Paystack
+// it refuses to refund a transaction twice.
+func (s *Server) refund(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Transaction string `json:"transaction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		refuse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	s.mu.Lock()
+	t, ok := s.transactions[body.Transaction]
+	if !ok {
+		s.mu.Unlock()
+		refuse(w, http.StatusNotFound, "Transaction not found")
+		return
+	}
+	switch t.Status {
+	case "reversed":
+		s.mu.Unlock()
+		refuse(w, http.StatusBadRequest, "Transaction has been fully reversed")
+		return
+	case "success":
+	default:
+		s.mu.Unlock()
+		refuse(w, http.StatusBadRequest, "Cannot refund a transaction that has not been paid")
+		return
+	}
+	t.Status = "reversed"
+	data := map[string]interface{}{
+		"transaction": transactionData(t),
+		"amount":      t.Amount,
+		"currency":    t.Currency,
+		"status":      "pending",
+	}
+	s.mu.Unlock()
+	respond(w, http.StatusOK, "Refund has been queued for processing", data)
+}
+
+// transactionData is a transaction as the API and webhooks show it.
+func transactionData(t *Transaction) map[string]interface{} {
+	data := map[string]interface{}{
+		"reference":        t.Reference,
+		"amount":           t.Amount,
+		"currency":         t.Currency,
+		"status":           t.Status,
+		"channel":          "card",
+		"gateway_response": "The transaction was not completed",
+		"customer":         map[string]interface{}{"email": t.Email},
+	}
+	if t.Status == "success" {
+		data["gateway_response"] = "Successful"
+		data["paid_at"] = t.Paid_At.Format(time.RFC3339)
+	}
+	return data
+}
+
+func (s *Server) banks(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, "Banks retrieved", Banks)
+}
+
+func findBank(code string) (Bank, bool) {
+	for _, bank := range Banks {
+		if bank.Code == code {
+			return bank, true
+		}
+	}
+	return Bank{}, false
+}
+
+// accountName resolves an account. It must be called with s.mu held.
+func (s *Server) accountName(bankCode, accountNumber string) (string, bool) {
+	if name, ok := s.accounts[bankCode+"/"+accountNumber]; ok {
+		return name, true
+	}
+	if !s.cfg.Resolve_Any || len(accountNumber) != 10 || strings.Trim(accountNumber, "0123456789") != "" {
+		return "", false
+	}
+	return "TEST ACCOUNT " + accountNumber[6:], true
+}
+
+func (s *Server) resolve(w http.ResponseWriter, r *http.Request) {
+	accountNumber := r.URL.Query().Get("account_number")
+	bank, ok := findBank(r.URL.Query().Get("bank_code"))
+	if !ok {
+		refuse(w, http.StatusUnprocessableEntity, "Unknown bank code")
+		return
+	}
+	s.mu.Lock()
+	name, ok := s.accountName(bank.Code, accountNumber)
+	s.mu.Unlock()
+	if !ok {
+		refuse(w, http.StatusUnprocessableEntity, "Could not resolve account name. Check parameters or try again.")
+		return
+	}
+	respond(w, http.StatusOK, "Account number resolved", map[string]interface{}{
+		"account_number": accountNumber,
+		"account_name":   name,
+		"bank_id":        bank.ID,
+	})
+}
+
+func (s *Server) createRecipient(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Type           string `json:"type"`
+		Name           string `json:"name"`
+		Account_Number string `json:"account_number"`
+		Bank_Code      string `json:"bank_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		refuse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if body.Type != "nuban" {
+		refuse(w, http.StatusBadRequest, "Invalid recipient type")
+		return
+	}
+	if _, ok := findBank(body.Bank_Code); !ok {
+		refuse(w, http.StatusBadRequest, "Invalid bank code")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.accountName(body.Bank_Code, body.Account_Number); !ok {
+		refuse(w, http.StatusBadRequest, "Cannot resolve account")
+		return
+	}
+	// Paystack hands back the existing recipient for an account it knows.
+	key := body.Bank_Code + "/" + body.Account_Number
+	recipient, ok := s.recipients[key]
+	if !ok {
+		recipient = &Recipient{
+			Recipient_Code: s.next("RCP"),
+			Name:           body.Name,
+			Account_Number: body.Account_Number,
+			Bank_Code:      body.Bank_Code,
+		}
+		s.recipients[key] = recipient
+	}
+	respond(w, http.StatusCreated, "Transfer recipient created successfully", recipientData(recipient))
+}
+
+// recipientData is a recipient as the API and webhooks show it.
+func recipientData(recipient *Recipient) map[string]interface{} {
+	bank, _ := findBank(recipient.Bank_Code)
+	return map[string]interface{}{
+		"active":         true,
+		"type":           "nuban",
+		"currency":       "NGN",
+		"name":           recipient.Name,
+		"recipient_code": recipient.Recipient_Code,
+		"details": map[string]interface{}{
+			"account_number": recipient.Account_Number,
+			"bank_code":      recipient.Bank_Code,
+			"bank_name":      bank.Name,
+		},
+	}
+}
+
+func (s *Server) recipient(code string) (*Recipient, bool) {
+	for _, recipient := range s.recipients {
+		if recipient.Recipient_Code == code {
+			return recipient, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Server) transfer(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Source    string `json:"source"`
+		Amount    int64  `json:"amount"`
+		Recipient string `json:"recipient"`
+		Reference string `json:"reference"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		refuse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if body.Source != "balance" {
+		refuse(w, http.StatusBadRequest, "Invalid transfer source")
+		return
+	}
+	if body.Amount <= 0 {
+		refuse(w, http.StatusBadRequest, "Invalid amount")
+		return
+	}
+
+	s.mu.Lock()
+	if _, ok := s.recipient(body.Recipient); !ok {
+		s.mu.Unlock()
+		refuse(w, http.StatusBadRequest, "Recipient specified is invalid")
+		return
+	}
+	if body.Reference == "" {
+		body.Reference = s.next("TRF_REF")
+	}
+	if _, ok := s.transfers[body.Reference]; ok {
+		s.mu.Unlock()
+		refuse(w, http.StatusBadRequest, "Duplicate Transfer Reference")
+		return
+	}
+	t := &Transfer{
+		Reference:      body.Reference,
+		Transfer_Code:  s.next("TRF"),
+		Recipient_Code: body.Recipient,
+		Amount:         body.Amount,
+		Status:         "pending",
+	}
+	s.transfers[t.Reference] = t
+	data := s.transferData(t)
+	s.mu.Unlock()
+
+	if s.cfg.Transfer_Event != "" {
+		s.pending.Add(1)
+		go func() {
+			defer s.pending.Done()
+			time.Sleep(s.cfg.Transfer_Delay)
+			s.SettleTransfer(context.Background(), t.Reference, s.cfg.Transfer_Event)
+		}()
+	}
+	respond(w, http.StatusOK, "Transfer has been queued", data)
+}
+
+// transferData is a transfer as the API and webhooks show it. It must be
+// called with s.mu held.
+func (s *Server) transferData(t *Transfer) map[string]interface{} {
+	data := map[string]interface{}{
+		"reference":     t.Reference,
+		"transfer_code": t.Transfer_Code,
+		"amount":        t.Amount,
+		"currency":      "NGN",
+		"status":        t.Status,
+	}
+	if recipient, ok := s.recipient(t.Recipient_Code); ok {
+		data["recipient"] = recipientData(recipient)
+	}
+	return data
+}
+
+// checkout stands in for the payment page behind an authorization URL:
+// opening it pays the transaction.
+func (s *Server) checkout(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	reference, ok := s.accessCodes[strings.TrimPrefix(r.URL.Path, "/checkout/")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "Unknown access code", http.StatusNotFound)
+		return
+	}
+	d, err := s.Pay(r.Context(), reference)
+	switch {
+	case errors.Is(err, ErrAlreadyPaid):
+		http.Error(w, "Transaction "+reference+" is already paid", http.StatusConflict)
+	case err != nil:
+		http.Error(w, "Transaction "+reference+" is paid but the webhook failed: "+err.Error(), http.StatusBadGateway)
+	default:
+		fmt.Fprintf(w, "Transaction %s is paid. Webhook answered %d.\n", reference, d.Status)
+	}
+}
+
+func respond(w http.ResponseWriter, status int, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "message": message, "data": data})
+}
+
+func refuse(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": message})
+}
@@ -0,0 +1,253 @@
+package paystackfake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the webhooks it is sent and answers with status.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	received []webhook
+}
+
+type webhook struct {
+	Event     string
+	Reference string
+	Status    string
+	Amount    int64
+	Signed    bool
+}
+
+func (h *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var event struct {
+		Event string `json:"event"`
+		Data  struct {
+			Reference string `json:"reference"`
+			Status    string `json:"status"`
+			Amount    int64  `json:"amount"`
+		} `json:"data"`
+	}
+	json.Unmarshal(body, &event)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.received = append(h.received, webhook{
+		Event:     event.Event,
+		Reference: event.Data.Reference,
+		Status:    event.Data.Status,
+		Amount:    event.Data.Amount,
+		Signed:    r.Header.Get(SignatureHeader) == Sign("sk_test", body),
+	})
+	w.WriteHeader(h.status)
+}
+
+func (h *webhookReceiver) webhooks() []webhook {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]webhook(nil), h.received...)
+}
+
+func start(t *testing.T, cfg Config) (*Server, *httptest.Server, *webhookReceiver) {
+	t.Helper()
+	receiver := &webhookReceiver{status: http.StatusOK}
+	hooks := httptest.NewServer(receiver)
+	t.Cleanup(hooks.Close)
+	cfg.Secret_Key = "sk_test"
+	cfg.Webhook_URL = hooks.URL
+	fake := New(cfg)
+	api := httptest.NewServer(fake)
+	t.Cleanup(api.Close)
+	return fake, api, receiver
+}
+
+func call(t *testing.T, api *httptest.Server, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, api.URL+path, reader)
+	req.Header.Set("Authorization", "Bearer sk_test")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var out map[string]interface{}
+	json.NewDecoder(res.Body).Decode(&out)
+	return res.StatusCode, out
+}
+
+func TestPayment(t *testing.T) {
+	ctx := context.Background()
+	fake, api, receiver := start(t, Config{})
+	status, out := call(t, api, http.MethodPost, "/transaction/initialize", map[string]interface{}{
+		"amount": "150000", "email": "ada@example.com", "reference": "ref-1",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("initialize = %d %v", status, out)
+	}
+	if status, _ := call(t, api, http.MethodPost, "/transaction/initialize", map[string]interface{}{
+		"amount": 150000, "email": "ada@example.com", "reference": "ref-1",
+	}); status != http.StatusBadRequest {
+		t.Fatalf("initialize with a used reference = %d, want 400", status)
+	}
+
+	// Opening the authorization URL pays the transaction.
+	url := out["data"].(map[string]interface{})["authorization_url"].(string)
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("checkout = %d", res.StatusCode)
+	}
+	if _, err := fake.Pay(ctx, "ref-1"); err != ErrAlreadyPaid {
+		t.Fatalf("paying twice = %v, want ErrAlreadyPaid", err)
+	}
+	_, verified := call(t, api, http.MethodGet, "/transaction/verify/ref-1", nil)
+	if data := verified["data"].(map[string]interface{}); data["status"] != "success" {
+		t.Fatalf("verify = %v", verified)
+	}
+
+	deliveries := fake.Deliveries()
+	if len(deliveries) != 1 || deliveries[0].Status != http.StatusOK {
+		t.Fatalf("deliveries = %+v", deliveries)
+	}
+	if _, err := fake.Redeliver(ctx, deliveries[0]); err != nil {
+		t.Fatal(err)
+	}
+	want := webhook{Event: EventChargeSuccess, Reference: "ref-1", Status: "success", Amount: 150000, Signed: true}
+	got := receiver.webhooks()
+	if len(got) != 2 || got[0] != want || got[1] != want {
+		t.Fatalf("webhooks = %+v, want %+v twice", got, want)
+	}
+
+	// A paid transaction can be refunded once.
+	if status, out := call(t, api, http.MethodPost, "/refund", map[string]interface{}{"transaction": "ref-1"}); status != http.StatusOK {
+		t.Fatalf("refund = %d %v", status, out)
+	}
+	if status, _ := call(t, api, http.MethodPost, "/refund", map[string]interface{}{"transaction": "ref-1"}); status != http.StatusBadRequest {
+		t.Fatalf("refunding twice = %d, want 400", status)
+	}
+	if transaction, _ := fake.Transaction("ref-1"); transaction.Status != "reversed" {
+		t.Fatalf("status after the refund = %s, want reversed", transaction.Status)
+	}
+}
+
+func TestTransferEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		settle []string
+		// want are the statuses the webhooks carry.
+		want       []string
+		wantStatus string
+	}{
+		{name: "settled automatically", cfg: Config{Transfer_Event: EventTransferSuccess, Transfer_Delay: time.Millisecond}, want: []string{"success"}, wantStatus: "success"},
+		{name: "left pending", wantStatus: "pending"},
+		{name: "failed", settle: []string{EventTransferFailed}, want: []string{"failed"}, wantStatus: "failed"},
+		{name: "reversed after success", settle: []string{EventTransferSuccess, EventTransferReversed}, want: []string{"success", "reversed"}, wantStatus: "reversed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tt.cfg.Resolve_Any = true
+			fake, api, receiver := start(t, tt.cfg)
+			_, recipient := call(t, api, http.MethodPost, "/transferrecipient", map[string]interface{}{
+				"type": "nuban", "name": "Ada", "account_number": "0123456789", "bank_code": "044",
+			})
+			code := recipient["data"].(map[string]interface{})["recipient_code"]
+			status, out := call(t, api, http.MethodPost, "/transfer", map[string]interface{}{
+				"source": "balance", "amount": 5000, "recipient": code, "reference": "payout-1",
+			})
+			if status != http.StatusOK {
+				t.Fatalf("transfer = %d %v", status, out)
+			}
+			for _, event := range tt.settle {
+				if _, err := fake.SettleTransfer(ctx, "payout-1", event); err != nil {
+					t.Fatalf("SettleTransfer(%s) = %v", event, err)
+				}
+			}
+			fake.Wait()
+
+			var statuses []string
+			for _, hook := range receiver.webhooks() {
+				if !hook.Signed || hook.Reference != "payout-1" || !strings.HasPrefix(hook.Event, "transfer.") {
+					t.Errorf("webhook = %+v", hook)
+				}
+				statuses = append(statuses, hook.Status)
+			}
+			if strings.Join(statuses, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("webhook statuses = %v, want %v", statuses, tt.want)
+			}
+			if transfer, _ := fake.Transfer("payout-1"); transfer.Status != tt.wantStatus {
+				t.Errorf("transfer status = %s, want %s", transfer.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequests(t *testing.T) {
+	_, api, _ := start(t, Config{})
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{"bank list", http.MethodGet, "/bank", nil, http.StatusOK},
+		{"unknown account", http.MethodGet, "/bank/resolve?account_number=0123456789&bank_code=044", nil, http.StatusUnprocessableEntity},
+		{"unknown bank", http.MethodGet, "/bank/resolve?account_number=0123456789&bank_code=999", nil, http.StatusUnprocessableEntity},
+		{"unknown reference", http.MethodGet, "/transaction/verify/nope", nil, http.StatusBadRequest},
+		{"unknown recipient", http.MethodPost, "/transfer", map[string]interface{}{"source": "balance", "amount": 100, "recipient": "RCP_x"}, http.StatusBadRequest},
+		{"no amount", http.MethodPost, "/transaction/initialize", map[string]interface{}{"email": "ada@example.com"}, http.StatusBadRequest},
+		{"unknown endpoint", http.MethodGet, "/customer", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if status, _ := call(t, api, tt.method, tt.path, tt.body); status != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.want)
+		}
+	}
+
+	res, err := http.Get(api.URL + "/bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("request without a key = %d, want 401", res.StatusCode)
+	}
+}
+
+func TestInject(t *testing.T) {
+	fake, api, _ := start(t, Config{})
+	fake.Inject(http.MethodGet, "/bank", 2, Fault{Status: http.StatusTooManyRequests})
+	for _, want := range []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK} {
+		if status, _ := call(t, api, http.MethodGet, "/bank", nil); status != want {
+			t.Fatalf("status = %d, want %d", status, want)
+		}
+	}
+
+	fake.Inject(http.MethodPost, "/transaction/initialize", 1, Fault{After: true})
+	body := map[string]interface{}{"amount": 100, "email": "ada@example.com", "reference": "ref-1"}
+	if status, _ := call(t, api, http.MethodPost, "/transaction/initialize", body); status != http.StatusInternalServerError {
+		t.Fatalf("faulted initialize = %d, want 500", status)
+	}
+	if _, ok := fake.Transaction("ref-1"); !ok {
+		t.Fatal("a fault after the request should leave the transaction in place")
+	}
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditFilter selects audit entries. Empty fields match everything;
+// User_ID matches the actor or the subject.
+type AuditFilter struct {
+	User_ID string
+	Action  string
+}
+
+// AuditRepository stores the audit log. Entries are only ever added.
+type AuditRepository interface {
+	Create(context.Context, *models.AuditEvent) error
+	List(ctx context.Context, filter AuditFilter, limit int64) ([]*models.AuditEvent, error)
+}
+
+type MongoAuditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAuditRepository(collection *mongo.Collection) *MongoAuditRepository {
+	return &MongoAuditRepository{
+		collection: collection,
+	}
+}
+
+func (r *MongoAuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	_, err := r.collection.InsertOne(ctx, event)
+	return duplicate(err)
+}
+
+// List returns the newest matching entries first. A zero limit lists them
+// all.
+func (r *MongoAuditRepository) List(ctx context.Context, filter AuditFilter, limit int64) ([]*models.AuditEvent, error) {
+	query := bson.M{}
+	if filter.User_ID != "" {
+		query["$or"] = bson.A{bson.M{"actor_id": filter.User_ID}, bson.M{"subject_id": filter.User_ID}}
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.AuditEvent](ctx, cursor)
+}
+
+// MemoryAuditRepository keeps the audit log in process memory.
+type MemoryAuditRepository struct {
+	mu     sync.Mutex
+	events []*models.AuditEvent
+}
+
+func NewMemoryAuditRepository() *MemoryAuditRepository {
+	return &MemoryAuditRepository{}
+}
+
+func (r *MemoryAuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.events {
+		if other.ID == event.ID {
+			return ErrDuplicate
+		}
+	}
+	r.events = append(r.events, clone(event))
+	return nil
+}
+
+func (r *MemoryAuditRepository) List(ctx context.Context, filter AuditFilter, limit int64) ([]*models.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []*models.AuditEvent
+	for _, event := range r.events {
+		if filter.User_ID != "" && event.Actor_ID != filter.User_ID && event.Subject_ID != filter.User_ID {
+			continue
+		}
+		if filter.Action != "" && event.Action != filter.Action {
+			continue
+		}
+		events = append(events, clone(event))
+	}
+	return newest(events, func(event *models.AuditEvent) time.Time { return event.Created_At }, limit), nil
+}
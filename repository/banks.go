@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// BankRepository stores users' bank accounts.
+type BankRepository interface {
+	Create(context.Context, *models.Bank) error
+	FindByUserID(context.Context, string) (*models.Bank, error)
+	Exists(context.Context, string) (bool, error)
+}
+
+type MongoBankRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoBankRepository(collection *mongo.Collection) *MongoBankRepository {
+	return &MongoBankRepository{
+		collection: collection,
+	}
+}
+
+func (r *MongoBankRepository) Create(ctx context.Context, bank *models.Bank) error {
+	_, err := r.collection.InsertOne(ctx, bank)
+	return duplicate(err)
+}
+
+func (r *MongoBankRepository) FindByUserID(ctx context.Context, userID string) (*models.Bank, error) {
+	var bank *models.Bank
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&bank)
+	return bank, err
+}
+
+func (r *MongoBankRepository) Exists(ctx context.Context, userID string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"user_id": userID})
+	return count > 0, err
+}
+
+// MemoryBankRepository keeps a bank account in process memory.
+type MemoryBankRepository struct {
+	mu    sync.Mutex
+	banks []*models.Bank
+}
+
+func NewMemoryBankRepository() *MemoryBankRepository {
+	return &MemoryBankRepository{}
+}
+
+func (r *MemoryBankRepository) Create(ctx context.Context, bank *models.Bank) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := clone(bank)
+	for _, other := range r.banks {
+		if other.ID == stored.ID {
+			return ErrDuplicate
+		}
+	}
+	r.banks = append(r.banks, stored)
+	return nil
+}
+
+// FindByUserID returns the user's first bank, as FindOne would.
+func (r *MemoryBankRepository) FindByUserID(ctx context.Context, userID string) (*models.Bank, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, bank := range r.banks {
+		if bank.User_ID == userID {
+			return clone(bank), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryBankRepository) Exists(ctx context.Context, userID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, bank := range r.banks {
+		if bank.User_ID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DonationRepository stores the donation pages users create.
+type DonationRepository interface {
+	Create(context.Context, *models.Donation) error
+	ListByUserID(context.Context, string) ([]*models.Donation, error)
+}
+
+type MongoDonationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoDonationRepository(collection *mongo.Collection) *MongoDonationRepository {
+	return &MongoDonationRepository{
+		collection: collection,
+	}
+}
+
+func (r *MongoDonationRepository) Create(ctx context.Context, donation *models.Donation) error {
+	_, err := r.collection.InsertOne(ctx, donation)
+	return duplicate(err)
+}
+
+func (r *MongoDonationRepository) ListByUserID(ctx context.Context, userID string) ([]*models.Donation, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.Donation](ctx, cursor)
+}
+
+// MemoryDonationRepository keeps donations in process memory, in insertion
+// order.
+type MemoryDonationRepository struct {
+	mu        sync.Mutex
+	donations []*models.Donation
+}
+
+func NewMemoryDonationRepository() *MemoryDonationRepository {
+	return &MemoryDonationRepository{}
+}
+
+func (r *MemoryDonationRepository) Create(ctx context.Context, donation *models.Donation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := clone(donation)
+	for _, other := range r.donations {
+		if other.ID == stored.ID {
+			return ErrDuplicate
+		}
+	}
+	r.donations = append(r.donations, stored)
+	return nil
+}
+
+func (r *MemoryDonationRepository) ListByUserID(ctx context.Context, userID string) ([]*models.Donation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var donations []*models.Donation
+	for _, donation := range r.donations {
+		if donation.User_ID == userID {
+			donations = append(donations, clone(donation))
+		}
+	}
+	return donations, nil
+}
@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// kycStatusOngoing is the status of a record created before the user has
+// submitted anything.
+const kycStatusOngoing = "ongoing"
+
+// KycChange moves a KYC record to a new status. The optional fields are
+// only set when not nil, and Review is appended to the review history.
+type KycChange struct {
+	Status           string
+	Rejection_Reason string
+	Tier             *int
+	Kyc_Docs         *string
+	Document_Type    *string
+	Submitted_At     *time.Time
+	Review           models.KycReview
+}
+
+// KycRepository stores one KYC record per user. Ensure, RaiseTier and
+// AddIdentityCheck create a tier 0 record when the user has none.
+type KycRepository interface {
+	FindByUserID(context.Context, string) (*models.KYC, error)
+	ListByStatus(context.Context, string, int64, int64) ([]*models.KYC, error)
+	Ensure(context.Context, string) error
+	RaiseTier(context.Context, string, int) error
+	LowerTier(context.Context, string, int) error
+	Transition(context.Context, string, []string, KycChange) (*models.KYC, error)
+	AddIdentityCheck(context.Context, string, models.IdentityCheck) error
+	SetFaceMatch(context.Context, string, float64, bool) error
+	SetSelfie(context.Context, string, string) error
+}
+
+type MongoKycRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoKycRepository(collection *mongo.Collection) *MongoKycRepository {
+	return &MongoKycRepository{
+		collection: collection,
+	}
+}
+
+func (r *MongoKycRepository) FindByUserID(ctx context.Context, userID string) (*models.KYC, error) {
+	var kyc *models.KYC
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&kyc)
+	return kyc, err
+}
+
+// ListByStatus lists the records with status, the longest waiting first.
+func (r *MongoKycRepository) ListByStatus(ctx context.Context, status string, limit, skip int64) ([]*models.KYC, error) {
+	opts := options.Find().SetSort(bson.M{"submitted_at": 1}).SetLimit(limit).SetSkip(skip)
+	cursor, err := r.collection.Find(ctx, bson.M{"status": status}, opts)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.KYC](ctx, cursor)
+}
+
+func (r *MongoKycRepository) Ensure(ctx context.Context, userID string) error {
+	return r.upsert(ctx, userID, bson.M{})
+}
+
+// RaiseTier raises the user's tier to tier. It never lowers it.
+func (r *MongoKycRepository) RaiseTier(ctx context.Context, userID string, tier int) error {
+	return r.upsert(ctx, userID, bson.M{
+		"$max": bson.M{"tier": tier},
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+}
+
+// LowerTier lowers the user's tier to tier. It never raises it, and a user
+// without a record is left without one.
+func (r *MongoKycRepository) LowerTier(ctx context.Context, userID string, tier int) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userID}, bson.M{
+		"$min": bson.M{"tier": tier},
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+	return err
+}
+
+func (r *MongoKycRepository) AddIdentityCheck(ctx context.Context, userID string, check models.IdentityCheck) error {
+	return r.upsert(ctx, userID, bson.M{"$push": bson.M{"identity_checks": check}})
+}
+
+// upsert applies update to the user's record, creating it first if needed.
+// Two racing upserts can both try to insert; the unique index on user_id
+// rejects one, which then just retries as an update.
+func (r *MongoKycRepository) upsert(ctx context.Context, userID string, update bson.M) error {
+	onInsert := bson.M{
+		"_id":        primitive.NewObjectID(),
+		"status":     kycStatusOngoing,
+		"created_at": time.Now(),
+	}
+	if _, ok := update["$max"]; !ok {
+		onInsert["tier"] = 0
+	}
+	update["$setOnInsert"] = onInsert
+	opts := options.Update().SetUpsert(true)
+	_, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userID}, update, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		_, err = r.collection.UpdateOne(ctx, bson.M{"user_id": userID}, update, opts)
+	}
+	return err
+}
+
+// Transition applies change if the user's record has one of the statuses
+// in from, and returns the record as updated. Matching on the status means
+// only the first of two concurrent transitions applies; the other gets
+// ErrNotFound.
+func (r *MongoKycRepository) Transition(ctx context.Context, userID string, from []string, change KycChange) (*models.KYC, error) {
+	set := bson.M{
+		"status":           change.Status,
+		"rejection_reason": change.Rejection_Reason,
+		"updated_at":       time.Now(),
+	}
+	if change.Tier != nil {
+		set["tier"] = *change.Tier
+	}
+	if change.Kyc_Docs != nil {
+		set["kyc_docs"] = *change.Kyc_Docs
+	}
+	if change.Document_Type != nil {
+		set["document_type"] = *change.Document_Type
+	}
+	if change.Submitted_At != nil {
+		set["submitted_at"] = *change.Submitted_At
+	}
+	var kyc *models.KYC
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"user_id": userID, "status": bson.M{"$in": from}},
+		bson.M{"$set": set, "$push": bson.M{"review_history": change.Review}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&kyc)
+	return kyc, err
+}
+
+// SetFaceMatch stores the latest selfie-to-ID comparison.
+func (r *MongoKycRepository) SetFaceMatch(ctx context.Context, userID string, score float64, matched bool) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{
+			"face_match_score": score,
+			"face_matched":     matched,
+			"face_matched_at":  time.Now(),
+		}},
+	)
+	return err
+}
+
+// SetSelfie stores the key of a new selfie, which has to be matched again.
+func (r *MongoKycRepository) SetSelfie(ctx context.Context, userID, key string) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{
+			"kyc_image":    key,
+			"face_matched": false,
+		}},
+	)
+	return matched(result, err)
+}
+
+// MemoryKycRepository keeps KYC records in process memory.
+type MemoryKycRepository struct {
+	mu   sync.Mutex
+	kycs []*models.KYC
+}
+
+func NewMemoryKycRepository() *MemoryKycRepository {
+	return &MemoryKycRepository{}
+}
+
+func (r *MemoryKycRepository) find(userID string) *models.KYC {
+	for _, kyc := range r.kycs {
+		if kyc.User_ID == userID {
+			return kyc
+		}
+	}
+	return nil
+}
+
+// ensure returns the user's record, creating it if needed.
+func (r *MemoryKycRepository) ensure(userID string) *models.KYC {
+	if kyc := r.find(userID); kyc != nil {
+		return kyc
+	}
+	kyc := &models.KYC{
+		ID:         primitive.NewObjectID(),
+		User_ID:    userID,
+		Status:     kycStatusOngoing,
+		Created_At: time.Now(),
+	}
+	r.kycs = append(r.kycs, kyc)
+	return kyc
+}
+
+func (r *MemoryKycRepository) FindByUserID(ctx context.Context, userID string) (*models.KYC, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if kyc := r.find(userID); kyc != nil {
+		return clone(kyc), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryKycRepository) ListByStatus(ctx context.Context, status string, limit, skip int64) ([]*models.KYC, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var kycs []*models.KYC
+	for _, kyc := range r.kycs {
+		if kyc.Status == status {
+			kycs = append(kycs, clone(kyc))
+		}
+	}
+	sort.SliceStable(kycs, func(i, j int) bool {
+		return kycs[i].Submitted_At.Before(kycs[j].Submitted_At)
+	})
+	if skip >= int64(len(kycs)) {
+		return nil, nil
+	}
+	kycs = kycs[skip:]
+	if limit > 0 && limit < int64(len(kycs)) {
+		kycs = kycs[:limit]
+	}
+	return kycs, nil
+}
+
+func (r *MemoryKycRepository) Ensure(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ensure(userID)
+	return nil
+}
+
+func (r *MemoryKycRepository) RaiseTier(ctx context.Context, userID string, tier int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kyc := r.ensure(userID)
+	if tier > kyc.Tier {
+		kyc.Tier = tier
+	}
+	kyc.Updated_At = time.Now()
+	return nil
+}
+
+func (r *MemoryKycRepository) LowerTier(ctx context.Context, userID string, tier int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kyc := r.find(userID)
+	if kyc == nil {
+		return nil
+	}
+	if tier < kyc.Tier {
+		kyc.Tier = tier
+	}
+	kyc.Updated_At = time.Now()
+	return nil
+}
+
+func (r *MemoryKycRepository) AddIdentityCheck(ctx context.Context, userID string, check models.IdentityCheck) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kyc := r.ensure(userID)
+	kyc.Identity_Checks = append(kyc.Identity_Checks, *clone(&check))
+	return nil
+}
+
+func (r *MemoryKycRepository) Transition(ctx context.Context, userID string, from []string, change KycChange) (*models.KYC, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kyc := r.find(userID)
+	if kyc == nil || !contains(from, kyc.Status) {
+		return nil, ErrNotFound
+	}
+	kyc.Status = change.Status
+	kyc.Rejection_Reason = change.Rejection_Reason
+	kyc.Updated_At = time.Now()
+	if change.Tier != nil {
+		kyc.Tier = *change.Tier
+	}
+	setString(&kyc.Kyc_Docs, change.Kyc_Docs)
+	if change.Document_Type != nil {
+		kyc.Document_Type = *change.Document_Type
+	}
+	if change.Submitted_At != nil {
+		kyc.Submitted_At = *change.Submitted_At
+	}
+	kyc.Review_History = append(kyc.Review_History, *clone(&change.Review))
+	return clone(kyc), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MemoryKycRepository) SetFaceMatch(ctx context.Context, userID string, score float64, matched bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if kyc := r.find(userID); kyc != nil {
+		kyc.Face_Match_Score = score
+		kyc.Face_Matched = matched
+		kyc.Face_Matched_At = time.Now()
+	}
+	return nil
+}
+
+func (r *MemoryKycRepository) SetSelfie(ctx context.Context, userID, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kyc := r.find(userID)
+	if kyc == nil {
+		return ErrNotFound
+	}
+	kyc.Kyc_Image = &key
+	kyc.Face_Matched = false
+	return nil
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+)
+
+func TestKycRaiseTier(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiers []int
+		want  int
+	}{
+		{"creates the record", []int{1}, 1},
+		{"raises", []int{1, 3}, 3},
+		{"never lowers", []int{3, 1}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			each(t, func(t *testing.T, repos *Repositories) {
+				ctx := context.Background()
+				for _, tier := range tt.tiers {
+					if err := repos.Kycs.RaiseTier(ctx, "u1", tier); err != nil {
+						t.Fatal(err)
+					}
+				}
+				kyc, err := repos.Kycs.FindByUserID(ctx, "u1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if kyc.Tier != tt.want || kyc.Status != kycStatusOngoing {
+					t.Fatalf("record = tier %d %s, want tier %d %s", kyc.Tier, kyc.Status, tt.want, kycStatusOngoing)
+				}
+			})
+		})
+	}
+}
+
+func TestKycTransition(t *testing.T) {
+	submit := func(documents string) KycChange {
+		now := time.Now()
+		return KycChange{
+			Status:       "pending_review",
+			Kyc_Docs:     &documents,
+			Submitted_At: &now,
+			Review:       models.KycReview{Action: "submitted", Status: "pending_review"},
+		}
+	}
+	submittable := []string{"ongoing", "rejected"}
+
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		if _, err := repos.Kycs.Transition(ctx, "u1", submittable, submit("doc")); err != ErrNotFound {
+			t.Fatalf("Transition without a record = %v, want ErrNotFound", err)
+		}
+		if err := repos.Kycs.Ensure(ctx, "u1"); err != nil {
+			t.Fatal(err)
+		}
+		kyc, err := repos.Kycs.Transition(ctx, "u1", submittable, submit("doc"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kyc.Status != "pending_review" || *kyc.Kyc_Docs != "doc" || len(kyc.Review_History) != 1 {
+			t.Fatalf("after submitting: %+v", kyc)
+		}
+		if _, err := repos.Kycs.Transition(ctx, "u1", submittable, submit("other")); err != ErrNotFound {
+			t.Fatalf("second submission = %v, want ErrNotFound", err)
+		}
+
+		tier := 3
+		kyc, err = repos.Kycs.Transition(ctx, "u1", []string{"pending_review"}, KycChange{
+			Status: "approved",
+			Tier:   &tier,
+			Review: models.KycReview{Action: "approved", Status: "approved", New_Tier: tier},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kyc.Status != "approved" || kyc.Tier != 3 || *kyc.Kyc_Docs != "doc" || len(kyc.Review_History) != 2 {
+			t.Fatalf("after approving: %+v", kyc)
+		}
+	})
+}
+
+func TestKycListByStatus(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		start := time.Now()
+		// Submitted in the order u3, u1, u2.
+		for i, userID := range []string{"u3", "u1", "u2"} {
+			if err := repos.Kycs.Ensure(ctx, userID); err != nil {
+				t.Fatal(err)
+			}
+			submitted := start.Add(time.Duration(i) * time.Second)
+			if _, err := repos.Kycs.Transition(ctx, userID, []string{"ongoing"}, KycChange{Status: "pending_review", Submitted_At: &submitted}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := repos.Kycs.Ensure(ctx, "u4"); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			limit, skip int64
+			want        []string
+		}{
+			{10, 0, []string{"u3", "u1", "u2"}},
+			{2, 0, []string{"u3", "u1"}},
+			{2, 2, []string{"u2"}},
+			{2, 3, nil},
+		}
+		for _, tt := range tests {
+			kycs, err := repos.Kycs.ListByStatus(ctx, "pending_review", tt.limit, tt.skip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, kyc := range kycs {
+				got = append(got, kyc.User_ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("ListByStatus(%d, %d) = %v, want %v", tt.limit, tt.skip, got, tt.want)
+				continue
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ListByStatus(%d, %d) = %v, want %v", tt.limit, tt.skip, got, tt.want)
+					break
+				}
+			}
+		}
+	})
+}
+
+func TestKycSelfieAndChecks(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		if err := repos.Kycs.SetSelfie(ctx, "u1", "selfie"); err != ErrNotFound {
+			t.Fatalf("SetSelfie without a record = %v, want ErrNotFound", err)
+		}
+		if err := repos.Kycs.AddIdentityCheck(ctx, "u1", models.IdentityCheck{Type: "bvn", Matched: true}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Kycs.SetFaceMatch(ctx, "u1", 0.9, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Kycs.SetSelfie(ctx, "u1", "selfie"); err != nil {
+			t.Fatal(err)
+		}
+		kyc, err := repos.Kycs.FindByUserID(ctx, "u1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(kyc.Identity_Checks) != 1 || *kyc.Kyc_Image != "selfie" || kyc.Face_Matched || kyc.Face_Match_Score != 0.9 {
+			t.Fatalf("record = %+v", kyc)
+		}
+	})
+}
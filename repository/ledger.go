@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LedgerRepository stores the balance ledger. An entry is unique per
+// reference and kind; a second one fails with ErrDuplicate.
+type LedgerRepository interface {
+	Create(context.Context, *models.LedgerEntry) error
+	ListByUserID(ctx context.Context, userID string, limit int64) ([]*models.LedgerEntry, error)
+}
+
+type MongoLedgerRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLedgerRepository(collection *mongo.Collection) *MongoLedgerRepository {
+	return &MongoLedgerRepository{
+		collection: collection,
+	}
+}
+
+func (r *MongoLedgerRepository) Create(ctx context.Context, entry *models.LedgerEntry) error {
+	_, err := r.collection.InsertOne(ctx, entry)
+	return duplicate(err)
+}
+
+// ListByUserID lists the user's newest entries first. A zero limit lists
+// them all.
+func (r *MongoLedgerRepository) ListByUserID(ctx context.Context, userID string, limit int64) ([]*models.LedgerEntry, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.LedgerEntry](ctx, cursor)
+}
+
+// MemoryLedgerRepository keeps the ledger in process memory.
+type MemoryLedgerRepository struct {
+	mu      sync.Mutex
+	entries []*models.LedgerEntry
+}
+
+func NewMemoryLedgerRepository() *MemoryLedgerRepository {
+	return &MemoryLedgerRepository{}
+}
+
+func (r *MemoryLedgerRepository) Create(ctx context.Context, entry *models.LedgerEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.entries {
+		if other.ID == entry.ID || (other.Reference == entry.Reference && other.Kind == entry.Kind) {
+			return ErrDuplicate
+		}
+	}
+	r.entries = append(r.entries, clone(entry))
+	return nil
+}
+
+func (r *MemoryLedgerRepository) ListByUserID(ctx context.Context, userID string, limit int64) ([]*models.LedgerEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []*models.LedgerEntry
+	for _, entry := range r.entries {
+		if entry.User_ID == userID {
+			entries = append(entries, clone(entry))
+		}
+	}
+	return newest(entries, func(entry *models.LedgerEntry) time.Time { return entry.Created_At }, limit), nil
+}
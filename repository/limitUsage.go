@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// LimitUsageRepository stores the inflows and payouts the daily and
+// monthly limits are measured against.
+type LimitUsageRepository interface {
+	Create(context.Context, *models.LimitUsage) error
+	// Sum totals the user's usage of kind since the given time.
+	Sum(ctx context.Context, userID, kind string, since time.Time) (int, error)
+	DeleteByReference(ctx context.Context, reference string) error
+}
+
+type MongoLimitUsageRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLimitUsageRepository(collection *mongo.Collection) *MongoLimitUsageRepository {
+	return &MongoLimitUsageRepository{
+		collection: collection,
+	}
+}
+
+func (r *MongoLimitUsageRepository) Create(ctx context.Context, usage *models.LimitUsage) error {
+	_, err := r.collection.InsertOne(ctx, usage)
+	return duplicate(err)
+}
+
+func (r *MongoLimitUsageRepository) Sum(ctx context.Context, userID, kind string, since time.Time) (int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID, "kind": kind, "created_at": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+	var result struct {
+		Total int `bson:"total"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return 0, err
+		}
+	}
+	return result.Total, cursor.Err()
+}
+
+func (r *MongoLimitUsageRepository) DeleteByReference(ctx context.Context, reference string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"reference": reference})
+	return err
+}
+
+// MemoryLimitUsageRepository keeps limit usage in process memory.
+type MemoryLimitUsageRepository struct {
+	mu     sync.Mutex
+	usages []*models.LimitUsage
+}
+
+func NewMemoryLimitUsageRepository() *MemoryLimitUsageRepository {
+	return &MemoryLimitUsageRepository{}
+}
+
+func (r *MemoryLimitUsageRepository) Create(ctx context.Context, usage *models.LimitUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.usages {
+		if other.ID == usage.ID {
+			return ErrDuplicate
+		}
+	}
+	r.usages = append(r.usages, clone(usage))
+	return nil
+}
+
+func (r *MemoryLimitUsageRepository) Sum(ctx context.Context, userID, kind string, since time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := 0
+	for _, usage := range r.usages {
+		if usage.User_ID == userID && usage.Kind == kind && !usage.Created_At.Before(since) {
+			total += usage.Amount
+		}
+	}
+	return total, nil
+}
+
+func (r *MemoryLimitUsageRepository) DeleteByReference(ctx context.Context, reference string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.usages[:0]
+	for _, usage := range r.usages {
+		if usage.Reference != reference {
+			kept = append(kept, usage)
+		}
+	}
+	r.usages = kept
+	return nil
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OtpRepository stores at most one code per user and purpose.
+type OtpRepository interface {
+	Replace(context.Context, *models.Otp, time.Time) error
+	Find(context.Context, string, string) (*models.Otp, error)
+	Attempt(context.Context, string, string, int) (*models.Otp, error)
+	Delete(context.Context, *models.Otp) (bool, error)
+}
+
+type MongoOtpRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoOtpRepository(collection *mongo.Collection) *MongoOtpRepository {
+	return &MongoOtpRepository{
+		collection: collection,
+	}
+}
+
+// Replace stores otp in place of the user's code for the same purpose. It
+// fails with ErrDuplicate, and changes nothing, when that code was sent
+// after sentBefore.
+func (r *MongoOtpRepository) Replace(ctx context.Context, otp *models.Otp, sentBefore time.Time) error {
+	// The filter only matches a code sent before sentBefore. If a newer
+	// one exists the upsert collides with the unique index.
+	filter := bson.M{
+		"user_id":      otp.User_ID,
+		"purpose":      otp.Purpose,
+		"last_sent_at": bson.M{"$lte": sentBefore},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"code_hash":    otp.Code_Hash,
+			"attempts":     otp.Attempts,
+			"expires_at":   otp.Expires_At,
+			"last_sent_at": otp.Last_Sent_At,
+			"created_at":   otp.Created_At,
+		},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+	}
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return duplicate(err)
+}
+
+func (r *MongoOtpRepository) Find(ctx context.Context, userID, purpose string) (*models.Otp, error) {
+	var otp *models.Otp
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID, "purpose": purpose}).Decode(&otp)
+	return otp, err
+}
+
+// Attempt counts a verification attempt against the user's code and
+// returns the code as it was before. It fails with ErrNotFound when there
+// is no code, or it has had maxAttempts attempts already.
+func (r *MongoOtpRepository) Attempt(ctx context.Context, userID, purpose string, maxAttempts int) (*models.Otp, error) {
+	var otp *models.Otp
+	filter := bson.M{
+		"user_id":  userID,
+		"purpose":  purpose,
+		"attempts": bson.M{"$lt": maxAttempts},
+	}
+	err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"attempts": 1}}).Decode(&otp)
+	return otp, err
+}
+
+// Delete removes otp if it has not been replaced, and reports whether it
+// did.
+func (r *MongoOtpRepository) Delete(ctx context.Context, otp *models.Otp) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": otp.ID, "code_hash": otp.Code_Hash})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount == 1, nil
+}
+
+// MemoryOtpRepository keeps codes in process memory. Expired codes are
+// kept until replaced, as Mongo's TTL monitor can lag too.
+type MemoryOtpRepository struct {
+	mu   sync.Mutex
+	otps []*models.Otp
+}
+
+func NewMemoryOtpRepository() *MemoryOtpRepository {
+	return &MemoryOtpRepository{}
+}
+
+func (r *MemoryOtpRepository) find(userID, purpose string) int {
+	for i, otp := range r.otps {
+		if otp.User_ID == userID && otp.Purpose == purpose {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *MemoryOtpRepository) Replace(ctx context.Context, otp *models.Otp, sentBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := clone(otp)
+	i := r.find(otp.User_ID, otp.Purpose)
+	if i < 0 {
+		stored.ID = primitive.NewObjectID()
+		r.otps = append(r.otps, stored)
+		return nil
+	}
+	if r.otps[i].Last_Sent_At.After(sentBefore) {
+		return ErrDuplicate
+	}
+	stored.ID = r.otps[i].ID
+	r.otps[i] = stored
+	return nil
+}
+
+func (r *MemoryOtpRepository) Find(ctx context.Context, userID, purpose string) (*models.Otp, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.find(userID, purpose); i >= 0 {
+		return clone(r.otps[i]), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryOtpRepository) Attempt(ctx context.Context, userID, purpose string, maxAttempts int) (*models.Otp, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.find(userID, purpose)
+	if i < 0 || r.otps[i].Attempts >= maxAttempts {
+		return nil, ErrNotFound
+	}
+	before := clone(r.otps[i])
+	r.otps[i].Attempts++
+	return before, nil
+}
+
+func (r *MemoryOtpRepository) Delete(ctx context.Context, otp *models.Otp) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, stored := range r.otps {
+		if stored.ID == otp.ID && stored.Code_Hash == otp.Code_Hash {
+			r.otps = append(r.otps[:i], r.otps[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+)
+
+func newOtp(hash string, sentAt time.Time) *models.Otp {
+	return &models.Otp{
+		User_ID:      "u1",
+		Purpose:      "login",
+		Code_Hash:    hash,
+		Expires_At:   sentAt.Add(10 * time.Minute),
+		Last_Sent_At: sentAt,
+		Created_At:   sentAt,
+	}
+}
+
+func TestOtpReplace(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		previous time.Time
+		want     error
+		wantHash string
+	}{
+		{"after the cooldown", now.Add(-2 * time.Minute), nil, "new"},
+		{"within the cooldown", now.Add(-30 * time.Second), ErrDuplicate, "old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			each(t, func(t *testing.T, repos *Repositories) {
+				ctx := context.Background()
+				if err := repos.Otps.Replace(ctx, newOtp("old", tt.previous), now.Add(-time.Minute)); err != nil {
+					t.Fatal(err)
+				}
+				if err := repos.Otps.Replace(ctx, newOtp("new", now), now.Add(-time.Minute)); !errors.Is(err, tt.want) {
+					t.Fatalf("Replace = %v, want %v", err, tt.want)
+				}
+				otp, err := repos.Otps.Find(ctx, "u1", "login")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if otp.Code_Hash != tt.wantHash {
+					t.Fatalf("Code_Hash = %s, want %s", otp.Code_Hash, tt.wantHash)
+				}
+			})
+		})
+	}
+}
+
+func TestOtpAttemptAndDelete(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		now := time.Now()
+		if _, err := repos.Otps.Attempt(ctx, "u1", "login", 3); err != ErrNotFound {
+			t.Fatalf("Attempt without a code = %v, want ErrNotFound", err)
+		}
+		if err := repos.Otps.Replace(ctx, newOtp("hash", now), now.Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		for attempt := 0; attempt < 3; attempt++ {
+			otp, err := repos.Otps.Attempt(ctx, "u1", "login", 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if otp.Attempts != attempt {
+				t.Fatalf("Attempt returned %d attempts, want %d", otp.Attempts, attempt)
+			}
+		}
+		if _, err := repos.Otps.Attempt(ctx, "u1", "login", 3); err != ErrNotFound {
+			t.Fatalf("fourth Attempt = %v, want ErrNotFound", err)
+		}
+
+		otp, err := repos.Otps.Find(ctx, "u1", "login")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stale := *otp
+		stale.Code_Hash = "replaced"
+		if deleted, err := repos.Otps.Delete(ctx, &stale); err != nil || deleted {
+			t.Fatalf("Delete of a replaced code = %v, %v; want false", deleted, err)
+		}
+		if deleted, err := repos.Otps.Delete(ctx, otp); err != nil || !deleted {
+			t.Fatalf("Delete = %v, %v; want true", deleted, err)
+		}
+		if deleted, err := repos.Otps.Delete(ctx, otp); err != nil || deleted {
+			t.Fatalf("second Delete = %v, %v; want false", deleted, err)
+		}
+	})
+}
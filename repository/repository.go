@@ -0,0 +1,146 @@
+// Package repository stores the platform's aggregates. Every repository
+// has a Mongo implementation and an in-memory one for tests. The in-memory
+// ones are safe for concurrent use and enforce the same unique indexes the
+// migrations create, so they fail the same way Mongo would.
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrNotFound is mongo.ErrNoDocuments, so callers that already check
+	// for that keep working with either implementation.
+	ErrNotFound = mongo.ErrNoDocuments
+	// ErrDuplicate is a write that would break a unique index.
+	ErrDuplicate = errors.New("duplicate key")
+
+	ErrEmailTaken    = errors.New("email is already in use")
+	ErrPhoneTaken    = errors.New("phone number is already in use")
+	ErrUsernameTaken = errors.New("username is already in use")
+	// ErrInsufficientBalance is a debit larger than the balance.
+	ErrInsufficientBalance = errors.New("you cannot withdraw an amount greater than your balance")
+)
+
+// Repositories holds one repository per aggregate.
+type Repositories struct {
+	Users        UserRepository
+	Transactions TransactionRepository
+	Banks        BankRepository
+	Kycs         KycRepository
+	Otps         OtpRepository
+	Socials      SocialRepository
+	Donations    DonationRepository
+
+	Ledger         LedgerRepository
+	LimitUsage     LimitUsageRepository
+	Audit          AuditRepository
+	LoginAttempts  LoginAttemptRepository
+	SecurityEvents SecurityEventRepository
+}
+
+// NewMongo returns repositories backed by the collections in db.
+func NewMongo(db *mongo.Database) *Repositories {
+	return &Repositories{
+		Users:        NewMongoUserRepository(db.Collection("Users")),
+		Transactions: NewMongoTransactionRepository(db.Collection("Transactions")),
+		Banks:        NewMongoBankRepository(db.Collection("Banks")),
+		Kycs:         NewMongoKycRepository(db.Collection("Kycs")),
+		Otps:         NewMongoOtpRepository(db.Collection("Otps")),
+		Socials:      NewMongoSocialRepository(db.Collection("Socials")),
+		Donations:    NewMongoDonationRepository(db.Collection("Donations")),
+
+		Ledger:         NewMongoLedgerRepository(db.Collection("Ledger")),
+		LimitUsage:     NewMongoLimitUsageRepository(db.Collection("LimitUsage")),
+		Audit:          NewMongoAuditRepository(db.Collection("AuditLog")),
+		LoginAttempts:  NewMongoLoginAttemptRepository(db.Collection("LoginAttempts")),
+		SecurityEvents: NewMongoSecurityEventRepository(db.Collection("SecurityEvents")),
+	}
+}
+
+// NewMemory returns empty in-memory repositories.
+func NewMemory() *Repositories {
+	return &Repositories{
+		Users:        NewMemoryUserRepository(),
+		Transactions: NewMemoryTransactionRepository(),
+		Banks:        NewMemoryBankRepository(),
+		Kycs:         NewMemoryKycRepository(),
+		Otps:         NewMemoryOtpRepository(),
+		Socials:      NewMemorySocialRepository(),
+		Donations:    NewMemoryDonationRepository(),
+
+		Ledger:         NewMemoryLedgerRepository(),
+		LimitUsage:     NewMemoryLimitUsageRepository(),
+		Audit:          NewMemoryAuditRepository(),
+		LoginAttempts:  NewMemoryLoginAttemptRepository(),
+		SecurityEvents: NewMemorySecurityEventRepository(),
+	}
+}
+
+// clone copies v by a round trip through BSON, so what the in-memory
+// repositories store and return matches what Mongo would, down to
+// millisecond timestamps. The models always marshal, so a failure is a
+// programming error.
+func clone[T any](v *T) *T {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("repository: marshalling %T: %v", v, err))
+	}
+	var out T
+	if err := bson.Unmarshal(data, &out); err != nil {
+		panic(fmt.Sprintf("repository: unmarshalling %T: %v", v, err))
+	}
+	return &out
+}
+
+// duplicate turns a unique index violation into ErrDuplicate.
+func duplicate(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: %v", ErrDuplicate, err)
+	}
+	return err
+}
+
+// decodeAll reads every document from cursor.
+func decodeAll[T any](ctx context.Context, cursor *mongo.Cursor) ([]*T, error) {
+	defer cursor.Close(ctx)
+	var items []*T
+	for cursor.Next(ctx) {
+		var item *T
+		if err := cursor.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, cursor.Err()
+}
+
+// newest sorts items newest first and keeps at most limit of them, or all
+// of them when limit is zero.
+func newest[T any](items []*T, createdAt func(*T) time.Time, limit int64) []*T {
+	sort.SliceStable(items, func(i, j int) bool {
+		return createdAt(items[i]).After(createdAt(items[j]))
+	})
+	if limit > 0 && int64(len(items)) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// matched reports an update that matched nothing as ErrNotFound.
+func matched(result *mongo.UpdateResult, err error) error {
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/JayJosh846/donationPlatform/migrations"
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	mongoOnce   sync.Once
+	mongoClient *mongo.Client
+	mongoErr    error
+)
+
+// each runs test against the in-memory repositories and, when
+// MONGO_TEST_URI is set, against Mongo with the migrations applied, so
+// both implementations are held to the same behaviour.
+func each(t *testing.T, test func(t *testing.T, repos *Repositories)) {
+	t.Helper()
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemory())
+	})
+	t.Run("mongo", func(t *testing.T) {
+		uri := os.Getenv("MONGO_TEST_URI")
+		if uri == "" {
+			t.Skip("MONGO_TEST_URI is not set")
+		}
+		ctx := context.Background()
+		mongoOnce.Do(func() {
+			mongoClient, mongoErr = mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		})
+		if mongoErr != nil {
+			t.Fatal(mongoErr)
+		}
+		db := mongoClient.Database("repository_test_" + uuid.NewString()[:8])
+		t.Cleanup(func() { db.Drop(ctx) })
+		if _, err := migrations.New(db).Up(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+		test(t, NewMongo(db))
+	})
+}
+
+func TestBanksSocialsAndDonations(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		number := "0123456789"
+		if err := repos.Banks.Create(ctx, &models.Bank{ID: primitive.NewObjectID(), User_ID: "u1", Account_Number: &number}); err != nil {
+			t.Fatal(err)
+		}
+		handle := "@u1"
+		if err := repos.Socials.Create(ctx, &models.Social{ID: primitive.NewObjectID(), User_ID: "u1", Twitter: &handle}); err != nil {
+			t.Fatal(err)
+		}
+		for _, amount := range []string{"100", "200"} {
+			if err := repos.Donations.Create(ctx, &models.Donation{ID: primitive.NewObjectID(), User_ID: "u1", Amount: amount}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tests := []struct {
+			userID    string
+			hasBank   bool
+			hasSocial bool
+			donations int
+		}{
+			{userID: "u1", hasBank: true, hasSocial: true, donations: 2},
+			{userID: "u2"},
+		}
+		for _, tt := range tests {
+			if got, err := repos.Banks.Exists(ctx, tt.userID); err != nil || got != tt.hasBank {
+				t.Errorf("Banks.Exists(%s) = %v, %v; want %v", tt.userID, got, err, tt.hasBank)
+			}
+			if got, err := repos.Socials.Exists(ctx, tt.userID); err != nil || got != tt.hasSocial {
+				t.Errorf("Socials.Exists(%s) = %v, %v; want %v", tt.userID, got, err, tt.hasSocial)
+			}
+			if got, err := repos.Donations.ListByUserID(ctx, tt.userID); err != nil || len(got) != tt.donations {
+				t.Errorf("Donations.ListByUserID(%s) = %d donations, %v; want %d", tt.userID, len(got), err, tt.donations)
+			}
+		}
+
+		bank, err := repos.Banks.FindByUserID(ctx, "u1")
+		if err != nil || *bank.Account_Number != number {
+			t.Errorf("Banks.FindByUserID = %+v, %v", bank, err)
+		}
+		if _, err := repos.Socials.FindByUserID(ctx, "u2"); err != ErrNotFound {
+			t.Errorf("Socials.FindByUserID of a missing user = %v, want ErrNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginAttemptRepository stores the login attempt counters, one per key.
+type LoginAttemptRepository interface {
+	Find(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// CountAttempt counts an attempt at now in one atomic update and returns
+	// the counter as it was just before, a zero counter when there was none.
+	// A counter with no attempt since windowStart that is not locked starts
+	// again from zero. It leaves the last failure alone.
+	CountAttempt(ctx context.Context, key string, now, windowStart time.Time) (*models.LoginAttempt, error)
+	// Uncount takes back an attempt counted by CountAttempt.
+	Uncount(ctx context.Context, key string) error
+	// RecordFailure sets the last failure to now.
+	RecordFailure(ctx context.Context, key string, now time.Time) error
+	// ClaimFailure moves the last failure from last to now and reports
+	// whether it was still last, so of several attempts let through by the
+	// same back-off only one goes ahead.
+	ClaimFailure(ctx context.Context, key string, last, now time.Time) (bool, error)
+	// Lock locks the key until the given time when it has at least threshold
+	// failures, clears them and counts the lockout. It reports whether it
+	// did, so of several concurrent failures only one locks the key.
+	Lock(ctx context.Context, key string, threshold int, until time.Time) (bool, error)
+	// Delete reports whether there was a counter to delete.
+	Delete(ctx context.Context, key string) (bool, error)
+}
+
+// SecurityEventRepository stores the security event log.
+type SecurityEventRepository interface {
+	Create(context.Context, *models.SecurityEvent) error
+	// List returns the newest events first, only the user's when userID is
+	// set. A zero limit lists them all.
+	List(ctx context.Context, userID string, limit int64) ([]*models.SecurityEvent, error)
+}
+
+type MongoLoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLoginAttemptRepository(collection *mongo.Collection) *MongoLoginAttemptRepository {
+	return &MongoLoginAttemptRepository{
+		collection: collection,
+	}
+}
+
+func (r *MongoLoginAttemptRepository) Find(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	var attempt *models.LoginAttempt
+	err := r.collection.FindOne(ctx, bson.M{"key": key}).Decode(&attempt)
+	return attempt, err
+}
+
+func (r *MongoLoginAttemptRepository) CountAttempt(ctx context.Context, key string, now, windowStart time.Time) (*models.LoginAttempt, error) {
+	_, err := r.collection.UpdateOne(ctx,
+		// $not also matches counters from before last_attempt was kept.
+		bson.M{"key": key, "last_attempt": bson.M{"$not": bson.M{"$gte": windowStart}}, "locked_until": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"failures": 0}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var attempt *models.LoginAttempt
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{"key": key},
+		bson.M{
+			"$inc":         bson.M{"failures": 1},
+			"$set":         bson.M{"last_attempt": now},
+			"$setOnInsert": bson.M{"lockouts": 0, "last_failure": time.Time{}, "locked_until": time.Time{}},
+		},
+		opts,
+	).Decode(&attempt)
+	if err == mongo.ErrNoDocuments {
+		return &models.LoginAttempt{Key: key}, nil
+	}
+	return attempt, duplicate(err)
+}
+
+func (r *MongoLoginAttemptRepository) Uncount(ctx context.Context, key string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"key": key, "failures": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"failures": -1}},
+	)
+	return err
+}
+
+func (r *MongoLoginAttemptRepository) RecordFailure(ctx context.Context, key string, now time.Time) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"key": key},
+		bson.M{"$set": bson.M{"last_failure": now}},
+	)
+	return err
+}
+
+func (r *MongoLoginAttemptRepository) ClaimFailure(ctx context.Context, key string, last, now time.Time) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"key": key, "last_failure": last},
+		bson.M{"$set": bson.M{"last_failure": now}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *MongoLoginAttemptRepository) Lock(ctx context.Context, key string, threshold int, until time.Time) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"key": key, "failures": bson.M{"$gte": threshold}},
+		bson.M{
+			"$set": bson.M{"failures": 0, "locked_until": until},
+			"$inc": bson.M{"lockouts": 1},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *MongoLoginAttemptRepository) Delete(ctx context.Context, key string) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"key": key})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount == 1, nil
+}
+
+type MongoSecurityEventRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoSecurityEventRepository(collection *mongo.Collection) *MongoSecurityEventRepository {
+	return &MongoSecurityEventRepository{
+		collection: collection,
+	}
+}
+
+func (r *MongoSecurityEventRepository) Create(ctx context.Context, event *models.SecurityEvent) error {
+	_, err := r.collection.InsertOne(ctx, event)
+	return duplicate(err)
+}
+
+func (r *MongoSecurityEventRepository) List(ctx context.Context, userID string, limit int64) ([]*models.SecurityEvent, error) {
+	query := bson.M{}
+	if userID != "" {
+		query["user_id"] = userID
+	}
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.SecurityEvent](ctx, cursor)
+}
+
+// MemoryLoginAttemptRepository keeps login counters in process memory.
+type MemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]*models.LoginAttempt
+}
+
+func NewMemoryLoginAttemptRepository() *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{attempts: make(map[string]*models.LoginAttempt)}
+}
+
+func (r *MemoryLoginAttemptRepository) Find(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, ok := r.attempts[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(attempt), nil
+}
+
+func (r *MemoryLoginAttemptRepository) CountAttempt(ctx context.Context, key string, now, windowStart time.Time) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, ok := r.attempts[key]
+	if !ok {
+		attempt = &models.LoginAttempt{Key: key}
+		r.attempts[key] = attempt
+	}
+	if attempt.Last_Attempt.Before(windowStart) && attempt.Locked_Until.Before(now) {
+		attempt.Failures = 0
+	}
+	before := clone(attempt)
+	attempt.Failures++
+	attempt.Last_Attempt = now
+	return before, nil
+}
+
+func (r *MemoryLoginAttemptRepository) Uncount(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if attempt, ok := r.attempts[key]; ok && attempt.Failures > 0 {
+		attempt.Failures--
+	}
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) RecordFailure(ctx context.Context, key string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if attempt, ok := r.attempts[key]; ok {
+		attempt.Last_Failure = bsonTime(now)
+	}
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) ClaimFailure(ctx context.Context, key string, last, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, ok := r.attempts[key]
+	if !ok || !attempt.Last_Failure.Equal(bsonTime(last)) {
+		return false, nil
+	}
+	attempt.Last_Failure = bsonTime(now)
+	return true, nil
+}
+
+// bsonTime truncates t to the milliseconds MongoDB keeps, so the times
+// ClaimFailure compares match as they would there.
+func bsonTime(t time.Time) time.Time {
+	return t.Truncate(time.Millisecond)
+}
+
+func (r *MemoryLoginAttemptRepository) Lock(ctx context.Context, key string, threshold int, until time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, ok := r.attempts[key]
+	if !ok || attempt.Failures < threshold {
+		return false, nil
+	}
+	attempt.Failures = 0
+	attempt.Locked_Until = until
+	attempt.Lockouts++
+	return true, nil
+}
+
+func (r *MemoryLoginAttemptRepository) Delete(ctx context.Context, key string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.attempts[key]
+	delete(r.attempts, key)
+	return ok, nil
+}
+
+// MemorySecurityEventRepository keeps security events in process memory.
+type MemorySecurityEventRepository struct {
+	mu     sync.Mutex
+	events []*models.SecurityEvent
+}
+
+func NewMemorySecurityEventRepository() *MemorySecurityEventRepository {
+	return &MemorySecurityEventRepository{}
+}
+
+func (r *MemorySecurityEventRepository) Create(ctx context.Context, event *models.SecurityEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.events {
+		if other.ID == event.ID {
+			return ErrDuplicate
+		}
+	}
+	r.events = append(r.events, clone(event))
+	return nil
+}
+
+func (r *MemorySecurityEventRepository) List(ctx context.Context, userID string, limit int64) ([]*models.SecurityEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []*models.SecurityEvent
+	for _, event := range r.events {
+		if userID == "" || event.User_ID == userID {
+			events = append(events, clone(event))
+		}
+	}
+	return newest(events, func(event *models.SecurityEvent) time.Time { return event.Created_At }, limit), nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SocialRepository stores users' social links.
+type SocialRepository interface {
+	Create(context.Context, *models.Social) error
+	FindByUserID(context.Context, string) (*models.Social, error)
+	Exists(context.Context, string) (bool, error)
+}
+
+type MongoSocialRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoSocialRepository(collection *mongo.Collection) *MongoSocialRepository {
+	return &MongoSocialRepository{
+		collection: collection,
+	}
+}
+
+func (r *MongoSocialRepository) Create(ctx context.Context, social *models.Social) error {
+	_, err := r.collection.InsertOne(ctx, social)
+	return duplicate(err)
+}
+
+func (r *MongoSocialRepository) FindByUserID(ctx context.Context, userID string) (*models.Social, error) {
+	var social *models.Social
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&social)
+	return social, err
+}
+
+func (r *MongoSocialRepository) Exists(ctx context.Context, userID string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"user_id": userID})
+	return count > 0, err
+}
+
+// MemorySocialRepository keeps social links in process memory.
+type MemorySocialRepository struct {
+	mu      sync.Mutex
+	socials []*models.Social
+}
+
+func NewMemorySocialRepository() *MemorySocialRepository {
+	return &MemorySocialRepository{}
+}
+
+func (r *MemorySocialRepository) Create(ctx context.Context, social *models.Social) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := clone(social)
+	for _, other := range r.socials {
+		if other.ID == stored.ID {
+			return ErrDuplicate
+		}
+	}
+	r.socials = append(r.socials, stored)
+	return nil
+}
+
+// FindByUserID returns the user's first social, as FindOne would.
+func (r *MemorySocialRepository) FindByUserID(ctx context.Context, userID string) (*models.Social, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, social := range r.socials {
+		if social.User_ID == userID {
+			return clone(social), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemorySocialRepository) Exists(ctx context.Context, userID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, social := range r.socials {
+		if social.User_ID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
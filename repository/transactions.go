@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TransactionFilter selects transactions. Empty fields match every
+// transaction.
+type TransactionFilter struct {
+	User_ID string
+	Status  string
+}
+
+// TransactionRepository stores transactions. References are unique.
+type TransactionRepository interface {
+	Create(context.Context, *models.Transaction) error
+	FindByID(context.Context, primitive.ObjectID) (*models.Transaction, error)
+	FindByReference(context.Context, string) (*models.Transaction, error)
+	List(context.Context, TransactionFilter) ([]*models.Transaction, error)
+	Count(context.Context, TransactionFilter) (int64, error)
+	Transition(context.Context, string, string, string, string) (*models.Transaction, error)
+}
+
+type MongoTransactionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoTransactionRepository(collection *mongo.Collection) *MongoTransactionRepository {
+	return &MongoTransactionRepository{
+		collection: collection,
+	}
+}
+
+func (r *MongoTransactionRepository) Create(ctx context.Context, transaction *models.Transaction) error {
+	_, err := r.collection.InsertOne(ctx, transaction)
+	return duplicate(err)
+}
+
+func (r *MongoTransactionRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&transaction)
+	return transaction, err
+}
+
+func (r *MongoTransactionRepository) FindByReference(ctx context.Context, reference string) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := r.collection.FindOne(ctx, bson.M{"reference": reference}).Decode(&transaction)
+	return transaction, err
+}
+
+func (r *MongoTransactionRepository) List(ctx context.Context, filter TransactionFilter) ([]*models.Transaction, error) {
+	cursor, err := r.collection.Find(ctx, filter.query())
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.Transaction](ctx, cursor)
+}
+
+func (r *MongoTransactionRepository) Count(ctx context.Context, filter TransactionFilter) (int64, error) {
+	return r.collection.CountDocuments(ctx, filter.query())
+}
+
+func (f TransactionFilter) query() bson.M {
+	query := bson.M{}
+	if f.User_ID != "" {
+		query["user_id"] = f.User_ID
+	}
+	if f.Status != "" {
+		query["status"] = f.Status
+	}
+	return query
+}
+
+// Transition moves the transaction with reference from status from to
+// status to and returns it as updated. holdReason is stored with it, or
+// cleared when empty. Matching on the status means only one caller can
+// make a given transition; the others get ErrNotFound.
+func (r *MongoTransactionRepository) Transition(ctx context.Context, reference, from, to, holdReason string) (*models.Transaction, error) {
+	set := bson.M{"status": to, "updated_at": time.Now()}
+	update := bson.M{"$set": set}
+	if holdReason != "" {
+		set["hold_reason"] = holdReason
+	} else {
+		update["$unset"] = bson.M{"hold_reason": ""}
+	}
+	var transaction *models.Transaction
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"reference": reference, "status": from},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&transaction)
+	return transaction, err
+}
+
+// MemoryTransactionRepository keeps transactions in process memory, in
+// insertion order.
+type MemoryTransactionRepository struct {
+	mu           sync.Mutex
+	transactions []*models.Transaction
+}
+
+func NewMemoryTransactionRepository() *MemoryTransactionRepository {
+	return &MemoryTransactionRepository{}
+}
+
+func (r *MemoryTransactionRepository) Create(ctx context.Context, transaction *models.Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := clone(transaction)
+	for _, other := range r.transactions {
+		if other.ID == stored.ID || sameString(other.Reference, stored.Reference) {
+			return ErrDuplicate
+		}
+	}
+	r.transactions = append(r.transactions, stored)
+	return nil
+}
+
+func (r *MemoryTransactionRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, transaction := range r.transactions {
+		if transaction.ID == id {
+			return clone(transaction), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryTransactionRepository) FindByReference(ctx context.Context, reference string) (*models.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if transaction := r.find(reference); transaction != nil {
+		return clone(transaction), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryTransactionRepository) find(reference string) *models.Transaction {
+	for _, transaction := range r.transactions {
+		if transaction.Reference != nil && *transaction.Reference == reference {
+			return transaction
+		}
+	}
+	return nil
+}
+
+func (r *MemoryTransactionRepository) List(ctx context.Context, filter TransactionFilter) ([]*models.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var transactions []*models.Transaction
+	for _, transaction := range r.transactions {
+		if filter.matches(transaction) {
+			transactions = append(transactions, clone(transaction))
+		}
+	}
+	return transactions, nil
+}
+
+func (r *MemoryTransactionRepository) Count(ctx context.Context, filter TransactionFilter) (int64, error) {
+	transactions, err := r.List(ctx, filter)
+	return int64(len(transactions)), err
+}
+
+func (f TransactionFilter) matches(transaction *models.Transaction) bool {
+	return (f.User_ID == "" || f.User_ID == transaction.User_ID) &&
+		(f.Status == "" || f.Status == transaction.Status)
+}
+
+func (r *MemoryTransactionRepository) Transition(ctx context.Context, reference, from, to, holdReason string) (*models.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	transaction := r.find(reference)
+	if transaction == nil || transaction.Status != from {
+		return nil, ErrNotFound
+	}
+	transaction.Status = to
+	transaction.Hold_Reason = holdReason
+	transaction.Updated_At = time.Now()
+	return clone(transaction), nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTransaction(reference, userID, status string) *models.Transaction {
+	return &models.Transaction{
+		ID:        primitive.NewObjectID(),
+		Reference: &reference,
+		User_ID:   userID,
+		Amount:    "1000",
+		Status:    status,
+	}
+}
+
+func TestTransactionCreateUniqueReference(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		if err := repos.Transactions.Create(ctx, newTransaction("ref", "u1", "pending")); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Transactions.Create(ctx, newTransaction("ref", "u2", "pending")); !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("Create with a used reference = %v, want ErrDuplicate", err)
+		}
+	})
+}
+
+func TestTransactionTransition(t *testing.T) {
+	tests := []struct {
+		name       string
+		from, to   string
+		holdReason string
+		wantErr    error
+	}{
+		{name: "complete", from: "pending", to: "complete"},
+		{name: "hold", from: "pending", to: "held", holdReason: "daily inflow limit"},
+		{name: "wrong status", from: "held", to: "complete", wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			each(t, func(t *testing.T, repos *Repositories) {
+				ctx := context.Background()
+				if err := repos.Transactions.Create(ctx, newTransaction("ref", "u1", "pending")); err != nil {
+					t.Fatal(err)
+				}
+				transaction, err := repos.Transactions.Transition(ctx, "ref", tt.from, tt.to, tt.holdReason)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Transition = %v, want %v", err, tt.wantErr)
+				}
+				if err != nil {
+					return
+				}
+				if transaction.Status != tt.to || transaction.Hold_Reason != tt.holdReason {
+					t.Fatalf("Transition returned %s %q, want %s %q", transaction.Status, transaction.Hold_Reason, tt.to, tt.holdReason)
+				}
+				stored, err := repos.Transactions.FindByReference(ctx, "ref")
+				if err != nil || stored.Status != tt.to {
+					t.Fatalf("stored transaction = %+v, %v", stored, err)
+				}
+			})
+		})
+	}
+}
+
+func TestTransactionTransitionClearsHoldReason(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		if err := repos.Transactions.Create(ctx, newTransaction("ref", "u1", "pending")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repos.Transactions.Transition(ctx, "ref", "pending", "held", "limit"); err != nil {
+			t.Fatal(err)
+		}
+		transaction, err := repos.Transactions.Transition(ctx, "ref", "held", "complete", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if transaction.Hold_Reason != "" {
+			t.Fatalf("Hold_Reason = %q after release", transaction.Hold_Reason)
+		}
+	})
+}
+
+func TestTransactionTransitionOnce(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		if err := repos.Transactions.Create(ctx, newTransaction("ref", "u1", "pending")); err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		won := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := repos.Transactions.Transition(ctx, "ref", "pending", "complete", ""); err == nil {
+					mu.Lock()
+					won++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if won != 1 {
+			t.Fatalf("%d transitions applied, want 1", won)
+		}
+	})
+}
+
+func TestTransactionListAndCount(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		for _, transaction := range []*models.Transaction{
+			newTransaction("r1", "u1", "complete"),
+			newTransaction("r2", "u1", "failed"),
+			newTransaction("r3", "u2", "complete"),
+		} {
+			if err := repos.Transactions.Create(ctx, transaction); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tests := []struct {
+			filter TransactionFilter
+			want   int64
+		}{
+			{TransactionFilter{}, 3},
+			{TransactionFilter{User_ID: "u1"}, 2},
+			{TransactionFilter{Status: "complete"}, 2},
+			{TransactionFilter{User_ID: "u2", Status: "failed"}, 0},
+		}
+		for _, tt := range tests {
+			if got, err := repos.Transactions.Count(ctx, tt.filter); err != nil || got != tt.want {
+				t.Errorf("Count(%+v) = %d, %v; want %d", tt.filter, got, err, tt.want)
+			}
+			transactions, err := repos.Transactions.List(ctx, tt.filter)
+			if err != nil || int64(len(transactions)) != tt.want {
+				t.Errorf("List(%+v) = %d, %v; want %d", tt.filter, len(transactions), err, tt.want)
+			}
+		}
+	})
+}
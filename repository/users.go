@@ -0,0 +1,442 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserFilter selects users. Empty fields match every user.
+type UserFilter struct {
+	Email    string
+	Phone    string
+	Username string
+	Role     string
+	// Kyc_Verified only matches users whose KYC was approved.
+	Kyc_Verified bool
+}
+
+// UserUpdate sets the fields that are not nil.
+type UserUpdate struct {
+	Email_Verified    *bool             `bson:"email_verified,omitempty"`
+	Selfie_Upload     *bool             `bson:"selfie_upload,omitempty"`
+	Bvn_Verified      *bool             `bson:"bvn_verified,omitempty"`
+	ID_Upload         *bool             `bson:"id_upload,omitempty"`
+	Kyc_Status        *bool             `bson:"kyc_status,omitempty"`
+	Identification    *string           `bson:"identification,omitempty"`
+	Profile_Picture   *string           `bson:"profile_picture,omitempty"`
+	Profile_Thumbnail *string           `bson:"profile_thumbnail,omitempty"`
+	Token             *string           `bson:"token,omitempty"`
+	Refresh_Token     *string           `bson:"refresh_token,omitempty"`
+	Two_Factor        *models.TwoFactor `bson:"two_factor,omitempty"`
+}
+
+// UserRepository stores users by their User_ID. Email, phone and username
+// are unique; a clash fails with ErrEmailTaken, ErrPhoneTaken or
+// ErrUsernameTaken.
+type UserRepository interface {
+	Create(context.Context, *models.User) error
+	FindByID(context.Context, string) (*models.User, error)
+	FindByEmail(context.Context, string) (*models.User, error)
+	Count(context.Context, UserFilter) (int64, error)
+	List(context.Context, UserFilter) ([]*models.User, error)
+	Update(context.Context, string, UserUpdate) error
+	SetContact(context.Context, string, string, string) (bool, error)
+	VerifyPhone(context.Context, string, string) error
+	AdjustBalance(context.Context, string, int) (int, error)
+	ConsumeRecoveryCode(context.Context, string, string) (bool, error)
+	UseTOTPStep(context.Context, string, int64) (bool, error)
+}
+
+type MongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserRepository(collection *mongo.Collection) *MongoUserRepository {
+	return &MongoUserRepository{
+		collection: collection,
+	}
+}
+
+func (r *MongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	_, err := r.collection.InsertOne(ctx, user)
+	return duplicateUserError(err)
+}
+
+// duplicateUserError turns a clash on one of the unique user indexes into
+// the error for that field.
+func duplicateUserError(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	switch message := err.Error(); {
+	case strings.Contains(message, "email_1"):
+		return ErrEmailTaken
+	case strings.Contains(message, "phone_1"):
+		return ErrPhoneTaken
+	case strings.Contains(message, "username_1"):
+		return ErrUsernameTaken
+	}
+	return duplicate(err)
+}
+
+func (r *MongoUserRepository) FindByID(ctx context.Context, userID string) (*models.User, error) {
+	var user *models.User
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+	return user, err
+}
+
+func (r *MongoUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user *models.User
+	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	return user, err
+}
+
+func (r *MongoUserRepository) Count(ctx context.Context, filter UserFilter) (int64, error) {
+	return r.collection.CountDocuments(ctx, filter.query())
+}
+
+func (r *MongoUserRepository) List(ctx context.Context, filter UserFilter) ([]*models.User, error) {
+	cursor, err := r.collection.Find(ctx, filter.query())
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.User](ctx, cursor)
+}
+
+func (f UserFilter) query() bson.M {
+	query := bson.M{}
+	if f.Email != "" {
+		query["email"] = f.Email
+	}
+	if f.Phone != "" {
+		query["phone"] = f.Phone
+	}
+	if f.Username != "" {
+		query["username"] = f.Username
+	}
+	if f.Role != "" {
+		query["role"] = f.Role
+	}
+	if f.Kyc_Verified {
+		query["kyc_status"] = true
+	}
+	return query
+}
+
+func (r *MongoUserRepository) Update(ctx context.Context, userID string, update UserUpdate) error {
+	// omitempty leaves the nil fields out of the $set.
+	result, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userID}, bson.M{
+		"$set":         update,
+		"$currentDate": bson.M{"updated_at": true},
+	})
+	return matched(result, duplicateUserError(err))
+}
+
+// SetContact changes the user's email and phone and reports whether the
+// phone changed. A new phone number has to be verified again.
+func (r *MongoUserRepository) SetContact(ctx context.Context, userID, email, phone string) (bool, error) {
+	// A single pipeline update compares with the old phone, so a change the
+	// unique indexes reject leaves the verification alone. Values in a
+	// pipeline starting with $ would be read as field paths, so the caller's
+	// are passed as literals.
+	var before models.User
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"user_id": userID}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"phone_verified": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$phone", bson.M{"$literal": phone}}},
+				"$phone_verified",
+				false,
+			}},
+			"email":      bson.M{"$literal": email},
+			"phone":      bson.M{"$literal": phone},
+			"updated_at": time.Now(),
+		}}},
+	}).Decode(&before)
+	if err != nil {
+		return false, duplicateUserError(err)
+	}
+	return before.Phone == nil || *before.Phone != phone, nil
+}
+
+// VerifyPhone marks phone verified if it is still the user's number, and
+// fails with ErrNotFound if it is not.
+func (r *MongoUserRepository) VerifyPhone(ctx context.Context, userID, phone string) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"user_id": userID, "phone": phone},
+		bson.M{"$set": bson.M{"phone_verified": true}},
+	)
+	return matched(result, err)
+}
+
+// AdjustBalance adds amount, which is negative for a debit, to the user's
+// balance and returns the new balance. A debit larger than the balance
+// fails with ErrInsufficientBalance and changes nothing.
+func (r *MongoUserRepository) AdjustBalance(ctx context.Context, userID string, amount int) (int, error) {
+	filter := bson.M{"user_id": userID}
+	if amount < 0 {
+		filter["balance"] = bson.M{"$gte": -amount}
+	}
+	var user models.User
+	err := r.collection.FindOneAndUpdate(ctx, filter,
+		bson.M{"$inc": bson.M{"balance": amount}, "$set": bson.M{"updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"balance": 1}),
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) && amount < 0 {
+		return 0, ErrInsufficientBalance
+	}
+	return user.Balance, err
+}
+
+// ConsumeRecoveryCode removes the recovery code with hash from the user's
+// unused codes and reports whether it was there.
+func (r *MongoUserRepository) ConsumeRecoveryCode(ctx context.Context, userID, hash string) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"user_id": userID, "two_factor.recovery_codes": hash},
+		bson.M{"$pull": bson.M{"two_factor.recovery_codes": hash}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// UseTOTPStep records step as the last TOTP time step used by the user and
+// reports whether it is later than the one before, so each code is only
+// accepted once.
+func (r *MongoUserRepository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		// $not also matches users who have never used a code.
+		bson.M{"user_id": userID, "two_factor.totp_last_step": bson.M{"$not": bson.M{"$gte": step}}},
+		bson.M{"$set": bson.M{"two_factor.totp_last_step": step}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// MemoryUserRepository keeps users in process memory, in insertion order.
+type MemoryUserRepository struct {
+	mu    sync.Mutex
+	users []*models.User
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{}
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := clone(user)
+	if err := r.checkUnique(stored, nil); err != nil {
+		return err
+	}
+	r.users = append(r.users, stored)
+	return nil
+}
+
+// checkUnique mirrors the unique indexes on Users, which only cover string
+// values. replacing is the stored user that user would replace, if any.
+func (r *MemoryUserRepository) checkUnique(user, replacing *models.User) error {
+	for _, other := range r.users {
+		if other == replacing {
+			continue
+		}
+		switch {
+		case other.ID == user.ID:
+			return ErrDuplicate
+		case sameString(other.Email, user.Email):
+			return ErrEmailTaken
+		case sameString(other.Phone, user.Phone):
+			return ErrPhoneTaken
+		case sameString(other.Username, user.Username):
+			return ErrUsernameTaken
+		case user.User_ID != "" && other.User_ID == user.User_ID:
+			return ErrDuplicate
+		}
+	}
+	return nil
+}
+
+func sameString(a, b *string) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+func (r *MemoryUserRepository) find(userID string) *models.User {
+	for _, user := range r.users {
+		if user.User_ID == userID {
+			return user
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) FindByID(ctx context.Context, userID string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user := r.find(userID); user != nil {
+		return clone(user), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email != nil && *user.Email == email {
+			return clone(user), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserRepository) Count(ctx context.Context, filter UserFilter) (int64, error) {
+	users, err := r.List(ctx, filter)
+	return int64(len(users)), err
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context, filter UserFilter) ([]*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []*models.User
+	for _, user := range r.users {
+		if filter.matches(user) {
+			users = append(users, clone(user))
+		}
+	}
+	return users, nil
+}
+
+func (f UserFilter) matches(user *models.User) bool {
+	return matchString(f.Email, user.Email) &&
+		matchString(f.Phone, user.Phone) &&
+		matchString(f.Username, user.Username) &&
+		(f.Role == "" || f.Role == user.Role) &&
+		(!f.Kyc_Verified || user.Kyc_Status)
+}
+
+func matchString(want string, value *string) bool {
+	return want == "" || value != nil && *value == want
+}
+
+func (r *MemoryUserRepository) Update(ctx context.Context, userID string, update UserUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user := r.find(userID)
+	if user == nil {
+		return ErrNotFound
+	}
+	setBool(&user.Email_Verified, update.Email_Verified)
+	setBool(&user.Selfie_Upload, update.Selfie_Upload)
+	setBool(&user.Bvn_Verified, update.Bvn_Verified)
+	setBool(&user.ID_Upload, update.ID_Upload)
+	setBool(&user.Kyc_Status, update.Kyc_Status)
+	setString(&user.Identification, update.Identification)
+	setString(&user.Profile_Picture, update.Profile_Picture)
+	setString(&user.Profile_Thumbnail, update.Profile_Thumbnail)
+	setString(&user.Token, update.Token)
+	setString(&user.Refresh_Token, update.Refresh_Token)
+	if update.Two_Factor != nil {
+		user.Two_Factor = *clone(update.Two_Factor)
+	}
+	user.Updated_At = time.Now()
+	return nil
+}
+
+func setBool(field *bool, value *bool) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+func setString(field **string, value *string) {
+	if value != nil {
+		v := *value
+		*field = &v
+	}
+}
+
+func (r *MemoryUserRepository) SetContact(ctx context.Context, userID, email, phone string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user := r.find(userID)
+	if user == nil {
+		return false, ErrNotFound
+	}
+	changed := *clone(user)
+	changed.Email, changed.Phone = &email, &phone
+	if err := r.checkUnique(&changed, user); err != nil {
+		return false, err
+	}
+	phoneChanged := user.Phone == nil || *user.Phone != phone
+	if phoneChanged {
+		user.Phone_Verified = false
+	}
+	user.Email, user.Phone = &email, &phone
+	user.Updated_At = time.Now()
+	return phoneChanged, nil
+}
+
+func (r *MemoryUserRepository) VerifyPhone(ctx context.Context, userID, phone string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user := r.find(userID)
+	if user == nil || user.Phone == nil || *user.Phone != phone {
+		return ErrNotFound
+	}
+	user.Phone_Verified = true
+	return nil
+}
+
+func (r *MemoryUserRepository) AdjustBalance(ctx context.Context, userID string, amount int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user := r.find(userID)
+	switch {
+	case amount < 0 && (user == nil || user.Balance < -amount):
+		return 0, ErrInsufficientBalance
+	case user == nil:
+		return 0, ErrNotFound
+	}
+	user.Balance += amount
+	user.Updated_At = time.Now()
+	return user.Balance, nil
+}
+
+func (r *MemoryUserRepository) ConsumeRecoveryCode(ctx context.Context, userID, hash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user := r.find(userID)
+	if user == nil {
+		return false, nil
+	}
+	codes := user.Two_Factor.Recovery_Codes
+	for i, code := range codes {
+		if code == hash {
+			user.Two_Factor.Recovery_Codes = append(codes[:i:i], codes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryUserRepository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user := r.find(userID)
+	if user == nil || user.Two_Factor.Totp_Last_Step >= step {
+		return false, nil
+	}
+	user.Two_Factor.Totp_Last_Step = step
+	return true, nil
+}
@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newUser(userID, email, phone, username string) *models.User {
+	user := &models.User{ID: primitive.NewObjectID(), User_ID: userID, Role: "user"}
+	if email != "" {
+		user.Email = &email
+	}
+	if phone != "" {
+		user.Phone = &phone
+	}
+	if username != "" {
+		user.Username = &username
+	}
+	return user
+}
+
+func TestUserCreateUnique(t *testing.T) {
+	tests := []struct {
+		name string
+		user *models.User
+		want error
+	}{
+		{"new user", newUser("u2", "b@example.com", "+2342", "b"), nil},
+		{"same email", newUser("u2", "a@example.com", "+2342", "b"), ErrEmailTaken},
+		{"same phone", newUser("u2", "b@example.com", "+2341", "b"), ErrPhoneTaken},
+		{"same username", newUser("u2", "b@example.com", "+2342", "a"), ErrUsernameTaken},
+		{"same user id", newUser("u1", "b@example.com", "+2342", "b"), ErrDuplicate},
+		// Donors have no phone, and the index only covers strings.
+		{"no phone", newUser("u2", "b@example.com", "", "b"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			each(t, func(t *testing.T, repos *Repositories) {
+				ctx := context.Background()
+				if err := repos.Users.Create(ctx, newUser("u1", "a@example.com", "", "a")); err != nil {
+					t.Fatal(err)
+				}
+				if err := repos.Users.Create(ctx, newUser("u3", "c@example.com", "+2341", "c")); err != nil {
+					t.Fatal(err)
+				}
+				if err := repos.Users.Create(ctx, tt.user); !errors.Is(err, tt.want) {
+					t.Fatalf("Create = %v, want %v", err, tt.want)
+				}
+			})
+		})
+	}
+}
+
+func TestUserAdjustBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  string
+		amounts []int
+		want    int
+		wantErr error
+	}{
+		{name: "credit", userID: "u1", amounts: []int{500}, want: 500},
+		{name: "debit", userID: "u1", amounts: []int{500, -200}, want: 300},
+		{name: "whole balance", userID: "u1", amounts: []int{500, -500}, want: 0},
+		{name: "overdraft", userID: "u1", amounts: []int{500, -501}, wantErr: ErrInsufficientBalance},
+		{name: "missing user", userID: "nobody", amounts: []int{100}, wantErr: ErrNotFound},
+		{name: "debit missing user", userID: "nobody", amounts: []int{-100}, wantErr: ErrInsufficientBalance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			each(t, func(t *testing.T, repos *Repositories) {
+				ctx := context.Background()
+				if err := repos.Users.Create(ctx, newUser("u1", "a@example.com", "", "a")); err != nil {
+					t.Fatal(err)
+				}
+				var balance int
+				var err error
+				for _, amount := range tt.amounts {
+					if balance, err = repos.Users.AdjustBalance(ctx, tt.userID, amount); err != nil {
+						break
+					}
+				}
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("AdjustBalance = %v, want %v", err, tt.wantErr)
+				}
+				if err == nil && balance != tt.want {
+					t.Fatalf("balance = %d, want %d", balance, tt.want)
+				}
+			})
+		})
+	}
+}
+
+func TestUserAdjustBalanceConcurrentDebits(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		if err := repos.Users.Create(ctx, newUser("u1", "a@example.com", "", "a")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repos.Users.AdjustBalance(ctx, "u1", 1000); err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		debited := 0
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := repos.Users.AdjustBalance(ctx, "u1", -100); err == nil {
+					mu.Lock()
+					debited++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		user, err := repos.Users.FindByID(ctx, "u1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if debited != 10 || user.Balance != 0 {
+			t.Fatalf("debited %d times leaving %d, want 10 times leaving 0", debited, user.Balance)
+		}
+	})
+}
+
+func TestUserSetContact(t *testing.T) {
+	tests := []struct {
+		name         string
+		email, phone string
+		wantErr      error
+		wantVerified bool
+		wantChanged  bool
+	}{
+		{name: "same phone", email: "new@example.com", phone: "+2341", wantVerified: true},
+		{name: "new phone", email: "a@example.com", phone: "+2349", wantVerified: false, wantChanged: true},
+		{name: "taken email", email: "b@example.com", phone: "+2341", wantErr: ErrEmailTaken, wantVerified: true},
+		{name: "taken phone", email: "a@example.com", phone: "+2342", wantErr: ErrPhoneTaken, wantVerified: true},
+		// Stored as given, not read as the fields they name.
+		{name: "field paths", email: "$password", phone: "$phone", wantVerified: false, wantChanged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			each(t, func(t *testing.T, repos *Repositories) {
+				ctx := context.Background()
+				for _, user := range []*models.User{
+					newUser("u1", "a@example.com", "+2341", "a"),
+					newUser("u2", "b@example.com", "+2342", "b"),
+				} {
+					if err := repos.Users.Create(ctx, user); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := repos.Users.VerifyPhone(ctx, "u1", "+2341"); err != nil {
+					t.Fatal(err)
+				}
+				changed, err := repos.Users.SetContact(ctx, "u1", tt.email, tt.phone)
+				if !errors.Is(err, tt.wantErr) || changed != tt.wantChanged {
+					t.Fatalf("SetContact = %v, %v; want %v, %v", changed, err, tt.wantChanged, tt.wantErr)
+				}
+				user, err := repos.Users.FindByID(ctx, "u1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if user.Phone_Verified != tt.wantVerified {
+					t.Errorf("Phone_Verified = %v, want %v", user.Phone_Verified, tt.wantVerified)
+				}
+				if tt.wantErr == nil && (*user.Email != tt.email || *user.Phone != tt.phone) {
+					t.Errorf("contact = %s %s, want %s %s", *user.Email, *user.Phone, tt.email, tt.phone)
+				}
+			})
+		})
+	}
+}
+
+func TestUserVerifyPhoneChangedNumber(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		if err := repos.Users.Create(ctx, newUser("u1", "a@example.com", "+2341", "a")); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Users.VerifyPhone(ctx, "u1", "+2349"); err != ErrNotFound {
+			t.Fatalf("VerifyPhone of an old number = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestUserUpdateAndList(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		admin := newUser("admin", "admin@example.com", "", "admin")
+		admin.Role = "admin"
+		for _, user := range []*models.User{newUser("u1", "a@example.com", "", "a"), newUser("u2", "b@example.com", "", "b"), admin} {
+			if err := repos.Users.Create(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+		}
+		approved, token := true, "token"
+		if err := repos.Users.Update(ctx, "u1", UserUpdate{Kyc_Status: &approved, Token: &token}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Users.Update(ctx, "nobody", UserUpdate{Token: &token}); err != ErrNotFound {
+			t.Fatalf("Update of a missing user = %v, want ErrNotFound", err)
+		}
+
+		tests := []struct {
+			filter UserFilter
+			want   int64
+		}{
+			{UserFilter{}, 3},
+			{UserFilter{Role: "user"}, 2},
+			{UserFilter{Role: "user", Kyc_Verified: true}, 1},
+			{UserFilter{Email: "b@example.com"}, 1},
+			{UserFilter{Username: "c"}, 0},
+		}
+		for _, tt := range tests {
+			if got, err := repos.Users.Count(ctx, tt.filter); err != nil || got != tt.want {
+				t.Errorf("Count(%+v) = %d, %v; want %d", tt.filter, got, err, tt.want)
+			}
+			users, err := repos.Users.List(ctx, tt.filter)
+			if err != nil || int64(len(users)) != tt.want {
+				t.Errorf("List(%+v) = %d users, %v; want %d", tt.filter, len(users), err, tt.want)
+			}
+		}
+
+		user, err := repos.Users.FindByEmail(ctx, "a@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Token == nil || *user.Token != token || user.Email_Verified {
+			t.Errorf("Update changed %+v", user)
+		}
+	})
+}
+
+func TestUserConsumeRecoveryCode(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		user := newUser("u1", "a@example.com", "", "a")
+		user.Two_Factor = models.TwoFactor{Enabled: true, Recovery_Codes: []string{"h1", "h2"}}
+		if err := repos.Users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range []struct {
+			hash string
+			want bool
+		}{
+			{"h1", true},
+			{"h1", false},
+			{"h3", false},
+			{"h2", true},
+		} {
+			if got, err := repos.Users.ConsumeRecoveryCode(ctx, "u1", tt.hash); err != nil || got != tt.want {
+				t.Errorf("ConsumeRecoveryCode(%s) = %v, %v; want %v", tt.hash, got, err, tt.want)
+			}
+		}
+	})
+}
+
+func TestUserUseTOTPStep(t *testing.T) {
+	each(t, func(t *testing.T, repos *Repositories) {
+		ctx := context.Background()
+		if err := repos.Users.Create(ctx, newUser("u1", "a@example.com", "", "a")); err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range []struct {
+			step int64
+			want bool
+		}{
+			{100, true},
+			{100, false},
+			{99, false},
+			{101, true},
+		} {
+			if got, err := repos.Users.UseTOTPStep(ctx, "u1", tt.step); err != nil || got != tt.want {
+				t.Errorf("UseTOTPStep(%d) = %v, %v; want %v", tt.step, got, err, tt.want)
+			}
+		}
+	})
+}
+
+func TestMemoryUserRepositoryCopies(t *testing.T) {
+	repo := NewMemoryUserRepository()
+	ctx := context.Background()
+	user := newUser("u1", "a@example.com", "", "a")
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	*user.Email = "changed@example.com"
+	found, err := repo.FindByID(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	*found.Username = "changed"
+	again, _ := repo.FindByID(ctx, "u1")
+	if *again.Email != "a@example.com" || *again.Username != "a" {
+		t.Fatalf("stored user changed through a pointer: %s %s", *again.Email, *again.Username)
+	}
+}
@@ -4,35 +4,39 @@ import (
 	"context"
 
 	"github.com/JayJosh846/donationPlatform/models"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/JayJosh846/donationPlatform/repository"
 )
 
 type BankService interface {
-	AddBank(*models.Bank) error
-	GetUserBankByID(id string) (*models.Bank, error)
+	AddBank(context.Context, *models.Bank) error
+	GetUserBankByID(ctx context.Context, id string) (*models.Bank, error)
+	HasBank(context.Context, string) (bool, error)
 }
 
 type BankServiceImpl struct {
-	bankCollection *mongo.Collection
-	ctx            context.Context
+	bankRepository repository.BankRepository
 }
 
-func BankConstructor(bankCollection *mongo.Collection, ctx context.Context) BankService {
+func BankConstructor(bankRepository repository.BankRepository) BankService {
 	return &BankServiceImpl{
-		bankCollection: bankCollection,
-		ctx:            ctx,
+		bankRepository: bankRepository,
 	}
 }
 
-func (b *BankServiceImpl) AddBank(bank *models.Bank) error {
-	_, err := b.bankCollection.InsertOne(b.ctx, bank)
-	return err
+func (b *BankServiceImpl) AddBank(ctx context.Context, bank *models.Bank) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return b.bankRepository.Create(ctx, bank)
 }
 
-func (b *BankServiceImpl) GetUserBankByID(id string) (*models.Bank, error) {
-	var bank *models.Bank
-	query := bson.M{"user_id": id}
-	err := b.bankCollection.FindOne(b.ctx, query).Decode(&bank)
-	return bank, err
+func (b *BankServiceImpl) GetUserBankByID(ctx context.Context, id string) (*models.Bank, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return b.bankRepository.FindByUserID(ctx, id)
+}
+
+func (b *BankServiceImpl) HasBank(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return b.bankRepository.Exists(ctx, id)
 }
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/JayJosh846/donationPlatform/config"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/api/option"
+)
+
+const (
+	BlobSelfie         = "selfie"
+	BlobKycDocument    = "kyc-document"
+	BlobProfilePicture = "profile-picture"
+)
+
+var (
+	ErrBlobNotFound   = errors.New("blob not found")
+	ErrInvalidBlobKey = errors.New("invalid blob key")
+)
+
+// BlobStore keeps uploaded files. Keys come from NewBlobKey and are opaque
+// to callers.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobKey returns a fresh key for a user's upload of the given kind. Keys
+// are namespaced per user and never reuse the client's filename, so uploads
+// cannot collide or overwrite each other. ext is the extension of the
+// detected content type, e.g. ".jpg".
+func NewBlobKey(userID, kind, ext string) string {
+	return path.Join("users", userID, kind, uuid.NewString()+ext)
+}
+
+func validBlobKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, "/") && path.Clean(key) == key && !strings.Contains(key, "..")
+}
+
+// NewBlobStore returns the private store for KYC files, using the
+// configured backend: gridfs (the default), gcs or local.
+func NewBlobStore(ctx context.Context, db *mongo.Database, cfg config.StorageConfig) (BlobStore, error) {
+	switch cfg.Backend {
+	case "", "gridfs":
+		return NewGridFSBlobStore(db, "fs")
+	case "gcs":
+		return NewGCSBlobStore(ctx, cfg.GCS_Bucket, cfg.GCS_Credentials_File)
+	case "local":
+		return NewLocalBlobStore(cfg.Local_Dir)
+	default:
+		return nil, fmt.Errorf("unknown blob backend %q", cfg.Backend)
+	}
+}
+
+// PublicBlobStore holds files anyone may read, such as profile pictures.
+// It is always a separate location from the private store so a public URL
+// can never reach a KYC file; config.Validate refuses a shared bucket or
+// directories that are the same or nested.
+type PublicBlobStore struct {
+	BlobStore
+	BaseURL string
+}
+
+// URL is where clients can fetch the file stored at key.
+func (p *PublicBlobStore) URL(key string) string {
+	return strings.TrimSuffix(p.BaseURL, "/") + "/" + key
+}
+
+// NewPublicBlobStore returns the public store, using the configured public
+// backend. A GCS bucket must be configured for public read; other backends
+// are served by the API under the public base URL.
+func NewPublicBlobStore(ctx context.Context, db *mongo.Database, cfg config.StorageConfig) (*PublicBlobStore, error) {
+	var (
+		store   BlobStore
+		baseURL = "/api/v1/public"
+		err     error
+	)
+	switch cfg.Public_Backend {
+	case "", "gridfs":
+		store, err = NewGridFSBlobStore(db, "public")
+	case "gcs":
+		if cfg.GCS_Public_Bucket == "" {
+			return nil, errors.New("a public GCS bucket is required for the gcs public backend")
+		}
+		baseURL = "https://storage.googleapis.com/" + cfg.GCS_Public_Bucket
+		store, err = NewGCSBlobStore(ctx, cfg.GCS_Public_Bucket, cfg.GCS_Credentials_File)
+	case "local":
+		store, err = NewLocalBlobStore(cfg.Public_Local_Dir)
+	default:
+		return nil, fmt.Errorf("unknown public blob backend %q", cfg.Public_Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Public_Base_URL != "" {
+		baseURL = cfg.Public_Base_URL
+	}
+	return &PublicBlobStore{BlobStore: store, BaseURL: baseURL}, nil
+}
+
+type GCSBlobStore struct {
+	bucket *storage.BucketHandle
+}
+
+// NewGCSBlobStore uses the credentials file when one is given and the
+// default application credentials otherwise.
+func NewGCSBlobStore(ctx context.Context, bucket, credentialsFile string) (*GCSBlobStore, error) {
+	var opts []option.ClientOption
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &GCSBlobStore{bucket: client.Bucket(bucket)}, nil
+}
+
+func (g *GCSBlobStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if !validBlobKey(key) {
+		return ErrInvalidBlobKey
+	}
+	w := g.bucket.Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (g *GCSBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validBlobKey(key) {
+		return nil, ErrInvalidBlobKey
+	}
+	reader, err := g.bucket.Object(key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return reader, err
+}
+
+func (g *GCSBlobStore) Delete(ctx context.Context, key string) error {
+	if !validBlobKey(key) {
+		return ErrInvalidBlobKey
+	}
+	err := g.bucket.Object(key).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return ErrBlobNotFound
+	}
+	return err
+}
+
+type GridFSBlobStore struct {
+	bucket *gridfs.Bucket
+}
+
+func NewGridFSBlobStore(db *mongo.Database, name string) (*GridFSBlobStore, error) {
+	bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(name))
+	if err != nil {
+		return nil, err
+	}
+	return &GridFSBlobStore{bucket: bucket}, nil
+}
+
+func (g *GridFSBlobStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if !validBlobKey(key) {
+		return ErrInvalidBlobKey
+	}
+	opts := options.GridFSUpload().SetMetadata(bson.M{"content_type": contentType})
+	_, err := g.bucket.UploadFromStream(key, r, opts)
+	return err
+}
+
+func (g *GridFSBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validBlobKey(key) {
+		return nil, ErrInvalidBlobKey
+	}
+	stream, err := g.bucket.OpenDownloadStreamByName(key)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrBlobNotFound
+	}
+	return stream, err
+}
+
+func (g *GridFSBlobStore) Delete(ctx context.Context, key string) error {
+	if !validBlobKey(key) {
+		return ErrInvalidBlobKey
+	}
+	cursor, err := g.bucket.Find(bson.M{"filename": key})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	found := false
+	for cursor.Next(ctx) {
+		var file struct {
+			ID interface{} `bson:"_id"`
+		}
+		if err := cursor.Decode(&file); err != nil {
+			return err
+		}
+		if err := g.bucket.Delete(file.ID); err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return ErrBlobNotFound
+	}
+	return cursor.Err()
+}
+
+// LocalBlobStore keeps files under a directory on disk. It is meant for
+// local development and tests.
+type LocalBlobStore struct {
+	root string
+}
+
+func NewLocalBlobStore(root string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalBlobStore{root: root}, nil
+}
+
+func (l *LocalBlobStore) path(key string) (string, error) {
+	if !validBlobKey(key) {
+		return "", ErrInvalidBlobKey
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+func (l *LocalBlobStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see a partial upload.
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (l *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return file, err
+}
+
+func (l *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrBlobNotFound
+	}
+	return err
+}
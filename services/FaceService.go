@@ -0,0 +1,115 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/config"
+)
+
+// FaceMatchThreshold is the lowest similarity accepted as the same face.
+var FaceMatchThreshold = 0.8
+
+var ErrNoReferencePhoto = errors.New("the identity provider returned no photo to compare against")
+
+// FaceMatcher scores how likely two images show the same person, from 0
+// to 1.
+type FaceMatcher interface {
+	Compare(ctx context.Context, selfie, reference []byte) (float64, error)
+}
+
+// NewFaceMatcher picks the configured matcher. Without one the stub is
+// used, which config.Validate only allows in development.
+func NewFaceMatcher(cfg config.FaceMatchConfig) FaceMatcher {
+	switch cfg.Provider {
+	case "http":
+		return &HTTPFaceMatcher{
+			URL:    cfg.URL,
+			APIKey: cfg.API_Key,
+			Client: &http.Client{Timeout: 30 * time.Second},
+		}
+	default:
+		return StubFaceMatcher{}
+	}
+}
+
+// DecodePhoto decodes a base64 photo as returned by identity providers,
+// with or without a data URL prefix.
+func DecodePhoto(photo string) ([]byte, error) {
+	if photo == "" {
+		return nil, ErrNoReferencePhoto
+	}
+	if i := strings.Index(photo, ","); strings.HasPrefix(photo, "data:") && i >= 0 {
+		photo = photo[i+1:]
+	}
+	return base64.StdEncoding.DecodeString(photo)
+}
+
+// HTTPFaceMatcher posts both images to a face comparison endpoint that
+// answers with {"score": 0.0-1.0}.
+type HTTPFaceMatcher struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+type faceMatchRequest struct {
+	Selfie    string `json:"selfie"`
+	Reference string `json:"reference"`
+}
+
+type faceMatchResponse struct {
+	Score float64 `json:"score"`
+}
+
+func (h *HTTPFaceMatcher) Compare(ctx context.Context, selfie, reference []byte) (float64, error) {
+	requestBodyJSON, err := json.Marshal(faceMatchRequest{
+		Selfie:    base64.StdEncoding.EncodeToString(selfie),
+		Reference: base64.StdEncoding.EncodeToString(reference),
+	})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(requestBodyJSON))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("Authorization", "Bearer "+h.APIKey)
+	res, err := h.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return 0, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return 0, fmt.Errorf("face match failed with status %d", res.StatusCode)
+	}
+	var response faceMatchResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return 0, err
+	}
+	return response.Score, nil
+}
+
+// StubFaceMatcher is a deterministic matcher for local development and
+// tests: only identical images match, so it never passes a real selfie.
+type StubFaceMatcher struct{}
+
+func (StubFaceMatcher) Compare(ctx context.Context, selfie, reference []byte) (float64, error) {
+	if len(selfie) > 0 && bytes.Equal(selfie, reference) {
+		return 1, nil
+	}
+	return 0, nil
+}
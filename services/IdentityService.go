@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/JayJosh846/donationPlatform/config"
+	"github.com/JayJosh846/donationPlatform/models"
+	helper "github.com/JayJosh846/donationPlatform/utils"
+)
+
+const (
+	IdentityBVN            = "bvn"
+	IdentityNIN            = "nin"
+	IdentityDriversLicence = "drivers_licence"
+	IdentityPassport       = "passport"
+)
+
+var (
+	ErrIdentityNotFound    = errors.New("no identity record found for this number")
+	ErrIdentityUnsupported = errors.New("identity document type is not supported")
+)
+
+// NameMatchThreshold is the lowest name similarity accepted as the same
+// person.
+var NameMatchThreshold = 0.85
+
+// IdentityResult is what a provider returned for an identity number.
+type IdentityResult struct {
+	Type        string `json:"type"`
+	Provider    string `json:"provider"`
+	First_Name  string `json:"first_name"`
+	Middle_Name string `json:"middle_name"`
+	Last_Name   string `json:"last_name"`
+	DOB         string `json:"dob"`
+	Phone       string `json:"phone"`
+	Gender      string `json:"gender"`
+	// Photo is the base64 encoded image on record, when the provider has one.
+	Photo string `json:"-"`
+}
+
+func IsIdentityType(kind string) bool {
+	switch kind {
+	case IdentityBVN, IdentityNIN, IdentityDriversLicence, IdentityPassport:
+		return true
+	}
+	return false
+}
+
+func (r *IdentityResult) FullName() string {
+	return strings.Join(strings.Fields(r.First_Name+" "+r.Middle_Name+" "+r.Last_Name), " ")
+}
+
+// IdentityVerifier looks up an identity number with a verification
+// provider. It returns ErrIdentityNotFound when the provider has no record.
+type IdentityVerifier interface {
+	Lookup(ctx context.Context, kind, number string) (*IdentityResult, error)
+}
+
+// NewIdentityVerifier picks the configured provider. CheckID is the default.
+func NewIdentityVerifier(cfg config.IdentityConfig, providers config.ProvidersConfig) IdentityVerifier {
+	switch cfg.Provider {
+	case "fake":
+		return NewFakeIdentityVerifier()
+	default:
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+cfg.CheckID_Sec_Key)
+		return &CheckIDVerifier{
+			Client: NewProviderClient("checkid", cfg.CheckID_Base_URL, header, providers),
+		}
+	}
+}
+
+// IdentityMatch compares an identity record with the details on a profile.
+type IdentityMatch struct {
+	Name_Score float64 `json:"name_score"`
+	DOB_Match  bool    `json:"dob_match"`
+	Matched    bool    `json:"matched"`
+}
+
+// MatchIdentity scores the profile name against the name on record and
+// compares the dates of birth. Both must agree for a match.
+func MatchIdentity(user *models.User, result *IdentityResult) IdentityMatch {
+	var match IdentityMatch
+	if user.Fullname != nil {
+		match.Name_Score = helper.NameSimilarity(*user.Fullname, result.FullName())
+	}
+	if user.DOB != nil {
+		match.DOB_Match = helper.SameDate(*user.DOB, result.DOB)
+	}
+	match.Matched = match.DOB_Match && match.Name_Score >= NameMatchThreshold
+	return match
+}
+
+type CheckIDVerifier struct {
+	Client *ProviderClient
+}
+
+var checkIDEndpoints = map[string]struct{ path, field string }{
+	IdentityBVN:            {"/api/v1/identity/bvn", "bvn"},
+	IdentityNIN:            {"/api/v1/identity/nin", "nin"},
+	IdentityDriversLicence: {"/api/v1/identity/drivers-license", "license_number"},
+	IdentityPassport:       {"/api/v1/identity/passport", "passport_number"},
+}
+
+type checkIDResponse struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Entity json.RawMessage `json:"entity"`
+	} `json:"data"`
+}
+
+type checkIDEntity struct {
+	First_Name    string `json:"first_name"`
+	Middle_Name   string `json:"middle_name"`
+	Last_Name     string `json:"last_name"`
+	Date_Of_Birth string `json:"date_of_birth"`
+	Dob           string `json:"dob"`
+	Phone_Number  string `json:"phone_number"`
+	Gender        string `json:"gender"`
+	Photo         string `json:"photo"`
+	Image         string `json:"image"`
+}
+
+func (v *CheckIDVerifier) Lookup(ctx context.Context, kind, number string) (*IdentityResult, error) {
+	endpoint, ok := checkIDEndpoints[kind]
+	if !ok {
+		return nil, ErrIdentityUnsupported
+	}
+	// Lookups only read the record, so they are safe to retry.
+	var response checkIDResponse
+	err := v.Client.Do(ctx, ProviderCall{
+		Method: http.MethodPost,
+		Path:   endpoint.path,
+		Body: map[string]string{
+			endpoint.field: number,
+			"type":         "validate",
+		},
+		Idempotent: true,
+	}, &response)
+	if errors.Is(err, ErrProviderNotFound) {
+		return nil, ErrIdentityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Data.Entity) == 0 || string(response.Data.Entity) == "null" {
+		return nil, ErrIdentityNotFound
+	}
+	var entity checkIDEntity
+	if err := json.Unmarshal(response.Data.Entity, &entity); err != nil {
+		return nil, &ProviderError{Provider: "checkid", Message: "unexpected response", Kind: ErrProviderUnavailable}
+	}
+	// Validation responses carry a per-document status, e.g.
+	// {"bvn": {"status": false}}, when the number is not valid.
+	var statuses map[string]json.RawMessage
+	json.Unmarshal(response.Data.Entity, &statuses)
+	if raw, ok := statuses[endpoint.field]; ok {
+		var status struct {
+			Status *bool `json:"status"`
+		}
+		if json.Unmarshal(raw, &status) == nil && status.Status != nil && !*status.Status {
+			return nil, ErrIdentityNotFound
+		}
+	}
+
+	result := &IdentityResult{
+		Type:        kind,
+		Provider:    "checkid",
+		First_Name:  entity.First_Name,
+		Middle_Name: entity.Middle_Name,
+		Last_Name:   entity.Last_Name,
+		DOB:         firstNonEmpty(entity.Date_Of_Birth, entity.Dob),
+		Phone:       entity.Phone_Number,
+		Gender:      entity.Gender,
+		Photo:       firstNonEmpty(entity.Photo, entity.Image),
+	}
+	if result.FullName() == "" {
+		return nil, ErrIdentityNotFound
+	}
+	return result, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// FakeIdentityVerifier answers lookups from records added with Add. It is
+// meant for local development and tests.
+type FakeIdentityVerifier struct {
+	mu      sync.Mutex
+	records map[string]IdentityResult
+}
+
+func NewFakeIdentityVerifier() *FakeIdentityVerifier {
+	return &FakeIdentityVerifier{records: make(map[string]IdentityResult)}
+}
+
+func (f *FakeIdentityVerifier) Add(kind, number string, result IdentityResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result.Type = kind
+	result.Provider = "fake"
+	f.records[kind+":"+number] = result
+}
+
+func (f *FakeIdentityVerifier) Lookup(ctx context.Context, kind, number string) (*IdentityResult, error) {
+	if !IsIdentityType(kind) {
+		return nil, ErrIdentityUnsupported
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result, ok := f.records[kind+":"+number]
+	if !ok {
+		return nil, ErrIdentityNotFound
+	}
+	return &result, nil
+}
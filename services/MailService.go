@@ -2,34 +2,118 @@ package services
 
 import (
 	"fmt"
+	"log/slog"
 	"net/smtp"
+	"sync"
+
+	"github.com/JayJosh846/donationPlatform/config"
+	"github.com/JayJosh846/donationPlatform/logging"
 )
 
 // Send a verification email with the code
-func sendVerificationEmail(userName, email, code string) error {
-	from := "jesudara.j@gmail.com" // Replace with your email
-	password := "ycqphxpqaudxipsg" // Replace with your email password
-	to := email
+func sendVerificationEmail(mailer Mailer, userName, email, code string) error {
 	subject := "Email Verification Code"
 	body := fmt.Sprintf("Hello %s,\n\nYour verification code is: %s", userName, code)
+	return mailer.Send(email, subject, body)
+}
+
+// Send a one-time code used as a second factor for login, payouts and
+// bank detail changes
+func sendTwoFactorEmail(mailer Mailer, userName, email, purpose, code string) error {
+	subject := "Your Security Code"
+	body := fmt.Sprintf("Hello %s,\n\nYour security code for %s is: %s\n\nIf you did not request this code, please change your password immediately.", userName, purpose, code)
+	return mailer.Send(email, subject, body)
+}
+
+// Send the outcome of a KYC review to the user
+func sendKycStatusEmail(mailer Mailer, userName, email, status, reason string) error {
+	subject := "Update on your identity verification"
+	var body string
+	switch status {
+	case KycStatusApproved:
+		body = fmt.Sprintf("Hello %s,\n\nYour identity documents have been approved. Your account limits have been upgraded.", userName)
+	case KycStatusRejected:
+		body = fmt.Sprintf("Hello %s,\n\nWe could not approve your identity documents.\n\nReason: %s", userName, reason)
+	case KycStatusResubmissionRequested:
+		body = fmt.Sprintf("Hello %s,\n\nPlease upload your identity documents again.\n\nReason: %s", userName, reason)
+	default:
+		return nil
+	}
+	return mailer.Send(email, subject, body)
+}
+
+// Mailer delivers a plain text email.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NewMailer returns the mailer named by the mail provider setting.
+func NewMailer(cfg config.MailConfig) Mailer {
+	if cfg.Provider == "log" {
+		return &LogMailer{}
+	}
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+	return &SMTPMailer{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     from,
+	}
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
 
+func (m *SMTPMailer) Send(to, subject, body string) error {
 	// Compose the email
-	msg := "From: " + from + "\n" +
+	msg := "From: " + m.From + "\n" +
 		"To: " + to + "\n" +
 		"Subject: " + subject + "\n\n" +
 		body
 
-	// Set up the SMTP server configuration
-	smtpServer := "smtp.gmail.com"
-	smtpPort := "587"
-
-	auth := smtp.PlainAuth("", from, password, smtpServer)
+	auth := smtp.PlainAuth("", m.Username, m.Password, m.Host)
 
 	// Connect to the server, authenticate, and send the email
-	err := smtp.SendMail(smtpServer+":"+smtpPort, auth, from, []string{to}, []byte(msg))
-	if err != nil {
-		return err
-	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, []byte(msg))
+}
 
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// LogMailer writes emails to the log instead of sending them and keeps
+// them so tests can read what was sent.
+type LogMailer struct {
+	mu   sync.Mutex
+	Sent []MailMessage
+}
+
+func (l *LogMailer) Send(to, subject, body string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Sent = append(l.Sent, MailMessage{To: to, Subject: subject, Body: body})
+	// The body holds codes and links, so it only appears at debug level.
+	slog.Info("email logged instead of sent", "to", logging.MaskEmail(to), "subject", subject)
+	slog.Debug("email body", "to", logging.MaskEmail(to), "body", body)
 	return nil
 }
+
+func (l *LogMailer) Last() (MailMessage, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.Sent) == 0 {
+		return MailMessage{}, false
+	}
+	return l.Sent[len(l.Sent)-1], true
+}
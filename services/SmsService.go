@@ -0,0 +1,151 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/config"
+	"github.com/JayJosh846/donationPlatform/logging"
+)
+
+// SMSSender delivers a text message to a phone number in international
+// format.
+type SMSSender interface {
+	Send(ctx context.Context, to, message string) error
+}
+
+// NewSMSSender picks the configured gateway. Without one messages are only
+// logged, which is what local development and tests want.
+func NewSMSSender(cfg config.SMSConfig) SMSSender {
+	switch cfg.Provider {
+	case "termii":
+		return &TermiiSender{
+			APIKey:   cfg.Termii_API_Key,
+			SenderID: cfg.Termii_Sender_ID,
+			BaseURL:  "https://api.ng.termii.com",
+			Client:   &http.Client{Timeout: 15 * time.Second},
+		}
+	case "twilio":
+		return &TwilioSender{
+			AccountSID: cfg.Twilio_Account_SID,
+			AuthToken:  cfg.Twilio_Auth_Token,
+			From:       cfg.Twilio_From,
+			BaseURL:    "https://api.twilio.com",
+			Client:     &http.Client{Timeout: 15 * time.Second},
+		}
+	default:
+		return &LogSMSSender{}
+	}
+}
+
+type TermiiSender struct {
+	APIKey   string
+	SenderID string
+	BaseURL  string
+	Client   *http.Client
+}
+
+type termiiRequest struct {
+	To      string `json:"to"`
+	From    string `json:"from"`
+	Sms     string `json:"sms"`
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	APIKey  string `json:"api_key"`
+}
+
+func (t *TermiiSender) Send(ctx context.Context, to, message string) error {
+	requestBodyJSON, err := json.Marshal(termiiRequest{
+		To:      strings.TrimPrefix(to, "+"),
+		From:    t.SenderID,
+		Sms:     message,
+		Type:    "plain",
+		Channel: "dnd",
+		APIKey:  t.APIKey,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.BaseURL+"/api/sms/send", bytes.NewReader(requestBodyJSON))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doSMSRequest(t.Client, req, "termii")
+}
+
+type TwilioSender struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	BaseURL    string
+	Client     *http.Client
+}
+
+func (t *TwilioSender) Send(ctx context.Context, to, message string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", t.From)
+	form.Set("Body", message)
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", t.BaseURL, t.AccountSID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	return doSMSRequest(t.Client, req, "twilio")
+}
+
+func doSMSRequest(client *http.Client, req *http.Request, provider string) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s: sending sms failed with status %d: %s", provider, res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+type SMSMessage struct {
+	To      string
+	Message string
+}
+
+// LogSMSSender writes messages to the log instead of sending them and keeps
+// them so tests can read the code that was sent.
+type LogSMSSender struct {
+	mu   sync.Mutex
+	Sent []SMSMessage
+}
+
+func (l *LogSMSSender) Send(ctx context.Context, to, message string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Sent = append(l.Sent, SMSMessage{To: to, Message: message})
+	// The message holds codes, so it only appears at debug level.
+	slog.InfoContext(ctx, "sms logged instead of sent", "to", logging.MaskPhone(to))
+	slog.DebugContext(ctx, "sms body", "to", logging.MaskPhone(to), "message", message)
+	return nil
+}
+
+func (l *LogSMSSender) Last() (SMSMessage, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.Sent) == 0 {
+		return SMSMessage{}, false
+	}
+	return l.Sent[len(l.Sent)-1], true
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"golang.org/x/image/draw"
+)
+
+const (
+	UploadFileRequired    = "file_required"
+	UploadFileTooLarge    = "file_too_large"
+	UploadUnsupportedType = "unsupported_type"
+	UploadInvalidImage    = "invalid_image"
+
+	// maxImagePixels stops small files that decode to huge images.
+	maxImagePixels = 40_000_000
+)
+
+// UploadPolicy says what an upload of one kind may contain and how it is
+// processed before it is stored.
+type UploadPolicy struct {
+	Max_Size      int64
+	Allowed_Types []string
+	// Images are always decoded and re-encoded, which drops EXIF and GPS
+	// metadata and anything appended to the image data.
+	Thumbnail_Size int
+}
+
+var UploadPolicies = map[string]UploadPolicy{
+	BlobSelfie: {
+		Max_Size:      5 << 20,
+		Allowed_Types: []string{"image/jpeg", "image/png"},
+	},
+	BlobProfilePicture: {
+		Max_Size:       5 << 20,
+		Allowed_Types:  []string{"image/jpeg", "image/png"},
+		Thumbnail_Size: 256,
+	},
+	BlobKycDocument: {
+		Max_Size:      10 << 20,
+		Allowed_Types: []string{"image/jpeg", "image/png", "application/pdf"},
+	},
+}
+
+// UploadError explains why an upload was rejected.
+type UploadError struct {
+	Code          string   `json:"code"`
+	Message       string   `json:"message"`
+	Max_Size      int64    `json:"max_size,omitempty"`
+	Detected_Type string   `json:"detected_type,omitempty"`
+	Allowed_Types []string `json:"allowed_types,omitempty"`
+}
+
+func (e *UploadError) Error() string {
+	return e.Message
+}
+
+// ProcessedUpload is an upload that passed validation, ready to store.
+type ProcessedUpload struct {
+	Data         []byte
+	Content_Type string
+	Ext          string
+	// Thumbnail is a JPEG, set when the policy asks for one.
+	Thumbnail []byte
+}
+
+// ProcessUpload validates r against the policy for kind: it enforces the
+// size limit, detects the real content type from the data rather than the
+// client's claims, and re-encodes images.
+func ProcessUpload(kind string, r io.Reader) (*ProcessedUpload, error) {
+	policy, ok := UploadPolicies[kind]
+	if !ok {
+		return nil, fmt.Errorf("no upload policy for %q", kind)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, policy.Max_Size+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > policy.Max_Size {
+		return nil, &UploadError{
+			Code:     UploadFileTooLarge,
+			Message:  fmt.Sprintf("File is larger than the %d MB limit", policy.Max_Size>>20),
+			Max_Size: policy.Max_Size,
+		}
+	}
+	if len(data) == 0 {
+		return nil, &UploadError{Code: UploadFileRequired, Message: "File is empty"}
+	}
+
+	detected := mimetype.Detect(data)
+	contentType := detected.String()
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if !allowedType(policy.Allowed_Types, contentType) {
+		return nil, &UploadError{
+			Code:          UploadUnsupportedType,
+			Message:       "File type is not allowed",
+			Detected_Type: contentType,
+			Allowed_Types: policy.Allowed_Types,
+		}
+	}
+
+	upload := &ProcessedUpload{Data: data, Content_Type: contentType, Ext: detected.Extension()}
+	if !strings.HasPrefix(contentType, "image/") {
+		return upload, nil
+	}
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Data, err = encodeImage(img, contentType); err != nil {
+		return nil, err
+	}
+	if policy.Thumbnail_Size > 0 {
+		if upload.Thumbnail, err = encodeImage(thumbnail(img, policy.Thumbnail_Size), "image/jpeg"); err != nil {
+			return nil, err
+		}
+	}
+	return upload, nil
+}
+
+// ThumbnailKey is where the thumbnail of the upload stored at key lives.
+func ThumbnailKey(key string) string {
+	if i := strings.LastIndex(key, "."); i > strings.LastIndex(key, "/") {
+		key = key[:i]
+	}
+	return key + "_thumb.jpg"
+}
+
+func allowedType(allowed []string, contentType string) bool {
+	for _, t := range allowed {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeImage(data []byte) (image.Image, error) {
+	invalid := &UploadError{Code: UploadInvalidImage, Message: "Image could not be read"}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, invalid
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return nil, &UploadError{Code: UploadInvalidImage, Message: "Image dimensions are too large"}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, invalid
+	}
+	return img, nil
+}
+
+func encodeImage(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	}
+	return buf.Bytes(), err
+}
+
+// thumbnail scales img to fit in a size x size square, keeping its aspect
+// ratio. Smaller images are left at their size.
+func thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return img
+	}
+	if width >= height {
+		height = max(1, height*size/width)
+		width = size
+	} else {
+		width = max(1, width*size/height)
+		height = size
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
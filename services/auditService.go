@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AuditLogin               = "login"
+	AuditAdminLogin          = "admin_login"
+	AuditBankAdded           = "bank_added"
+	AuditPayout              = "payout"
+	AuditPayoutRefunded      = "payout_refunded"
+	AuditDepositCredited     = "deposit_credited"
+	AuditDepositHeld         = "deposit_held"
+	AuditTransactionReleased = "transaction_released"
+	AuditTransactionRefunded = "transaction_refunded"
+	AuditAccountUnlocked     = "account_unlocked"
+	AuditKycReviewed         = "kyc_reviewed"
+	AuditTwoFactorEnabled    = "two_factor_enabled"
+	AuditTwoFactorDisabled   = "two_factor_disabled"
+	// AuditAdminView is an admin reading other users' personal or
+	// financial data.
+	AuditAdminView = "admin_view"
+
+	AuditActorUser   = "user"
+	AuditActorAdmin  = "admin"
+	AuditActorSystem = "system"
+
+	AuditSucceeded = "succeeded"
+	AuditFailed    = "failed"
+)
+
+// AuditOutcome is the outcome to record for an action that returned err.
+func AuditOutcome(err error) string {
+	if err != nil {
+		return AuditFailed
+	}
+	return AuditSucceeded
+}
+
+// AuditService keeps the audit log. It can only add and read entries; the
+// database user the server runs as should likewise be limited to insert
+// and find on the collection.
+type AuditService interface {
+	Record(context.Context, *models.AuditEvent) error
+	GetAuditEvents(context.Context, string, string, int64) ([]*models.AuditEvent, error)
+}
+
+type AuditServiceImpl struct {
+	auditRepository repository.AuditRepository
+}
+
+func AuditConstructor(auditRepository repository.AuditRepository) AuditService {
+	return &AuditServiceImpl{
+		auditRepository: auditRepository,
+	}
+}
+
+// Record adds event to the log. The write is detached from ctx's
+// cancellation so a client hanging up cannot keep an action it already
+// triggered out of the log.
+func (a *AuditServiceImpl) Record(ctx context.Context, event *models.AuditEvent) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DatabaseTimeout)
+	defer cancel()
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	if event.Created_At.IsZero() {
+		event.Created_At = time.Now()
+	}
+	return a.auditRepository.Create(ctx, event)
+}
+
+// GetAuditEvents lists the newest entries, optionally only those with
+// userID as actor or subject and only those for action.
+func (a *AuditServiceImpl) GetAuditEvents(ctx context.Context, userID, action string, limit int64) ([]*models.AuditEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return a.auditRepository.List(ctx, repository.AuditFilter{User_ID: userID, Action: action}, limit)
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitBreaker stops calls to a provider that keeps failing. After
+// Threshold failures in a row it opens and refuses calls for Cooldown;
+// then it lets one trial call through and closes again if that succeeds.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether a call may go ahead. Every allowed call must be
+// followed by Success, Failure or Release.
+func (b *CircuitBreaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.Threshold {
+		return true
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.Cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// Success closes the breaker.
+func (b *CircuitBreaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure counts a failed call, opening the breaker at the threshold or
+// reopening it when the trial call failed.
+func (b *CircuitBreaker) Failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.Threshold {
+		b.openedAt = b.now()
+	}
+	b.probing = false
+}
+
+// Release ends a call whose outcome says nothing about the provider, such
+// as one the caller cancelled.
+func (b *CircuitBreaker) Release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Open reports whether calls are currently being refused.
+func (b *CircuitBreaker) Open() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.Threshold && (b.probing || b.now().Sub(b.openedAt) < b.Cooldown)
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"sync"
+)
+
+// sentMessage is an email or SMS a fake delivered.
+type sentMessage struct {
+	To, Subject, Body string
+}
+
+// recordingMailer keeps the emails it is asked to send.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []sentMessage
+}
+
+func (m *recordingMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentMessage{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+func (m *recordingMailer) messages() []sentMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]sentMessage(nil), m.sent...)
+}
+
+// recordingSMS keeps the text messages it is asked to send.
+type recordingSMS struct {
+	mu   sync.Mutex
+	sent []sentMessage
+}
+
+func (s *recordingSMS) Send(ctx context.Context, to, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, sentMessage{To: to, Body: message})
+	return nil
+}
+
+var codePattern = regexp.MustCompile(`\b\d{6}\b`)
+
+// lastCode is the one-time code in the last message sent.
+func lastCode(messages []sentMessage) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	return codePattern.FindString(messages[len(messages)-1].Body)
+}
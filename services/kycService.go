@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+)
+
+const (
+	KycStatusOngoing               = "ongoing"
+	KycStatusPendingReview         = "pending_review"
+	KycStatusApproved              = "approved"
+	KycStatusRejected              = "rejected"
+	KycStatusResubmissionRequested = "resubmission_requested"
+
+	KycActionSubmitted             = "submitted"
+	KycActionApproved              = "approved"
+	KycActionRejected              = "rejected"
+	KycActionResubmissionRequested = "resubmission_requested"
+
+	// KycDocumentTier is granted when an admin approves the uploaded ID.
+	KycDocumentTier = 3
+)
+
+// kycSubmittable are the statuses from which documents can be submitted;
+// a submission under review or approved cannot be replaced.
+var kycSubmittable = []string{KycStatusOngoing, KycStatusRejected, KycStatusResubmissionRequested}
+
+var (
+	ErrKycNotPendingReview = errors.New("KYC submission is not pending review")
+	ErrKycAlreadySubmitted = errors.New("KYC documents are already under review or approved")
+	ErrKycReasonRequired   = errors.New("a reason is required")
+	ErrKycFaceNotMatched   = errors.New("the selfie has not been matched to the ID")
+	ErrKycPhoneNotVerified = errors.New("the phone number has not been verified")
+)
+
+type KycService interface {
+	SubmitForReview(context.Context, string, string, string) error
+	GetKycByUserID(context.Context, string) (*models.KYC, error)
+	GetReviewQueue(context.Context, int64, int64) ([]*models.KYC, error)
+	OpenDocument(context.Context, string) (io.ReadCloser, error)
+	Approve(context.Context, string, string) (*models.KYC, error)
+	Reject(context.Context, string, string, string) (*models.KYC, error)
+	RequestResubmission(context.Context, string, string, string) (*models.KYC, error)
+	RecordIdentityCheck(context.Context, string, models.IdentityCheck) error
+	RecordFaceMatch(context.Context, string, float64, bool) error
+	SetSelfie(context.Context, string, string) error
+}
+
+type KycServiceImpl struct {
+	uow            UnitOfWork
+	kycRepository  repository.KycRepository
+	userRepository repository.UserRepository
+	blobStore      BlobStore
+	mailer         Mailer
+	workers        *Workers
+}
+
+func KycConstructor(uow UnitOfWork, kycRepository repository.KycRepository, userRepository repository.UserRepository, blobStore BlobStore, mailer Mailer, workers *Workers) KycService {
+	return &KycServiceImpl{
+		uow:            uow,
+		kycRepository:  kycRepository,
+		userRepository: userRepository,
+		blobStore:      blobStore,
+		mailer:         mailer,
+		workers:        workers,
+	}
+}
+
+// SubmitForReview queues the uploaded document for an admin. A submission
+// already under review or approved cannot be replaced.
+func (k *KycServiceImpl) SubmitForReview(ctx context.Context, userID, documentType, document string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if err := k.kycRepository.Ensure(ctx, userID); err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err := k.kycRepository.Transition(ctx, userID, kycSubmittable, repository.KycChange{
+		Status:        KycStatusPendingReview,
+		Kyc_Docs:      &document,
+		Document_Type: &documentType,
+		Submitted_At:  &now,
+		Review: models.KycReview{
+			Action:     KycActionSubmitted,
+			Status:     KycStatusPendingReview,
+			Created_At: now,
+		},
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrKycAlreadySubmitted
+	}
+	return err
+}
+
+func (k *KycServiceImpl) GetKycByUserID(ctx context.Context, userID string) (*models.KYC, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return k.kycRepository.FindByUserID(ctx, userID)
+}
+
+// GetReviewQueue lists submissions awaiting review, oldest first.
+func (k *KycServiceImpl) GetReviewQueue(ctx context.Context, limit, skip int64) ([]*models.KYC, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return k.kycRepository.ListByStatus(ctx, KycStatusPendingReview, limit, skip)
+}
+
+func (k *KycServiceImpl) OpenDocument(ctx context.Context, key string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return k.blobStore.Get(ctx, key)
+}
+
+func (k *KycServiceImpl) Approve(ctx context.Context, userID, reviewerID string) (*models.KYC, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	// The top tier needs the selfie matched to the ID and a verified phone
+	// on top of the document the admin has looked at.
+	current, err := k.GetKycByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != KycStatusPendingReview {
+		return nil, ErrKycNotPendingReview
+	}
+	if !current.Face_Matched {
+		return nil, ErrKycFaceNotMatched
+	}
+	user, err := k.userRepository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Phone_Verified {
+		return nil, ErrKycPhoneNotVerified
+	}
+	approved := true
+	return k.review(ctx, userID, reviewerID, KycActionApproved, KycStatusApproved, "", KycDocumentTier, &repository.UserUpdate{Kyc_Status: &approved})
+}
+
+func (k *KycServiceImpl) Reject(ctx context.Context, userID, reviewerID, reason string) (*models.KYC, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if reason == "" {
+		return nil, ErrKycReasonRequired
+	}
+	return k.review(ctx, userID, reviewerID, KycActionRejected, KycStatusRejected, reason, -1, nil)
+}
+
+func (k *KycServiceImpl) RequestResubmission(ctx context.Context, userID, reviewerID, reason string) (*models.KYC, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if reason == "" {
+		return nil, ErrKycReasonRequired
+	}
+	return k.review(ctx, userID, reviewerID, KycActionResubmissionRequested, KycStatusResubmissionRequested, reason, -1, nil)
+}
+
+// review records a decision on a pending submission. A negative tier leaves
+// the current tier unchanged. A userUpdate is applied in the same unit of
+// work, so the user never keeps half of a decision.
+func (k *KycServiceImpl) review(ctx context.Context, userID, reviewerID, action, status, reason string, tier int, userUpdate *repository.UserUpdate) (*models.KYC, error) {
+	current, err := k.GetKycByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != KycStatusPendingReview {
+		return nil, ErrKycNotPendingReview
+	}
+	newTier := current.Tier
+	if tier > newTier {
+		newTier = tier
+	}
+	now := time.Now()
+	var kyc *models.KYC
+	err = k.uow.Do(ctx, func(ctx context.Context) error {
+		// Transitioning from pending review makes concurrent reviews of the
+		// same submission safe: only the first decision applies.
+		var err error
+		kyc, err = k.kycRepository.Transition(ctx, userID, []string{KycStatusPendingReview}, repository.KycChange{
+			Status:           status,
+			Rejection_Reason: reason,
+			Tier:             &newTier,
+			Review: models.KycReview{
+				Action:        action,
+				Status:        status,
+				Reason:        reason,
+				Reviewer_ID:   reviewerID,
+				Previous_Tier: current.Tier,
+				New_Tier:      newTier,
+				Created_At:    now,
+			},
+		})
+		if err != nil || userUpdate == nil {
+			return err
+		}
+		return k.userRepository.Update(ctx, userID, *userUpdate)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrKycNotPendingReview
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The email is best effort and must not hold up the review.
+	k.workers.Go(func() {
+		// The review request may be gone by the time this runs.
+		ctx, cancel := context.WithTimeout(context.Background(), DatabaseTimeout)
+		defer cancel()
+		k.notify(ctx, userID, status, reason)
+	})
+	return kyc, nil
+}
+
+// RecordIdentityCheck keeps the outcome of an identity number lookup on the
+// user's KYC record for reviewers.
+func (k *KycServiceImpl) RecordIdentityCheck(ctx context.Context, userID string, check models.IdentityCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return k.kycRepository.AddIdentityCheck(ctx, userID, check)
+}
+
+// RecordFaceMatch stores the latest selfie-to-ID comparison.
+func (k *KycServiceImpl) RecordFaceMatch(ctx context.Context, userID string, score float64, matched bool) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return k.kycRepository.SetFaceMatch(ctx, userID, score, matched)
+}
+
+// SetSelfie stores the key of a new selfie. A new selfie has to be matched
+// again.
+func (k *KycServiceImpl) SetSelfie(ctx context.Context, userID, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return k.kycRepository.SetSelfie(ctx, userID, key)
+}
+
+func (k *KycServiceImpl) notify(ctx context.Context, userID, status, reason string) {
+	user, err := k.userRepository.FindByID(ctx, userID)
+	if err != nil || user.Email == nil {
+		slog.ErrorContext(ctx, "loading user for KYC notification", "user_id", userID, "err", err)
+		return
+	}
+	name := userID
+	if user.Username != nil {
+		name = *user.Username
+	}
+	if err := sendKycStatusEmail(k.mailer, name, *user.Email, status, reason); err != nil {
+		slog.ErrorContext(ctx, "sending KYC notification", "user_id", userID, "err", err)
+	}
+}
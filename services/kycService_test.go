@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newKycService returns a KycService on in-memory repositories with one
+// user, u1, whose phone is verified. Its nil Workers send notifications
+// inline.
+func newKycService(t *testing.T) (KycService, *repository.Repositories, *recordingMailer) {
+	t.Helper()
+	repos := repository.NewMemory()
+	email, username := "u1@example.com", "u1"
+	err := repos.Users.Create(context.Background(), &models.User{
+		ID:             primitive.NewObjectID(),
+		User_ID:        "u1",
+		Email:          &email,
+		Username:       &username,
+		Phone_Verified: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mailer := &recordingMailer{}
+	return KycConstructor(&SerialUnitOfWork{}, repos.Kycs, repos.Users, nil, mailer, nil), repos, mailer
+}
+
+func TestKycReview(t *testing.T) {
+	tests := []struct {
+		name       string
+		review     func(KycService) (*models.KYC, error)
+		wantStatus string
+		wantTier   int
+		wantKyc    bool
+	}{
+		{
+			name:       "approve",
+			review:     func(k KycService) (*models.KYC, error) { return k.Approve(context.Background(), "u1", "admin") },
+			wantStatus: KycStatusApproved,
+			wantTier:   KycDocumentTier,
+			wantKyc:    true,
+		},
+		{
+			name: "reject",
+			review: func(k KycService) (*models.KYC, error) {
+				return k.Reject(context.Background(), "u1", "admin", "blurry")
+			},
+			wantStatus: KycStatusRejected,
+			wantTier:   1,
+		},
+		{
+			name: "request resubmission",
+			review: func(k KycService) (*models.KYC, error) {
+				return k.RequestResubmission(context.Background(), "u1", "admin", "expired")
+			},
+			wantStatus: KycStatusResubmissionRequested,
+			wantTier:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			kycs, repos, mailer := newKycService(t)
+			if err := repos.Kycs.RaiseTier(ctx, "u1", 1); err != nil {
+				t.Fatal(err)
+			}
+			if err := kycs.SubmitForReview(ctx, "u1", "passport", "kyc/u1/passport"); err != nil {
+				t.Fatal(err)
+			}
+			if err := kycs.RecordFaceMatch(ctx, "u1", 1, true); err != nil {
+				t.Fatal(err)
+			}
+			queue, err := kycs.GetReviewQueue(ctx, 10, 0)
+			if err != nil || len(queue) != 1 {
+				t.Fatalf("GetReviewQueue = %d submissions, %v; want 1", len(queue), err)
+			}
+
+			kyc, err := tt.review(kycs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kyc.Status != tt.wantStatus || kyc.Tier != tt.wantTier {
+				t.Errorf("record = %s tier %d, want %s tier %d", kyc.Status, kyc.Tier, tt.wantStatus, tt.wantTier)
+			}
+			if n := len(kyc.Review_History); n != 2 || kyc.Review_History[n-1].Reviewer_ID != "admin" {
+				t.Errorf("review history = %+v", kyc.Review_History)
+			}
+			user, err := repos.Users.FindByID(ctx, "u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Kyc_Status != tt.wantKyc {
+				t.Errorf("Kyc_Status = %v, want %v", user.Kyc_Status, tt.wantKyc)
+			}
+			if sent := mailer.messages(); len(sent) != 1 || sent[0].To != "u1@example.com" {
+				t.Errorf("notifications = %+v, want one to u1@example.com", sent)
+			}
+			if _, err := tt.review(kycs); !errors.Is(err, ErrKycNotPendingReview) {
+				t.Errorf("second review = %v, want ErrKycNotPendingReview", err)
+			}
+		})
+	}
+}
+
+func TestKycSubmitForReview(t *testing.T) {
+	ctx := context.Background()
+	kycs, _, _ := newKycService(t)
+	if err := kycs.SubmitForReview(ctx, "u1", "passport", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := kycs.SubmitForReview(ctx, "u1", "passport", "second"); !errors.Is(err, ErrKycAlreadySubmitted) {
+		t.Fatalf("submitting under review = %v, want ErrKycAlreadySubmitted", err)
+	}
+	if _, err := kycs.Reject(ctx, "u1", "admin", ""); !errors.Is(err, ErrKycReasonRequired) {
+		t.Fatalf("Reject without a reason = %v, want ErrKycReasonRequired", err)
+	}
+	if _, err := kycs.Reject(ctx, "u1", "admin", "blurry"); err != nil {
+		t.Fatal(err)
+	}
+	if err := kycs.SubmitForReview(ctx, "u1", "passport", "second"); err != nil {
+		t.Fatalf("resubmitting after a rejection = %v", err)
+	}
+	kyc, err := kycs.GetKycByUserID(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *kyc.Kyc_Docs != "second" || kyc.Rejection_Reason != "" || kyc.Status != KycStatusPendingReview {
+		t.Fatalf("record = %+v", kyc)
+	}
+}
+
+func TestKycApproveRequiresChecks(t *testing.T) {
+	tests := []struct {
+		name          string
+		faceMatched   bool
+		phoneVerified bool
+		want          error
+	}{
+		{name: "selfie not matched", phoneVerified: true, want: ErrKycFaceNotMatched},
+		{name: "phone not verified", faceMatched: true, want: ErrKycPhoneNotVerified},
+		{name: "both verified", faceMatched: true, phoneVerified: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			kycs, repos, _ := newKycService(t)
+			if !tt.phoneVerified {
+				// A new number has to be verified again.
+				if _, err := repos.Users.SetContact(ctx, "u1", "u1@example.com", "08000000000"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := kycs.SubmitForReview(ctx, "u1", "passport", "kyc/u1/passport"); err != nil {
+				t.Fatal(err)
+			}
+			if err := kycs.RecordFaceMatch(ctx, "u1", 0.5, tt.faceMatched); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := kycs.Approve(ctx, "u1", "admin"); !errors.Is(err, tt.want) {
+				t.Fatalf("Approve = %v, want %v", err, tt.want)
+			}
+			kyc, err := kycs.GetKycByUserID(ctx, "u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approved := kyc.Status == KycStatusApproved; approved != (tt.want == nil) {
+				t.Fatalf("status = %s after Approve returned %v", kyc.Status, tt.want)
+			}
+		})
+	}
+}
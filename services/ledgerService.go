@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	LedgerDeposit      = "deposit"
+	LedgerRelease      = "release"
+	LedgerPayout       = "payout"
+	LedgerPayoutRefund = "payout_refund"
+)
+
+// LedgerService records every balance change. An entry is unique per
+// reference and kind, so the same deposit cannot be entered twice.
+type LedgerService interface {
+	Record(context.Context, *models.LedgerEntry) error
+	GetUserLedger(context.Context, string, int64) ([]*models.LedgerEntry, error)
+}
+
+type LedgerServiceImpl struct {
+	ledgerRepository repository.LedgerRepository
+}
+
+func LedgerConstructor(ledgerRepository repository.LedgerRepository) LedgerService {
+	return &LedgerServiceImpl{
+		ledgerRepository: ledgerRepository,
+	}
+}
+
+func (l *LedgerServiceImpl) Record(ctx context.Context, entry *models.LedgerEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	if entry.Created_At.IsZero() {
+		entry.Created_At = time.Now()
+	}
+	return l.ledgerRepository.Create(ctx, entry)
+}
+
+// GetUserLedger lists the user's newest entries first.
+func (l *LedgerServiceImpl) GetUserLedger(ctx context.Context, userID string, limit int64) ([]*models.LedgerEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return l.ledgerRepository.ListByUserID(ctx, userID, limit)
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// Unlimited disables a single limit in a tier.
+	Unlimited = -1
+
+	LimitSingleDonation = "single_donation"
+	LimitDailyInflow    = "daily_inflow"
+	LimitMonthlyInflow  = "monthly_inflow"
+	LimitDailyPayout    = "daily_payout"
+	LimitMaxBalance     = "max_balance"
+
+	limitKindInflow = "inflow"
+	limitKindPayout = "payout"
+)
+
+// TierLimits holds the limits for one KYC tier, in naira. Unlimited turns a
+// limit off; zero means nothing is allowed.
+type TierLimits struct {
+	Max_Single_Donation int `json:"max_single_donation"`
+	Daily_Inflow        int `json:"daily_inflow"`
+	Monthly_Inflow      int `json:"monthly_inflow"`
+	Daily_Payout        int `json:"daily_payout"`
+	Max_Balance         int `json:"max_balance"`
+}
+
+// DefaultTierLimits is used for any tier not overridden by the configured
+// tier limits.
+var DefaultTierLimits = map[int]TierLimits{
+	0: {Max_Single_Donation: 5000, Daily_Inflow: 20000, Monthly_Inflow: 50000, Daily_Payout: 0, Max_Balance: 50000},
+	1: {Max_Single_Donation: 50000, Daily_Inflow: 200000, Monthly_Inflow: 1000000, Daily_Payout: 50000, Max_Balance: 300000},
+	2: {Max_Single_Donation: 200000, Daily_Inflow: 1000000, Monthly_Inflow: 5000000, Daily_Payout: 500000, Max_Balance: 2000000},
+	3: {Max_Single_Donation: 5000000, Daily_Inflow: 25000000, Monthly_Inflow: Unlimited, Daily_Payout: 5000000, Max_Balance: Unlimited},
+}
+
+// ParseTierLimits returns the limit table, with tiers replaced by the JSON
+// object in raw, e.g. {"1": {"max_single_donation": 20000, ...}}.
+func ParseTierLimits(raw string) (map[int]TierLimits, error) {
+	table := make(map[int]TierLimits, len(DefaultTierLimits))
+	for tier, limits := range DefaultTierLimits {
+		table[tier] = limits
+	}
+	if raw == "" {
+		return table, nil
+	}
+	var overrides map[string]TierLimits
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return nil, fmt.Errorf("TIER_LIMITS: %w", err)
+	}
+	for key, limits := range overrides {
+		tier, err := strconv.Atoi(key)
+		if err != nil || tier < 0 {
+			return nil, fmt.Errorf("TIER_LIMITS: invalid tier %q", key)
+		}
+		table[tier] = limits
+	}
+	return table, nil
+}
+
+// LimitError describes the limit an operation would breach.
+type LimitError struct {
+	Limit     string `json:"limit"`
+	Tier      int    `json:"tier"`
+	Max       int    `json:"max"`
+	Remaining int    `json:"remaining"`
+}
+
+func (e *LimitError) Error() string {
+	switch e.Limit {
+	case LimitSingleDonation:
+		return fmt.Sprintf("a single donation cannot exceed %d for tier %d accounts", e.Max, e.Tier)
+	case LimitDailyInflow:
+		return fmt.Sprintf("daily donation limit of %d for tier %d accounts reached, %d remaining", e.Max, e.Tier, e.Remaining)
+	case LimitMonthlyInflow:
+		return fmt.Sprintf("monthly donation limit of %d for tier %d accounts reached, %d remaining", e.Max, e.Tier, e.Remaining)
+	case LimitDailyPayout:
+		return fmt.Sprintf("daily withdrawal limit of %d for tier %d accounts reached, %d remaining", e.Max, e.Tier, e.Remaining)
+	case LimitMaxBalance:
+		return fmt.Sprintf("balance cannot exceed %d for tier %d accounts, %d remaining", e.Max, e.Tier, e.Remaining)
+	}
+	return "transaction limit exceeded"
+}
+
+// LimitSummary is the current tier, its limits and what has been used.
+type LimitSummary struct {
+	Tier           int        `json:"tier"`
+	Limits         TierLimits `json:"limits"`
+	Daily_Inflow   int        `json:"daily_inflow"`
+	Monthly_Inflow int        `json:"monthly_inflow"`
+	Daily_Payout   int        `json:"daily_payout"`
+	Balance        int        `json:"balance"`
+}
+
+// LimitService enforces the per-tier limits. Check* return a *LimitError
+// when the amount would breach a limit; Record* add to the usage that the
+// daily and monthly limits are measured against.
+type LimitService interface {
+	CheckInflow(context.Context, *models.User, int) error
+	CheckPayout(context.Context, *models.User, int) error
+	RecordInflow(context.Context, string, int, string) error
+	RecordPayout(context.Context, string, int, string) error
+	RemoveUsage(context.Context, string) error
+	GetLimitSummary(context.Context, *models.User) (*LimitSummary, error)
+}
+
+type LimitServiceImpl struct {
+	usageRepository repository.LimitUsageRepository
+	kycRepository   repository.KycRepository
+	limits          map[int]TierLimits
+}
+
+func LimitConstructor(usageRepository repository.LimitUsageRepository, kycRepository repository.KycRepository, limits map[int]TierLimits) LimitService {
+	return &LimitServiceImpl{
+		usageRepository: usageRepository,
+		kycRepository:   kycRepository,
+		limits:          limits,
+	}
+}
+
+func (l *LimitServiceImpl) CheckInflow(ctx context.Context, user *models.User, amount int) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	summary, err := l.GetLimitSummary(ctx, user)
+	if err != nil {
+		return err
+	}
+	limits := summary.Limits
+	if exceeds(limits.Max_Single_Donation, 0, amount) {
+		return summary.breach(LimitSingleDonation, limits.Max_Single_Donation, 0)
+	}
+	if exceeds(limits.Daily_Inflow, summary.Daily_Inflow, amount) {
+		return summary.breach(LimitDailyInflow, limits.Daily_Inflow, summary.Daily_Inflow)
+	}
+	if exceeds(limits.Monthly_Inflow, summary.Monthly_Inflow, amount) {
+		return summary.breach(LimitMonthlyInflow, limits.Monthly_Inflow, summary.Monthly_Inflow)
+	}
+	if exceeds(limits.Max_Balance, summary.Balance, amount) {
+		return summary.breach(LimitMaxBalance, limits.Max_Balance, summary.Balance)
+	}
+	return nil
+}
+
+func (l *LimitServiceImpl) CheckPayout(ctx context.Context, user *models.User, amount int) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	summary, err := l.GetLimitSummary(ctx, user)
+	if err != nil {
+		return err
+	}
+	if exceeds(summary.Limits.Daily_Payout, summary.Daily_Payout, amount) {
+		return summary.breach(LimitDailyPayout, summary.Limits.Daily_Payout, summary.Daily_Payout)
+	}
+	return nil
+}
+
+func (l *LimitServiceImpl) RecordInflow(ctx context.Context, userID string, amount int, reference string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return l.record(ctx, userID, limitKindInflow, amount, reference)
+}
+
+func (l *LimitServiceImpl) RecordPayout(ctx context.Context, userID string, amount int, reference string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return l.record(ctx, userID, limitKindPayout, amount, reference)
+}
+
+// RemoveUsage drops the usage recorded under reference, for a payout that
+// was refunded.
+func (l *LimitServiceImpl) RemoveUsage(ctx context.Context, reference string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return l.usageRepository.DeleteByReference(ctx, reference)
+}
+
+func (l *LimitServiceImpl) record(ctx context.Context, userID, kind string, amount int, reference string) error {
+	return l.usageRepository.Create(ctx, &models.LimitUsage{
+		ID:         primitive.NewObjectID(),
+		User_ID:    userID,
+		Kind:       kind,
+		Amount:     amount,
+		Reference:  reference,
+		Created_At: time.Now(),
+	})
+}
+
+func (l *LimitServiceImpl) GetLimitSummary(ctx context.Context, user *models.User) (*LimitSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	tier, err := l.tierFor(ctx, user.User_ID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	summary := &LimitSummary{
+		Tier:    tier,
+		Limits:  l.limitsFor(ctx, tier),
+		Balance: user.Balance,
+	}
+	if summary.Daily_Inflow, err = l.usageRepository.Sum(ctx, user.User_ID, limitKindInflow, startOfDay); err != nil {
+		return nil, err
+	}
+	if summary.Monthly_Inflow, err = l.usageRepository.Sum(ctx, user.User_ID, limitKindInflow, startOfMonth); err != nil {
+		return nil, err
+	}
+	if summary.Daily_Payout, err = l.usageRepository.Sum(ctx, user.User_ID, limitKindPayout, startOfDay); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// tierFor reads the tier from the user's KYC record. Users who have not
+// started KYC are tier 0.
+func (l *LimitServiceImpl) tierFor(ctx context.Context, userID string) (int, error) {
+	kyc, err := l.kycRepository.FindByUserID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return kyc.Tier, nil
+}
+
+// limitsFor falls back to the highest configured tier below tier, so a
+// table without an entry for a new tier never grants more than intended.
+func (l *LimitServiceImpl) limitsFor(ctx context.Context, tier int) TierLimits {
+	for t := tier; t >= 0; t-- {
+		if limits, ok := l.limits[t]; ok {
+			return limits
+		}
+	}
+	return TierLimits{}
+}
+
+func (s *LimitSummary) breach(limit string, max, used int) *LimitError {
+	remaining := max - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &LimitError{Limit: limit, Tier: s.Tier, Max: max, Remaining: remaining}
+}
+
+func exceeds(max, used, amount int) bool {
+	return max != Unlimited && used+amount > max
+}
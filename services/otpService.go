@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	helper "github.com/JayJosh846/donationPlatform/utils"
+)
+
+const (
+	OtpPurposeEmailVerification = "email_verification"
+
+	otpMaxAttempts    = 5
+	otpResendCooldown = time.Minute
+)
+
+var (
+	ErrOtpInvalid         = errors.New("OTP not valid")
+	ErrOtpExpired         = errors.New("OTP expired")
+	ErrOtpTooManyAttempts = errors.New("too many incorrect attempts, request a new OTP")
+)
+
+// OtpCooldownError is returned when a new code is requested before the
+// resend cooldown of the previous one has passed.
+type OtpCooldownError struct {
+	Retry_After time.Duration
+}
+
+func (e *OtpCooldownError) Error() string {
+	return fmt.Sprintf("please wait %d seconds before requesting another OTP", int(e.Retry_After.Seconds()+0.5))
+}
+
+// OtpService issues one-time codes bound to a user and purpose. Only a keyed
+// hash of each code is stored; a user holds at most one live code per
+// purpose and it is consumed by the first successful verification.
+type OtpService interface {
+	Issue(context.Context, string, string, time.Duration) (string, error)
+	Verify(context.Context, string, string, string) error
+}
+
+type OtpServiceImpl struct {
+	otpRepository repository.OtpRepository
+}
+
+func OtpConstructor(otpRepository repository.OtpRepository) OtpService {
+	return &OtpServiceImpl{
+		otpRepository: otpRepository,
+	}
+}
+
+// Issue replaces any previous code for the user and purpose with a new one
+// and returns the plaintext code for delivery. It never stores the code.
+func (o *OtpServiceImpl) Issue(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	code, err := helper.GenerateVerificationCode()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = o.otpRepository.Replace(ctx, &models.Otp{
+		User_ID:      userID,
+		Purpose:      purpose,
+		Code_Hash:    helper.HashOtp(userID, purpose, code),
+		Expires_At:   now.Add(ttl),
+		Last_Sent_At: now,
+		Created_At:   now,
+	}, now.Add(-otpResendCooldown))
+	if errors.Is(err, repository.ErrDuplicate) {
+		return "", o.cooldownError(ctx, userID, purpose, now)
+	}
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+func (o *OtpServiceImpl) cooldownError(ctx context.Context, userID, purpose string, now time.Time) error {
+	otp, err := o.otpRepository.Find(ctx, userID, purpose)
+	if err != nil {
+		return &OtpCooldownError{Retry_After: otpResendCooldown}
+	}
+	return &OtpCooldownError{Retry_After: otp.Last_Sent_At.Add(otpResendCooldown).Sub(now)}
+}
+
+// Verify checks code against the live code for the user and purpose and
+// consumes it on success. Every attempt counts towards the limit, and the
+// counter is bumped before comparing so parallel guesses cannot exceed it.
+func (o *OtpServiceImpl) Verify(ctx context.Context, userID, purpose, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	otp, err := o.otpRepository.Attempt(ctx, userID, purpose, otpMaxAttempts)
+	if errors.Is(err, repository.ErrNotFound) {
+		if _, findErr := o.otpRepository.Find(ctx, userID, purpose); findErr == nil {
+			return ErrOtpTooManyAttempts
+		}
+		return ErrOtpInvalid
+	}
+	if err != nil {
+		return err
+	}
+	// The TTL monitor only runs once a minute, so expiry is checked here too.
+	if time.Now().After(otp.Expires_At) {
+		o.otpRepository.Delete(ctx, otp)
+		return ErrOtpExpired
+	}
+	expected := helper.HashOtp(userID, purpose, code)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(otp.Code_Hash)) != 1 {
+		return ErrOtpInvalid
+	}
+	deleted, err := o.otpRepository.Delete(ctx, otp)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		// Another request consumed the code first.
+		return ErrOtpInvalid
+	}
+	return nil
+}
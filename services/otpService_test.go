@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	helper "github.com/JayJosh846/donationPlatform/utils"
+)
+
+func TestOtpVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		// guesses are tried before code, the code that was issued.
+		guesses []string
+		expired bool
+		want    error
+	}{
+		{name: "right code", want: nil},
+		{name: "after a wrong guess", guesses: []string{"000000"}, want: nil},
+		{name: "after four wrong guesses", guesses: []string{"1", "2", "3", "4"}, want: nil},
+		{name: "after five wrong guesses", guesses: []string{"1", "2", "3", "4", "5"}, want: ErrOtpTooManyAttempts},
+		{name: "expired", expired: true, want: ErrOtpExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			otps := repository.NewMemoryOtpRepository()
+			service := OtpConstructor(otps)
+			code, err := service.Issue(ctx, "u1", OtpPurposeEmailVerification, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.expired {
+				past := time.Now().Add(-2 * time.Minute)
+				otps.Replace(ctx, &models.Otp{
+					User_ID:      "u1",
+					Purpose:      OtpPurposeEmailVerification,
+					Code_Hash:    helper.HashOtp("u1", OtpPurposeEmailVerification, code),
+					Expires_At:   past.Add(time.Minute),
+					Last_Sent_At: past,
+				}, time.Now())
+			}
+			for _, guess := range tt.guesses {
+				if err := service.Verify(ctx, "u1", OtpPurposeEmailVerification, guess); !errors.Is(err, ErrOtpInvalid) {
+					t.Fatalf("Verify(%s) = %v, want ErrOtpInvalid", guess, err)
+				}
+			}
+			if err := service.Verify(ctx, "u1", OtpPurposeEmailVerification, code); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOtpIsConsumed(t *testing.T) {
+	ctx := context.Background()
+	service := OtpConstructor(repository.NewMemoryOtpRepository())
+	code, err := service.Issue(ctx, "u1", TwoFactorPurposeLogin, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Verify(ctx, "u1", TwoFactorPurposePayout, code); !errors.Is(err, ErrOtpInvalid) {
+		t.Fatalf("Verify for another purpose = %v, want ErrOtpInvalid", err)
+	}
+	if err := service.Verify(ctx, "u1", TwoFactorPurposeLogin, code); err != nil {
+		t.Fatalf("Verify = %v", err)
+	}
+	if err := service.Verify(ctx, "u1", TwoFactorPurposeLogin, code); !errors.Is(err, ErrOtpInvalid) {
+		t.Fatalf("second Verify = %v, want ErrOtpInvalid", err)
+	}
+}
+
+func TestOtpResendCooldown(t *testing.T) {
+	ctx := context.Background()
+	service := OtpConstructor(repository.NewMemoryOtpRepository())
+	if _, err := service.Issue(ctx, "u1", TwoFactorPurposeLogin, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	_, err := service.Issue(ctx, "u1", TwoFactorPurposeLogin, time.Minute)
+	var cooldown *OtpCooldownError
+	if !errors.As(err, &cooldown) {
+		t.Fatalf("second Issue = %v, want an OtpCooldownError", err)
+	}
+	if cooldown.Retry_After <= 0 || cooldown.Retry_After > otpResendCooldown {
+		t.Fatalf("Retry_After = %v", cooldown.Retry_After)
+	}
+	if _, err := service.Issue(ctx, "u2", TwoFactorPurposeLogin, time.Minute); err != nil {
+		t.Fatalf("Issue for another user = %v", err)
+	}
+}
@@ -1,44 +1,49 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
-	"os"
+	"net/url"
 
+	"github.com/JayJosh846/donationPlatform/config"
 	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
 	helper "github.com/JayJosh846/donationPlatform/utils"
-
 	// "go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type PaymentService interface {
-	Payin(amount string, user models.User) (string, error)
-	PaymentGetUser(*string) (*models.User, error)
-	VerifyDeposit(eventData []byte) (WebhookPayload, error)
-	GetBanks() (string, error)
-	VerifyAccountNumber(string, string) (string, error)
-	TransferRecipientCreation(string, string, string) (string, error)
-	InitiateTransfer(int, string) (string, error)
+	Payin(ctx context.Context, amount string, user models.User) (*PaystackInitializeResponse, error)
+	PaymentGetUser(context.Context, *string) (*models.User, error)
+	VerifyDeposit(ctx context.Context, eventData []byte) (WebhookPayload, error)
+	GetBanks(context.Context) (*PaystackBanksResponse, error)
+	VerifyAccountNumber(context.Context, string, string) (*PaystackAccountResponse, error)
+	TransferRecipientCreation(context.Context, string, string, string) (*PaystackRecipientResponse, error)
+	InitiateTransfer(context.Context, int, string, string) (*PaystackTransferResponse, error)
+	RefundCharge(context.Context, string) (*PaystackRefundResponse, error)
 }
 
 type PaymentServiceImpl struct {
-	paymentCollection *mongo.Collection
-	ctx               context.Context
+	userRepository repository.UserRepository
+	paystack       *ProviderClient
 }
 
-func PaymentConstructor(paymentCollection *mongo.Collection, ctx context.Context) PaymentService {
+func PaymentConstructor(userRepository repository.UserRepository, paystack *ProviderClient) PaymentService {
 	return &PaymentServiceImpl{
-		paymentCollection: paymentCollection,
-		ctx:               ctx,
+		userRepository: userRepository,
+		paystack:       paystack,
 	}
 }
 
+// NewPaystackClient builds the provider client for the Paystack API.
+func NewPaystackClient(cfg config.PaystackConfig, providers config.ProvidersConfig) *ProviderClient {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+cfg.Secret_Key)
+	return NewProviderClient("paystack", cfg.Base_URL, header, providers)
+}
+
 type PaymentRequest struct {
 	Amount    string   `json:"amount"`
 	Email     string   `json:"email"`
@@ -63,9 +68,75 @@ type WebhookPayload struct {
 
 type Bank struct {
 	Name string `json:"name"`
+	Slug string `json:"slug"`
 	Code string `json:"code"`
 }
 
+// Paystack wraps every response in the same envelope. A false Status
+// means the request was refused even when the HTTP status was 200.
+type PaystackInitializeResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Authorization_URL string `json:"authorization_url"`
+		Access_Code       string `json:"access_code"`
+		Reference         string `json:"reference"`
+	} `json:"data"`
+}
+
+type PaystackBanksResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    []Bank `json:"data"`
+}
+
+type PaystackAccountResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Account_Number string `json:"account_number"`
+		Account_Name   string `json:"account_name"`
+		Bank_ID        int    `json:"bank_id"`
+	} `json:"data"`
+}
+
+type PaystackRecipientResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Active         bool   `json:"active"`
+		Recipient_Code string `json:"recipient_code"`
+	} `json:"data"`
+}
+
+type PaystackTransferResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Reference     string `json:"reference"`
+		Transfer_Code string `json:"transfer_code"`
+		Status        string `json:"status"`
+	} `json:"data"`
+}
+
+type PaystackRefundResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Amount int    `json:"amount"`
+		Status string `json:"status"`
+	} `json:"data"`
+}
+
+// paystackRefused turns a response Paystack answered with status false into
+// a ProviderError.
+func paystackRefused(status bool, message string) error {
+	if status {
+		return nil
+	}
+	return &ProviderError{Provider: "paystack", Status: http.StatusOK, Message: message, Kind: ErrProviderRejected}
+}
+
 type RecipientCreationRequest struct {
 	Type           string `json:"type"`
 	Name           string `json:"name"`
@@ -78,260 +149,183 @@ type TransferRequest struct {
 	Source         string `json:"source"`
 	Amount         int    `json:"amount"`
 	Recipient_code string `json:"recipient"`
+	Reference      string `json:"reference"`
 }
 
-func (u *PaymentServiceImpl) PaymentGetUser(email *string) (*models.User, error) {
-	var user *models.User
-	query := bson.M{"email": email}
-	err := u.paymentCollection.FindOne(u.ctx, query).Decode(&user)
-	return user, err
+func (u *PaymentServiceImpl) PaymentGetUser(ctx context.Context, email *string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if email == nil {
+		return nil, repository.ErrNotFound
+	}
+	return u.userRepository.FindByEmail(ctx, *email)
 }
 
-func (u *PaymentServiceImpl) Payin(amount string, user models.User) (string, error) {
-	// u.
-	// var paymentRequest PaymentRequest
-	secKey := os.Getenv("PAYSTACK_SEC_KEY")
-	token := secKey
-	url := "https://api.paystack.co/transaction/initialize"
-	method := "POST"
-
-	reference := helper.GenerateTransactionReference()
+func (u *PaymentServiceImpl) Payin(ctx context.Context, amount string, user models.User) (*PaystackInitializeResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+	defer cancel()
 	paymentRequest := PaymentRequest{
 		Amount:    amount,
 		Email:     *user.Email,
 		Currency:  "NGN",
-		Reference: reference,
+		Reference: helper.GenerateTransactionReference(),
 		Channels:  []string{"card", "bank", "ussd", "mobile_money", "qr", "bank_transfer"},
 	}
-	requestBodyJSON, err := json.Marshal(paymentRequest)
-	if err != nil {
-		fmt.Println("Error marshaling JSON:", err)
-		return "", err
-	}
-	bodyReader := bytes.NewReader([]byte(requestBodyJSON))
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, bodyReader)
+	// Paystack refuses a second initialization with the same reference, so
+	// this call is not retried.
+	var response PaystackInitializeResponse
+	err := u.paystack.Do(ctx, ProviderCall{
+		Method: http.MethodPost,
+		Path:   "/transaction/initialize",
+		Body:   paymentRequest,
+	}, &response)
 	if err != nil {
-		fmt.Println(err)
-		return "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("Authorization", "Bearer "+token)
-
-	res, err := client.Do(req)
-	if err != nil {
-		fmt.Println(err)
-		return "", err
+		return nil, err
 	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		fmt.Println(err)
-		return "", err
-
+	if err := paystackRefused(response.Status, response.Message); err != nil {
+		return nil, err
 	}
-	fmt.Println("ctx", u.ctx)
-	return string(body), nil
+	return &response, nil
 }
 
-func (u *PaymentServiceImpl) VerifyDeposit(eventData []byte) (WebhookPayload, error) {
+func (u *PaymentServiceImpl) VerifyDeposit(ctx context.Context, eventData []byte) (WebhookPayload, error) {
 
 	var webhookPayload WebhookPayload
 	if err := json.Unmarshal(eventData, &webhookPayload); err != nil {
-		fmt.Println("Error:", err)
 		return WebhookPayload{}, err
 	}
-	fmt.Println("Payload:", webhookPayload)
+	slog.InfoContext(ctx, "paystack webhook", "event", webhookPayload.Event, "reference", webhookPayload.Data.Reference, "status", webhookPayload.Data.Status)
 	return webhookPayload, nil
 }
 
-func (u *PaymentServiceImpl) GetBanks() (string, error) {
-	secKey := os.Getenv("PAYSTACK_SEC_KEY")
-	token := secKey
-	url := "https://api.paystack.co/bank"
-	method := "GET"
-
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
+func (u *PaymentServiceImpl) GetBanks(ctx context.Context) (*PaystackBanksResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+	defer cancel()
+	var response PaystackBanksResponse
+	err := u.paystack.Do(ctx, ProviderCall{Method: http.MethodGet, Path: "/bank"}, &response)
 	if err != nil {
-		fmt.Println(err)
-		return "", err
+		return nil, err
+	}
+	if err := paystackRefused(response.Status, response.Message); err != nil {
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("Authorization", "Bearer "+token)
+	return &response, nil
+}
 
-	res, err := client.Do(req)
+// bankCode looks up the Paystack code of the bank with the given name.
+func (u *PaymentServiceImpl) bankCode(ctx context.Context, bank string) (string, error) {
+	banks, err := u.GetBanks(ctx)
 	if err != nil {
-		fmt.Println(err)
 		return "", err
 	}
-	defer res.Body.Close()
-	// Read the response body
-	responseBody, err := io.ReadAll(res.Body)
-	if err != nil {
-		fmt.Println("Error reading response:", err)
-		return "", err
+	for _, b := range banks.Data {
+		if bank == b.Name {
+			return b.Code, nil
+		}
 	}
-	return string(responseBody), nil
-
+	return "", &ProviderError{Provider: "paystack", Message: "Bank not found in the bank list", Kind: ErrProviderNotFound}
 }
 
-func (u *PaymentServiceImpl) VerifyAccountNumber(accountNumber string, bank string) (string, error) {
-	secKey := os.Getenv("PAYSTACK_SEC_KEY")
-	token := secKey
-	method := "GET"
-	// Retrieve the list of banks as a JSON string
-	jsonString, err := u.GetBanks()
+func (u *PaymentServiceImpl) VerifyAccountNumber(ctx context.Context, accountNumber string, bank string) (*PaystackAccountResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+	defer cancel()
+	code, err := u.bankCode(ctx, bank)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	var bankData struct {
-		Data []Bank `json:"data"`
+	var response PaystackAccountResponse
+	err = u.paystack.Do(ctx, ProviderCall{
+		Method: http.MethodGet,
+		Path:   "/bank/resolve",
+		Query:  url.Values{"account_number": {accountNumber}, "bank_code": {code}},
+	}, &response)
+	if err != nil {
+		return nil, err
 	}
-	errr := json.Unmarshal([]byte(jsonString), &bankData)
-	if errr != nil {
-		fmt.Println("Error unmarshaling JSON:", err)
-		return "", err
+	if err := paystackRefused(response.Status, response.Message); err != nil {
+		return nil, err
 	}
-
-	// Extract the slice of Bank structs
-	banks := bankData.Data
-	for _, b := range banks {
-		if bank == b.Name {
-			url := fmt.Sprintf("https://api.paystack.co/bank/resolve?account_number=%s&bank_code=%s", accountNumber, b.Code)
-			client := &http.Client{}
-			req, err := http.NewRequest(method, url, nil)
-			if err != nil {
-				return "", err
-			}
-			// Add the authorization header
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Add("Authorization", "Bearer "+token)
-			// Send the request
-			resp, err := client.Do(req)
-			if err != nil {
-				return "", err
-			}
-			defer resp.Body.Close()
-			responseBody, err := io.ReadAll(resp.Body)
-			if err != nil {
-				fmt.Println("Error reading response:", err)
-				return "", err
-			}
-			fmt.Println("responseBody", string(responseBody))
-			return string(responseBody), nil
-		}
-	}
-
-	return "", fmt.Errorf("Bank not found in the bank list")
+	return &response, nil
 }
 
-func (u *PaymentServiceImpl) TransferRecipientCreation(username string, accountNumber string, bank string) (string, error) {
-	secKey := os.Getenv("PAYSTACK_SEC_KEY")
-	token := secKey
-	url := "https://api.paystack.co/transferrecipient"
-	method := "POST"
-	// Retrieve the list of banks as a JSON string
-	jsonString, err := u.GetBanks()
+func (u *PaymentServiceImpl) TransferRecipientCreation(ctx context.Context, username string, accountNumber string, bank string) (*PaystackRecipientResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+	defer cancel()
+	code, err := u.bankCode(ctx, bank)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	var bankData struct {
-		Data []Bank `json:"data"`
+	transferRecipient := RecipientCreationRequest{
+		Type:           "nuban",
+		Name:           username,
+		Account_number: accountNumber,
+		Bank_code:      code,
+		Currency:       "NGN",
 	}
-	errr := json.Unmarshal([]byte(jsonString), &bankData)
-	if errr != nil {
-		fmt.Println("Error unmarshaling JSON:", err)
-		return "", err
+	// Paystack returns the existing recipient for an account it already
+	// knows, so creating one is safe to repeat.
+	var response PaystackRecipientResponse
+	err = u.paystack.Do(ctx, ProviderCall{
+		Method:     http.MethodPost,
+		Path:       "/transferrecipient",
+		Body:       transferRecipient,
+		Idempotent: true,
+	}, &response)
+	if err != nil {
+		return nil, err
 	}
-
-	// Extract the slice of Bank structs
-	banks := bankData.Data
-	for _, b := range banks {
-		if bank == b.Name {
-			transferRecipient := RecipientCreationRequest{
-				Type:           "nuban",
-				Name:           username,
-				Account_number: accountNumber,
-				Bank_code:      b.Code,
-				Currency:       "NGN",
-			}
-			requestBodyJSON, err := json.Marshal(transferRecipient)
-			if err != nil {
-				fmt.Println("Error marshaling JSON:", err)
-				return "", err
-			}
-			bodyReader := bytes.NewReader([]byte(requestBodyJSON))
-			client := &http.Client{}
-			req, err := http.NewRequest(method, url, bodyReader)
-			if err != nil {
-				fmt.Println(err)
-				return "", err
-			}
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Add("Authorization", "Bearer "+token)
-
-			res, err := client.Do(req)
-			if err != nil {
-				fmt.Println(err)
-				return "", err
-			}
-			defer res.Body.Close()
-
-			body, err := io.ReadAll(res.Body)
-			if err != nil {
-				fmt.Println(err)
-				return "", err
-
-			}
-			fmt.Println("ctx", u.ctx)
-			return string(body), nil
-		}
+	if err := paystackRefused(response.Status, response.Message); err != nil {
+		return nil, err
 	}
-	return "", fmt.Errorf("Something went wrong while creating reciept. Please try again")
-
+	return &response, nil
 }
 
-func (u *PaymentServiceImpl) InitiateTransfer(amount int, recipientCode string) (string, error) {
-	secKey := os.Getenv("PAYSTACK_SEC_KEY")
-	token := secKey
-	url := "https://api.paystack.co/transfer"
-	method := "POST"
-
+// InitiateTransfer pays amount, in kobo, to the recipient. The reference
+// is ours and comes back on the transfer webhooks that settle the payout.
+func (u *PaymentServiceImpl) InitiateTransfer(ctx context.Context, amount int, recipientCode, reference string) (*PaystackTransferResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+	defer cancel()
 	transferRequest := TransferRequest{
 		Source:         "balance",
 		Amount:         amount,
 		Recipient_code: recipientCode,
+		Reference:      reference,
 	}
-	requestBodyJSON, err := json.Marshal(transferRequest)
+	// A transfer moves money; repeating it after a timeout could pay out
+	// twice, so it is never retried.
+	var response PaystackTransferResponse
+	err := u.paystack.Do(ctx, ProviderCall{
+		Method: http.MethodPost,
+		Path:   "/transfer",
+		Body:   transferRequest,
+	}, &response)
 	if err != nil {
-		fmt.Println("Error marshaling JSON:", err)
-		return "", err
+		return nil, err
 	}
-	bodyReader := bytes.NewReader([]byte(requestBodyJSON))
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, bodyReader)
-	if err != nil {
-		fmt.Println(err)
-		return "", err
+	if err := paystackRefused(response.Status, response.Message); err != nil {
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("Authorization", "Bearer "+token)
+	return &response, nil
+}
 
-	res, err := client.Do(req)
+// RefundCharge returns the whole charge with reference to the customer who
+// paid it. Paystack refuses to refund a charge twice.
+func (u *PaymentServiceImpl) RefundCharge(ctx context.Context, reference string) (*PaystackRefundResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+	defer cancel()
+	// A retry after a lost response would be refused as a second refund, so
+	// the call is made once and a failure is left to the caller.
+	var response PaystackRefundResponse
+	err := u.paystack.Do(ctx, ProviderCall{
+		Method: http.MethodPost,
+		Path:   "/refund",
+		Body:   map[string]string{"transaction": reference},
+	}, &response)
 	if err != nil {
-		fmt.Println(err)
-		return "", err
+		return nil, err
 	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		fmt.Println(err)
-		return "", err
-
+	if err := paystackRefused(response.Status, response.Message); err != nil {
+		return nil, err
 	}
-	fmt.Println("ctx", u.ctx)
-	return string(body), nil
+	return &response, nil
 }
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/config"
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/paystackfake"
+)
+
+const testPaystackKey = "sk_test_key"
+
+// newFakePaystack serves a fake Paystack and returns a PaymentService
+// talking to it. Retries back off for at most a millisecond.
+func newFakePaystack(t *testing.T, cfg paystackfake.Config) (PaymentService, *paystackfake.Server) {
+	t.Helper()
+	cfg.Secret_Key = testPaystackKey
+	fake := paystackfake.New(cfg)
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client := NewPaystackClient(
+		config.PaystackConfig{Secret_Key: testPaystackKey, Base_URL: server.URL},
+		config.ProvidersConfig{
+			Timeout:           time.Second,
+			Max_Retries:       2,
+			Retry_Backoff:     time.Millisecond,
+			Retry_Max_Backoff: time.Millisecond,
+			Breaker_Threshold: 100,
+			Breaker_Cooldown:  time.Second,
+		},
+	)
+	return PaymentConstructor(nil, client), fake
+}
+
+func TestPayin(t *testing.T) {
+	ctx := context.Background()
+	payments, fake := newFakePaystack(t, paystackfake.Config{})
+	email := "ada@example.com"
+	response, err := payments.Payin(ctx, "500000", models.User{Email: &email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction, ok := fake.Transaction(response.Data.Reference)
+	if !ok {
+		t.Fatalf("the fake has no transaction %s", response.Data.Reference)
+	}
+	if transaction.Amount != 500000 || transaction.Email != email || transaction.Status != "abandoned" {
+		t.Fatalf("transaction = %+v", transaction)
+	}
+	if response.Data.Authorization_URL == "" || response.Data.Access_Code != transaction.Access_Code {
+		t.Fatalf("response = %+v", response.Data)
+	}
+}
+
+func TestPaystackRetries(t *testing.T) {
+	email := "ada@example.com"
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		fault  paystackfake.Fault
+		call   func(PaymentService) error
+		// want is nil when the call should succeed on a retry.
+		want error
+	}{
+		{
+			name:   "bank list is retried",
+			method: http.MethodGet,
+			path:   "/bank",
+			fault:  paystackfake.Fault{Status: http.StatusServiceUnavailable},
+			call: func(p PaymentService) error {
+				_, err := p.GetBanks(context.Background())
+				return err
+			},
+		},
+		{
+			name:   "dropped bank list is retried",
+			method: http.MethodGet,
+			path:   "/bank",
+			fault:  paystackfake.Fault{Drop: true},
+			call: func(p PaymentService) error {
+				_, err := p.GetBanks(context.Background())
+				return err
+			},
+		},
+		{
+			name:   "payin is not retried",
+			method: http.MethodPost,
+			path:   "/transaction/initialize",
+			fault:  paystackfake.Fault{Status: http.StatusInternalServerError},
+			call: func(p PaymentService) error {
+				_, err := p.Payin(context.Background(), "1000", models.User{Email: &email})
+				return err
+			},
+			want: ErrProviderUnavailable,
+		},
+		{
+			name:   "transfer is not retried",
+			method: http.MethodPost,
+			path:   "/transfer",
+			fault:  paystackfake.Fault{Status: http.StatusBadGateway},
+			call: func(p PaymentService) error {
+				_, err := p.InitiateTransfer(context.Background(), 1000, "RCP_unknown", "ref")
+				return err
+			},
+			want: ErrProviderUnavailable,
+		},
+		{
+			name:   "refusals are not retried",
+			method: http.MethodGet,
+			path:   "/bank",
+			fault:  paystackfake.Fault{Status: http.StatusBadRequest, Message: "Bad request"},
+			call: func(p PaymentService) error {
+				_, err := p.GetBanks(context.Background())
+				return err
+			},
+			want: ErrProviderRejected,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments, fake := newFakePaystack(t, paystackfake.Config{})
+			fake.Inject(tt.method, tt.path, 1, tt.fault)
+			if err := tt.call(payments); !errors.Is(err, tt.want) {
+				t.Fatalf("call = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaystackAccounts(t *testing.T) {
+	ctx := context.Background()
+	payments, fake := newFakePaystack(t, paystackfake.Config{})
+	fake.AddAccount("058", "0123456789", "ADA LOVELACE")
+
+	tests := []struct {
+		account, bank string
+		want          error
+		wantName      string
+	}{
+		{"0123456789", "Guaranty Trust Bank", nil, "ADA LOVELACE"},
+		{"0123456789", "Zenith Bank", ErrProviderRejected, ""},
+		{"0123456789", "Bank of Nowhere", ErrProviderNotFound, ""},
+	}
+	for _, tt := range tests {
+		account, err := payments.VerifyAccountNumber(ctx, tt.account, tt.bank)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("VerifyAccountNumber(%s, %s) = %v, want %v", tt.account, tt.bank, err, tt.want)
+			continue
+		}
+		if err == nil && account.Data.Account_Name != tt.wantName {
+			t.Errorf("VerifyAccountNumber(%s, %s) name = %s, want %s", tt.account, tt.bank, account.Data.Account_Name, tt.wantName)
+		}
+	}
+
+	first, err := payments.TransferRecipientCreation(ctx, "Ada Lovelace", "0123456789", "Guaranty Trust Bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := payments.TransferRecipientCreation(ctx, "Ada Lovelace", "0123456789", "Guaranty Trust Bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Data.Active || first.Data.Recipient_Code != second.Data.Recipient_Code {
+		t.Fatalf("recipients = %+v and %+v, want the same active one", first.Data, second.Data)
+	}
+}
+
+func TestInitiateTransfer(t *testing.T) {
+	tests := []struct {
+		name string
+		// fault, when set, applies to the transfer call.
+		fault *paystackfake.Fault
+		want  error
+		// wantSent is whether the fake made the transfer.
+		wantSent bool
+	}{
+		{name: "queued", wantSent: true},
+		{name: "refused", fault: &paystackfake.Fault{Status: http.StatusBadRequest}, want: ErrProviderRejected},
+		{name: "response lost", fault: &paystackfake.Fault{Drop: true, After: true}, want: ErrProviderUnavailable, wantSent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			payments, fake := newFakePaystack(t, paystackfake.Config{Resolve_Any: true})
+			recipient, err := payments.TransferRecipientCreation(ctx, "Ada Lovelace", "0123456789", "Access Bank")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.fault != nil {
+				fake.Inject(http.MethodPost, "/transfer", 1, *tt.fault)
+			}
+			transfer, err := payments.InitiateTransfer(ctx, 250000, recipient.Data.Recipient_Code, "payout-1")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("InitiateTransfer = %v, want %v", err, tt.want)
+			}
+			if err == nil && (transfer.Data.Reference != "payout-1" || transfer.Data.Status != "pending") {
+				t.Fatalf("transfer = %+v", transfer.Data)
+			}
+			sent, ok := fake.Transfer("payout-1")
+			if ok != tt.wantSent {
+				t.Fatalf("fake has the transfer = %v, want %v", ok, tt.wantSent)
+			}
+			if ok && (sent.Amount != 250000 || sent.Recipient_Code != recipient.Data.Recipient_Code) {
+				t.Fatalf("fake transfer = %+v", sent)
+			}
+		})
+	}
+}
+
+func TestPaystackLatency(t *testing.T) {
+	payments, _ := newFakePaystack(t, paystackfake.Config{Latency: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := payments.GetBanks(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetBanks = %v, want the deadline to pass", err)
+	}
+}
+
+func TestPaystackGivingUpDuringBackoff(t *testing.T) {
+	fake := paystackfake.New(paystackfake.Config{Secret_Key: testPaystackKey})
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	fake.Inject(http.MethodGet, "/bank", 100, paystackfake.Fault{Status: http.StatusServiceUnavailable})
+	client := NewPaystackClient(
+		config.PaystackConfig{Secret_Key: testPaystackKey, Base_URL: server.URL},
+		config.ProvidersConfig{
+			Timeout:           time.Second,
+			Max_Retries:       100,
+			Retry_Backoff:     time.Second,
+			Retry_Max_Backoff: time.Second,
+			Breaker_Threshold: 100,
+			Breaker_Cooldown:  time.Second,
+		},
+	)
+	payments := PaymentConstructor(nil, client)
+
+	// The deadline passes while waiting to retry; the caller must see that
+	// rather than the provider's last answer.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := payments.GetBanks(ctx)
+	var providerErr *ProviderError
+	if !errors.Is(err, context.DeadlineExceeded) || errors.As(err, &providerErr) {
+		t.Fatalf("GetBanks = %v, want the deadline to pass", err)
+	}
+}
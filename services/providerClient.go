@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/config"
+	"github.com/JayJosh846/donationPlatform/logging"
+	"github.com/JayJosh846/donationPlatform/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrProviderUnavailable is returned when a provider cannot be reached,
+	// fails on its side, or its circuit breaker is open.
+	ErrProviderUnavailable = errors.New("provider is unavailable, please try again later")
+	ErrProviderRateLimited = errors.New("provider is rate limiting requests")
+	ErrProviderRejected    = errors.New("provider rejected the request")
+	ErrProviderNotFound    = errors.New("provider has no matching record")
+	// ErrProviderAuth means our credentials were refused; it is an
+	// operator problem rather than the caller's.
+	ErrProviderAuth = errors.New("provider refused our credentials")
+)
+
+// maxProviderResponse caps how much of a response body is read.
+const maxProviderResponse = 4 << 20
+
+// ProviderError is a failed provider call. It unwraps to one of the
+// ErrProvider errors so callers can branch on the kind of failure.
+type ProviderError struct {
+	Provider string
+	Status   int
+	// Message is what the provider said went wrong, when it said anything.
+	Message string
+	Kind    error
+}
+
+func (e *ProviderError) Error() string {
+	if e.Message != "" {
+		return e.Provider + ": " + e.Message
+	}
+	return e.Provider + ": " + e.Kind.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Kind
+}
+
+// ProviderCall describes one request to a provider.
+type ProviderCall struct {
+	Method string
+	Path   string
+	// Query is kept apart from Path so it never reaches the logs.
+	Query url.Values
+	Body  interface{}
+	// Idempotent marks a call that is safe to repeat. GET and HEAD always
+	// are; anything that creates a payment or moves money must not be.
+	Idempotent bool
+}
+
+func (call ProviderCall) retryable() bool {
+	return call.Idempotent || call.Method == http.MethodGet || call.Method == http.MethodHead
+}
+
+// ProviderClient is the HTTP client shared by calls to one provider. Every
+// attempt has its own timeout, safe calls are retried with jittered
+// backoff, and a circuit breaker stops calls while the provider is down.
+type ProviderClient struct {
+	Name    string
+	BaseURL string
+	Header  http.Header
+	HTTP    *http.Client
+	Breaker *CircuitBreaker
+
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewProviderClient builds a client for the provider at baseURL. header is
+// sent with every request, usually to authenticate.
+func NewProviderClient(name, baseURL string, header http.Header, cfg config.ProvidersConfig) *ProviderClient {
+	return &ProviderClient{
+		Name:       name,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Header:     header,
+		HTTP:       &http.Client{Timeout: cfg.Timeout, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		Breaker:    NewCircuitBreaker(cfg.Breaker_Threshold, cfg.Breaker_Cooldown),
+		MaxRetries: cfg.Max_Retries,
+		Backoff:    cfg.Retry_Backoff,
+		MaxBackoff: cfg.Retry_Max_Backoff,
+	}
+}
+
+// Do sends call and decodes a successful JSON response into out. Failures
+// are returned as a *ProviderError.
+func (c *ProviderClient) Do(ctx context.Context, call ProviderCall, out interface{}) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, c.Name+" "+call.Method+" "+call.Path,
+		attribute.String("provider", c.Name),
+		attribute.Bool("provider.retryable", call.retryable()),
+	)
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	var body []byte
+	if call.Body != nil {
+		if body, err = json.Marshal(call.Body); err != nil {
+			return err
+		}
+	}
+	attempts := 1
+	if call.retryable() {
+		attempts += c.MaxRetries
+	}
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if waitErr := sleepContext(ctx, c.backoff(attempt)); waitErr != nil {
+				// The caller gave up; the last failure is kept as text only so
+				// it cannot pass for the provider's answer.
+				return fmt.Errorf("%w while waiting to retry: %v", waitErr, err)
+			}
+		}
+		var retry bool
+		retry, err = c.attempt(ctx, call, body, out)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// attempt makes one request and reports whether a failure is worth
+// retrying.
+func (c *ProviderClient) attempt(ctx context.Context, call ProviderCall, body []byte, out interface{}) (bool, error) {
+	if !c.Breaker.Allow() {
+		telemetry.ProviderRequests.WithLabelValues(c.Name, call.Method, call.Path, "circuit_open").Inc()
+		slog.WarnContext(ctx, "provider call skipped, circuit open", "provider", c.Name, "method", call.Method, "path", call.Path)
+		return false, &ProviderError{Provider: c.Name, Kind: ErrProviderUnavailable}
+	}
+
+	target := c.BaseURL + call.Path
+	if len(call.Query) > 0 {
+		target += "?" + call.Query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, call.Method, target, reader)
+	if err != nil {
+		c.Breaker.Release()
+		return false, err
+	}
+	for name, values := range c.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	res, err := c.HTTP.Do(req)
+	telemetry.ProviderDuration.WithLabelValues(c.Name, call.Method, call.Path).Observe(time.Since(start).Seconds())
+	if err != nil {
+		telemetry.ProviderRequests.WithLabelValues(c.Name, call.Method, call.Path, "error").Inc()
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the provider.
+			c.Breaker.Release()
+			return false, ctx.Err()
+		}
+		c.Breaker.Failure()
+		slog.WarnContext(ctx, "provider call failed", "provider", c.Name, "method", call.Method, "path", call.Path,
+			"duration", time.Since(start).Round(time.Millisecond), "err", redactError(err))
+		return true, &ProviderError{Provider: c.Name, Kind: ErrProviderUnavailable}
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxProviderResponse))
+	slog.InfoContext(ctx, "provider call", "provider", c.Name, "method", call.Method, "path", call.Path,
+		"status", res.StatusCode, "duration", time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		telemetry.ProviderRequests.WithLabelValues(c.Name, call.Method, call.Path, "error").Inc()
+		c.Breaker.Failure()
+		return true, &ProviderError{Provider: c.Name, Status: res.StatusCode, Kind: ErrProviderUnavailable}
+	}
+	telemetry.ProviderRequests.WithLabelValues(c.Name, call.Method, call.Path, telemetry.StatusOutcome(res.StatusCode)).Inc()
+	if res.StatusCode >= 500 {
+		c.Breaker.Failure()
+	} else {
+		c.Breaker.Success()
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		if len(data) > 0 {
+			slog.WarnContext(ctx, "provider error response", "provider", c.Name, "method", call.Method, "path", call.Path,
+				"status", res.StatusCode, "body", logging.RedactJSON(data))
+		}
+		providerErr := &ProviderError{
+			Provider: c.Name,
+			Status:   res.StatusCode,
+			Message:  providerMessage(data),
+			Kind:     providerErrorKind(res.StatusCode),
+		}
+		retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+		return retry, providerErr
+	}
+	if out == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, &ProviderError{Provider: c.Name, Status: res.StatusCode, Message: "unexpected response", Kind: ErrProviderUnavailable}
+	}
+	return false, nil
+}
+
+// backoff is a random wait of up to Backoff doubled for every earlier
+// attempt, capped at MaxBackoff ("full jitter"), so clients retrying at
+// the same time spread out.
+func (c *ProviderClient) backoff(attempt int) time.Duration {
+	limit := c.Backoff << (attempt - 1)
+	if limit <= 0 || limit > c.MaxBackoff {
+		limit = c.MaxBackoff
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(limit)))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func providerErrorKind(status int) error {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrProviderAuth
+	case status == http.StatusNotFound:
+		return ErrProviderNotFound
+	case status == http.StatusTooManyRequests:
+		return ErrProviderRateLimited
+	case status >= 500:
+		return ErrProviderUnavailable
+	}
+	return ErrProviderRejected
+}
+
+// providerMessage pulls the human readable message out of an error body.
+// Paystack and CheckID both use a top-level "message".
+func providerMessage(data []byte) string {
+	var body struct {
+		Message string `json:"message"`
+	}
+	json.Unmarshal(data, &body)
+	return body.Message
+}
+
+// redactError drops the URL, and with it any query string, from transport
+// errors before they are logged.
+func redactError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	SecurityEventLoginFailed     = "login_failed"
+	SecurityEventLoginSucceeded  = "login_succeeded"
+	SecurityEventAccountLocked   = "account_locked"
+	SecurityEventIPLocked        = "ip_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+	SecurityEventLoginThrottled  = "login_throttled"
+)
+
+// LoginPolicy controls how failed logins are throttled. Attempts beyond
+// FreeAttempts must wait BaseDelay, doubling per failure up to MaxDelay.
+// Reaching LockThreshold locks the key for LockDuration, doubling with each
+// successive lockout up to MaxLockDuration. Counters reset after Window
+// without failures.
+type LoginPolicy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockThreshold   int
+	LockDuration    time.Duration
+	MaxLockDuration time.Duration
+	Window          time.Duration
+}
+
+// ErrAccountNotLocked is returned when unlocking an account that has no
+// recorded failures.
+var ErrAccountNotLocked = errors.New("account is not locked")
+
+var (
+	AccountLoginPolicy = LoginPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockThreshold:   10,
+		LockDuration:    15 * time.Minute,
+		MaxLockDuration: 24 * time.Hour,
+		Window:          time.Hour,
+	}
+	IPLoginPolicy = LoginPolicy{
+		FreeAttempts:    20,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		LockThreshold:   100,
+		LockDuration:    15 * time.Minute,
+		MaxLockDuration: 6 * time.Hour,
+		Window:          time.Hour,
+	}
+)
+
+type LoginThrottle struct {
+	Locked      bool
+	Retry_After time.Duration
+}
+
+type SecurityService interface {
+	BeginLogin(context.Context, string, string) (LoginThrottle, error)
+	RecordLoginFailure(context.Context, string, string) (LoginThrottle, error)
+	RecordLoginSuccess(context.Context, string, string) error
+	ReleaseLogin(context.Context, string, string) error
+	UnlockAccount(context.Context, string) error
+	LogSecurityEvent(context.Context, *models.SecurityEvent) error
+	GetSecurityEvents(context.Context, string, int64) ([]*models.SecurityEvent, error)
+}
+
+type SecurityServiceImpl struct {
+	attemptRepository repository.LoginAttemptRepository
+	eventRepository   repository.SecurityEventRepository
+}
+
+func SecurityConstructor(attemptRepository repository.LoginAttemptRepository, eventRepository repository.SecurityEventRepository) SecurityService {
+	return &SecurityServiceImpl{
+		attemptRepository: attemptRepository,
+		eventRepository:   eventRepository,
+	}
+}
+
+func accountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// countedAttempt is an attempt counted by BeginLogin for one key.
+type countedAttempt struct {
+	key      string
+	throttle LoginThrottle
+	// claimed is set when the attempt moved the last failure from last to
+	// at, as the one attempt let through after a back-off.
+	claimed  bool
+	last, at time.Time
+}
+
+// BeginLogin counts a login attempt for the account and the ip and reports
+// whether it may go ahead. It must be called before the password hash is
+// compared so throttled requests cost no bcrypt work. The attempt is counted
+// first and the decision made from the count it was added to, so parallel
+// guesses cannot all slip in under the same back-off. An attempt let through
+// must end in RecordLoginFailure, RecordLoginSuccess or ReleaseLogin.
+func (s *SecurityServiceImpl) BeginLogin(ctx context.Context, email, ip string) (LoginThrottle, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	now := time.Now()
+	account, err := s.countAttempt(ctx, accountAttemptKey(email), AccountLoginPolicy, now)
+	if err != nil {
+		return LoginThrottle{}, err
+	}
+	byIP, err := s.countAttempt(ctx, ipAttemptKey(ip), IPLoginPolicy, now)
+	if err != nil {
+		s.takeBack(ctx, account)
+		return LoginThrottle{}, err
+	}
+	throttle := worseThrottle(account.throttle, byIP.throttle)
+	if throttle.Locked || throttle.Retry_After > 0 {
+		// Turned away attempts are not guesses: they do not count and do
+		// not push the back-off further out.
+		if err := s.takeBack(ctx, account); err != nil {
+			return LoginThrottle{}, err
+		}
+		if err := s.takeBack(ctx, byIP); err != nil {
+			return LoginThrottle{}, err
+		}
+	}
+	return throttle, nil
+}
+
+// RecordLoginFailure starts the back-off from now and locks the account or
+// the ip once their counted attempts reach the lock threshold.
+func (s *SecurityServiceImpl) RecordLoginFailure(ctx context.Context, email, ip string) (LoginThrottle, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	now := time.Now()
+	account, err := s.fail(ctx, accountAttemptKey(email), AccountLoginPolicy, now)
+	if err != nil {
+		return LoginThrottle{}, err
+	}
+	byIP, err := s.fail(ctx, ipAttemptKey(ip), IPLoginPolicy, now)
+	if err != nil {
+		return LoginThrottle{}, err
+	}
+	if account.Locked {
+		s.LogSecurityEvent(ctx, &models.SecurityEvent{Type: SecurityEventAccountLocked, Email: email, IP: ip})
+	}
+	if byIP.Locked {
+		s.LogSecurityEvent(ctx, &models.SecurityEvent{Type: SecurityEventIPLocked, Email: email, IP: ip})
+	}
+	return worseThrottle(account, byIP), nil
+}
+
+// RecordLoginSuccess clears the account counter. The ip counter only takes
+// back the attempt and is otherwise left to expire on its own so one valid
+// account cannot be used to reset it.
+func (s *SecurityServiceImpl) RecordLoginSuccess(ctx context.Context, email, ip string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if _, err := s.attemptRepository.Delete(ctx, accountAttemptKey(email)); err != nil {
+		return err
+	}
+	return s.attemptRepository.Uncount(ctx, ipAttemptKey(ip))
+}
+
+// ReleaseLogin takes back an attempt that neither failed nor completed,
+// such as one stopped to ask for the second factor.
+func (s *SecurityServiceImpl) ReleaseLogin(ctx context.Context, email, ip string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return s.uncount(ctx, email, ip)
+}
+
+func (s *SecurityServiceImpl) UnlockAccount(ctx context.Context, email string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	deleted, err := s.attemptRepository.Delete(ctx, accountAttemptKey(email))
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAccountNotLocked
+	}
+	return nil
+}
+
+func (s *SecurityServiceImpl) LogSecurityEvent(ctx context.Context, event *models.SecurityEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	if event.Created_At.IsZero() {
+		event.Created_At = time.Now()
+	}
+	return s.eventRepository.Create(ctx, event)
+}
+
+func (s *SecurityServiceImpl) GetSecurityEvents(ctx context.Context, userID string, limit int64) ([]*models.SecurityEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return s.eventRepository.List(ctx, userID, limit)
+}
+
+func (s *SecurityServiceImpl) countAttempt(ctx context.Context, key string, policy LoginPolicy, now time.Time) (countedAttempt, error) {
+	// Counters that have been quiet for a whole window start again.
+	before, err := s.attemptRepository.CountAttempt(ctx, key, now, now.Add(-policy.Window))
+	if err != nil {
+		return countedAttempt{}, err
+	}
+	counted := countedAttempt{key: key, throttle: policy.evaluate(before, now), last: before.Last_Failure, at: now}
+	if counted.throttle.Locked || counted.throttle.Retry_After > 0 || before.Failures < policy.FreeAttempts {
+		return counted, nil
+	}
+	// An attempt that would start or extend the back-off if it failed
+	// starts it as if it had failed already, so parallel ones still wait.
+	counted.claimed, err = s.attemptRepository.ClaimFailure(ctx, key, before.Last_Failure, now)
+	if err != nil {
+		s.attemptRepository.Uncount(ctx, key)
+		return countedAttempt{}, err
+	}
+	if !counted.claimed {
+		counted.throttle = LoginThrottle{Retry_After: policy.delay(before.Failures + 1)}
+	}
+	return counted, nil
+}
+
+// takeBack uncounts an attempt and gives back the last failure it claimed.
+func (s *SecurityServiceImpl) takeBack(ctx context.Context, counted countedAttempt) error {
+	if counted.claimed {
+		if _, err := s.attemptRepository.ClaimFailure(ctx, counted.key, counted.at, counted.last); err != nil {
+			return err
+		}
+	}
+	return s.attemptRepository.Uncount(ctx, counted.key)
+}
+
+func (s *SecurityServiceImpl) fail(ctx context.Context, key string, policy LoginPolicy, now time.Time) (LoginThrottle, error) {
+	if err := s.attemptRepository.RecordFailure(ctx, key, now); err != nil {
+		return LoginThrottle{}, err
+	}
+	attempt, err := s.attemptRepository.Find(ctx, key)
+	if errors.Is(err, repository.ErrNotFound) {
+		return LoginThrottle{}, nil
+	}
+	if err != nil {
+		return LoginThrottle{}, err
+	}
+	lockFor := policy.LockDuration << attempt.Lockouts
+	if lockFor <= 0 || lockFor > policy.MaxLockDuration {
+		lockFor = policy.MaxLockDuration
+	}
+	locked, err := s.attemptRepository.Lock(ctx, key, policy.LockThreshold, now.Add(lockFor))
+	if err != nil || !locked {
+		return LoginThrottle{}, err
+	}
+	return LoginThrottle{Locked: true, Retry_After: lockFor}, nil
+}
+
+func (s *SecurityServiceImpl) uncount(ctx context.Context, email, ip string) error {
+	if err := s.attemptRepository.Uncount(ctx, accountAttemptKey(email)); err != nil {
+		return err
+	}
+	return s.attemptRepository.Uncount(ctx, ipAttemptKey(ip))
+}
+
+func (p LoginPolicy) evaluate(attempt *models.LoginAttempt, now time.Time) LoginThrottle {
+	if now.Before(attempt.Locked_Until) {
+		return LoginThrottle{Locked: true, Retry_After: attempt.Locked_Until.Sub(now)}
+	}
+	if attempt.Failures <= p.FreeAttempts || now.Sub(attempt.Last_Failure) > p.Window {
+		return LoginThrottle{}
+	}
+	if wait := attempt.Last_Failure.Add(p.delay(attempt.Failures)).Sub(now); wait > 0 {
+		return LoginThrottle{Retry_After: wait}
+	}
+	return LoginThrottle{}
+}
+
+// delay is the back-off after the given number of failures.
+func (p LoginPolicy) delay(failures int) time.Duration {
+	delay := p.BaseDelay << (failures - p.FreeAttempts - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+func worseThrottle(a, b LoginThrottle) LoginThrottle {
+	if a.Locked != b.Locked {
+		if a.Locked {
+			return a
+		}
+		return b
+	}
+	if a.Retry_After >= b.Retry_After {
+		return a
+	}
+	return b
+}
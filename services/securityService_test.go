@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/repository"
+)
+
+func TestParallelLoginGuesses(t *testing.T) {
+	ctx := context.Background()
+	service := SecurityConstructor(repository.NewMemoryLoginAttemptRepository(), repository.NewMemorySecurityEventRepository())
+
+	// Guesses sent at once all see the same counter unless the attempt is
+	// counted before deciding, so only the free attempts and the one that
+	// starts the back-off may get through.
+	const guesses = 50
+	admitted := make(chan bool, guesses)
+	var wg sync.WaitGroup
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			throttle, err := service.BeginLogin(ctx, "ada@example.com", fmt.Sprintf("10.0.0.%d", i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			admitted <- !throttle.Locked && throttle.Retry_After == 0
+		}(i)
+	}
+	wg.Wait()
+	close(admitted)
+
+	var got int
+	for ok := range admitted {
+		if ok {
+			got++
+		}
+	}
+	if want := AccountLoginPolicy.FreeAttempts + 1; got != want {
+		t.Fatalf("%d of %d parallel guesses admitted, want %d", got, guesses, want)
+	}
+}
+
+func TestLoginAttemptCounting(t *testing.T) {
+	const email, ip = "ada@example.com", "10.0.0.1"
+	tests := []struct {
+		name string
+		// finish ends each admitted attempt before the next one.
+		finish func(ctx context.Context, s SecurityService) error
+		// admitted is how many of 10 attempts in a row get through.
+		admitted int
+	}{
+		{
+			name: "failures",
+			finish: func(ctx context.Context, s SecurityService) error {
+				_, err := s.RecordLoginFailure(ctx, email, ip)
+				return err
+			},
+			admitted: AccountLoginPolicy.FreeAttempts + 1,
+		},
+		{
+			name:     "successes",
+			finish:   func(ctx context.Context, s SecurityService) error { return s.RecordLoginSuccess(ctx, email, ip) },
+			admitted: 10,
+		},
+		{
+			name:     "two-factor prompts",
+			finish:   func(ctx context.Context, s SecurityService) error { return s.ReleaseLogin(ctx, email, ip) },
+			admitted: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := SecurityConstructor(repository.NewMemoryLoginAttemptRepository(), repository.NewMemorySecurityEventRepository())
+			var admitted int
+			for i := 0; i < 10; i++ {
+				throttle, err := service.BeginLogin(ctx, email, ip)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if throttle.Locked || throttle.Retry_After > 0 {
+					continue
+				}
+				admitted++
+				if err := tt.finish(ctx, service); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if admitted != tt.admitted {
+				t.Fatalf("%d attempts admitted, want %d", admitted, tt.admitted)
+			}
+		})
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	attempts := repository.NewMemoryLoginAttemptRepository()
+	service := SecurityConstructor(attempts, repository.NewMemorySecurityEventRepository())
+	const email = "ada@example.com"
+
+	// Counted attempts in flight reach the threshold together; of their
+	// failures only one locks the account.
+	for i := 0; i < AccountLoginPolicy.LockThreshold; i++ {
+		attempts.CountAttempt(ctx, accountAttemptKey(email), time.Now(), time.Now().Add(-AccountLoginPolicy.Window))
+	}
+	var locks int
+	for i := 0; i < 3; i++ {
+		throttle, err := service.RecordLoginFailure(ctx, email, fmt.Sprintf("10.0.0.%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if throttle.Locked {
+			locks++
+		}
+	}
+	if locks != 1 {
+		t.Fatalf("%d failures locked the account, want 1", locks)
+	}
+	attempt, err := attempts.Find(ctx, accountAttemptKey(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Lockouts != 1 {
+		t.Fatalf("Lockouts = %d, want 1", attempt.Lockouts)
+	}
+	if throttle, err := service.BeginLogin(ctx, email, "10.0.0.9"); err != nil || !throttle.Locked {
+		t.Fatalf("BeginLogin = %+v, %v; want locked", throttle, err)
+	}
+}
+
+func TestThrottledLoginKeepsBackOff(t *testing.T) {
+	ctx := context.Background()
+	attempts := repository.NewMemoryLoginAttemptRepository()
+	service := SecurityConstructor(attempts, repository.NewMemorySecurityEventRepository())
+	const email, ip = "ada@example.com", "10.0.0.1"
+
+	for i := 0; i <= AccountLoginPolicy.FreeAttempts; i++ {
+		if _, err := service.BeginLogin(ctx, email, ip); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.RecordLoginFailure(ctx, email, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	failed, err := attempts.Find(ctx, accountAttemptKey(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Retries turned away by the back-off neither count nor restart it, so
+	// they cannot keep the account out for good.
+	for i := 0; i < 5; i++ {
+		throttle, err := service.BeginLogin(ctx, email, ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if throttle.Retry_After == 0 {
+			t.Fatalf("retry %d let through during the back-off", i)
+		}
+	}
+	attempt, err := attempts.Find(ctx, accountAttemptKey(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Failures != failed.Failures || !attempt.Last_Failure.Equal(failed.Last_Failure) {
+		t.Fatalf("after throttled retries failures = %d at %v, want %d at %v",
+			attempt.Failures, attempt.Last_Failure, failed.Failures, failed.Last_Failure)
+	}
+}
@@ -0,0 +1,11 @@
+package services
+
+import "time"
+
+// Deadlines for a single database operation and a single call to an
+// external provider. A sooner deadline on the caller's context still wins,
+// so a client that disconnects cancels the work either way.
+var (
+	DatabaseTimeout = 10 * time.Second
+	ProviderTimeout = 30 * time.Second
+)
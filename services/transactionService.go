@@ -5,111 +5,176 @@ import (
 	"errors"
 
 	"github.com/JayJosh846/donationPlatform/models"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/JayJosh846/donationPlatform/repository"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	TransactionPending  = "pending"
+	TransactionComplete = "complete"
+	TransactionFailed   = "failed"
+	TransactionHeld     = "held"
+	TransactionRefunded = "refunded"
+
+	TransactionTypePayout = "payout"
+)
+
+var (
+	ErrNoHeldTransaction = errors.New("no held transaction found with this reference")
+	// ErrTransactionNotPending means the transaction was already settled,
+	// e.g. by an earlier delivery of the same webhook.
+	ErrTransactionNotPending = errors.New("transaction is not pending")
 )
 
 type TransactionService interface {
-	CreateTransaction(*models.Transaction) error
-	GetUserTransactionsByID(string) ([]*models.Transaction, error)
-	GetTransactionByID(primitive.ObjectID) (*models.Transaction, error)
-	GetTransactionCount() (int64, error)
-	GetSuccessfulTransactionCount() (int64, error)
-	GetFailureTransactionCount() (int64, error)
-	GetTransactions() ([]*models.Transaction, error)
-	UpdateTransactionStatus(*string) error
+	CreateTransaction(context.Context, *models.Transaction) error
+	GetUserTransactionsByID(context.Context, string) ([]*models.Transaction, error)
+	GetTransactionByID(context.Context, primitive.ObjectID) (*models.Transaction, error)
+	GetTransactionCount(context.Context) (int64, error)
+	GetSuccessfulTransactionCount(context.Context) (int64, error)
+	GetFailureTransactionCount(context.Context) (int64, error)
+	GetTransactions(context.Context) ([]*models.Transaction, error)
+	CompleteTransaction(context.Context, string) (*models.Transaction, error)
+	FailTransaction(context.Context, string) (*models.Transaction, error)
+	GetTransactionByReference(context.Context, *string) (*models.Transaction, error)
+	HoldTransaction(context.Context, *string, string) error
+	ReleaseHeldTransaction(context.Context, *string) (*models.Transaction, error)
+	RefundHeldTransaction(context.Context, *string) (*models.Transaction, error)
+	RestoreHeldTransaction(context.Context, *string, string) error
 }
 
 type TransactionServiceImpl struct {
-	transactionCollection *mongo.Collection
-	ctx                   context.Context
+	transactionRepository repository.TransactionRepository
 }
 
-func TransactionConstructor(transactionCollection *mongo.Collection, ctx context.Context) TransactionService {
+func TransactionConstructor(transactionRepository repository.TransactionRepository) TransactionService {
 	return &TransactionServiceImpl{
-		transactionCollection: transactionCollection,
-		ctx:                   ctx,
+		transactionRepository: transactionRepository,
 	}
 }
 
-func (u *TransactionServiceImpl) CreateTransaction(transaction *models.Transaction) error {
-	_, err := u.transactionCollection.InsertOne(u.ctx, transaction)
-	return err
+func (u *TransactionServiceImpl) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.transactionRepository.Create(ctx, transaction)
 }
 
-func (u *TransactionServiceImpl) GetTransactionByID(id primitive.ObjectID) (*models.Transaction, error) {
-	var transaction *models.Transaction
-	query := bson.M{"_id": id}
-	err := u.transactionCollection.FindOne(u.ctx, query).Decode(&transaction)
-	return transaction, err
+func (u *TransactionServiceImpl) GetTransactionByID(ctx context.Context, id primitive.ObjectID) (*models.Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.transactionRepository.FindByID(ctx, id)
 }
 
-func (u *TransactionServiceImpl) GetUserTransactionsByID(id string) ([]*models.Transaction, error) {
-	var transactions []*models.Transaction
-	query := bson.M{"user_id": id}
-	cursor, err := u.transactionCollection.Find(u.ctx, query)
-	defer cursor.Close(u.ctx)
+func (u *TransactionServiceImpl) GetUserTransactionsByID(ctx context.Context, id string) ([]*models.Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.transactionRepository.List(ctx, repository.TransactionFilter{User_ID: id})
+}
 
-	for cursor.Next(u.ctx) {
-		var transaction *models.Transaction
-		if err := cursor.Decode(&transaction); err != nil {
-			return nil, err
-		}
-		transactions = append(transactions, transaction)
-	}
-	return transactions, err
+func (u *TransactionServiceImpl) GetTransactions(ctx context.Context) ([]*models.Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.transactionRepository.List(ctx, repository.TransactionFilter{})
 }
 
-func (u *TransactionServiceImpl) GetTransactions() ([]*models.Transaction, error) {
-	var transactions []*models.Transaction
-	query := bson.M{}
-	cursor, err := u.transactionCollection.Find(u.ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(u.ctx)
-
-	for cursor.Next(u.ctx) {
-		var transaction *models.Transaction
-		if err := cursor.Decode(&transaction); err != nil {
-			return nil, err
-		}
-		transactions = append(transactions, transaction)
+func (u *TransactionServiceImpl) GetTransactionCount(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.transactionRepository.Count(ctx, repository.TransactionFilter{})
+}
+
+func (u *TransactionServiceImpl) GetSuccessfulTransactionCount(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.transactionRepository.Count(ctx, repository.TransactionFilter{Status: TransactionComplete})
+}
+
+func (u *TransactionServiceImpl) GetFailureTransactionCount(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.transactionRepository.Count(ctx, repository.TransactionFilter{Status: TransactionFailed})
+}
+
+// CompleteTransaction moves a pending transaction to complete and returns
+// it. Only one caller can settle a given transaction; the others get
+// ErrTransactionNotPending.
+func (u *TransactionServiceImpl) CompleteTransaction(ctx context.Context, reference string) (*models.Transaction, error) {
+	return u.settle(ctx, reference, TransactionComplete)
+}
+
+// FailTransaction moves a pending transaction to failed and returns it.
+func (u *TransactionServiceImpl) FailTransaction(ctx context.Context, reference string) (*models.Transaction, error) {
+	return u.settle(ctx, reference, TransactionFailed)
+}
+
+func (u *TransactionServiceImpl) settle(ctx context.Context, reference, status string) (*models.Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	transaction, err := u.transactionRepository.Transition(ctx, reference, TransactionPending, status, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrTransactionNotPending
 	}
-	return transactions, err
+	return transaction, err
 }
 
-func (u *TransactionServiceImpl) GetTransactionCount() (int64, error) {
-	query := bson.M{}
-	count, err := u.transactionCollection.CountDocuments(u.ctx, query)
-	return count, err
+func (u *TransactionServiceImpl) GetTransactionByReference(ctx context.Context, reference *string) (*models.Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if reference == nil {
+		return nil, repository.ErrNotFound
+	}
+	return u.transactionRepository.FindByReference(ctx, *reference)
 }
 
-func (u *TransactionServiceImpl) GetSuccessfulTransactionCount() (int64, error) {
-	query := bson.M{"status": "complete"}
-	count, err := u.transactionCollection.CountDocuments(u.ctx, query)
-	return count, err
+// HoldTransaction marks a paid, pending transaction whose funds were not
+// credited because they would breach the recipient's limits.
+func (u *TransactionServiceImpl) HoldTransaction(ctx context.Context, reference *string, reason string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	_, err := u.transactionRepository.Transition(ctx, *reference, TransactionPending, TransactionHeld, reason)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrTransactionNotPending
+	}
+	return err
 }
 
-func (u *TransactionServiceImpl) GetFailureTransactionCount() (int64, error) {
-	query := bson.M{"status": "failed"}
-	count, err := u.transactionCollection.CountDocuments(u.ctx, query)
-	return count, err
+// ReleaseHeldTransaction moves a held transaction to complete. Only one
+// caller can release a given transaction.
+func (u *TransactionServiceImpl) ReleaseHeldTransaction(ctx context.Context, reference *string) (*models.Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	transaction, err := u.transactionRepository.Transition(ctx, *reference, TransactionHeld, TransactionComplete, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrNoHeldTransaction
+	}
+	return transaction, err
 }
-func (u *TransactionServiceImpl) UpdateTransactionStatus(transaction *string) error {
-	filter := bson.D{primitive.E{Key: "reference", Value: transaction}}
-	update := bson.D{
-		primitive.E{
-			Key: "$set",
-			Value: bson.D{
-				primitive.E{Key: "status", Value: "complete"},
-			},
-		},
+
+// RefundHeldTransaction moves a held transaction to refunded, keeping the
+// reason it was held, before its charge is returned to the donor. Only one
+// caller can refund a given transaction, and it can no longer be released.
+func (u *TransactionServiceImpl) RefundHeldTransaction(ctx context.Context, reference *string) (*models.Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	held, err := u.transactionRepository.FindByReference(ctx, *reference)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrNoHeldTransaction
+	}
+	if err != nil {
+		return nil, err
 	}
-	result, _ := u.transactionCollection.UpdateOne(u.ctx, filter, update)
-	if result.MatchedCount != 1 {
-		return errors.New("no matched document found for update")
+	transaction, err := u.transactionRepository.Transition(ctx, *reference, TransactionHeld, TransactionRefunded, held.Hold_Reason)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrNoHeldTransaction
 	}
-	return nil
+	return transaction, err
+}
+
+// RestoreHeldTransaction puts a refunded transaction back on hold with
+// reason, for a refund the payment provider did not take.
+func (u *TransactionServiceImpl) RestoreHeldTransaction(ctx context.Context, reference *string, reason string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	_, err := u.transactionRepository.Transition(ctx, *reference, TransactionRefunded, TransactionHeld, reason)
+	return err
 }
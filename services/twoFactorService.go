@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	helper "github.com/JayJosh846/donationPlatform/utils"
+)
+
+const (
+	TwoFactorMethodTOTP  = "totp"
+	TwoFactorMethodEmail = "email"
+
+	TwoFactorPurposeLogin      = "login"
+	TwoFactorPurposePayout     = "payout"
+	TwoFactorPurposeBankChange = "bank_change"
+	TwoFactorPurposeDisable    = "disable_2fa"
+
+	totpIssuer         = "Pocdonation"
+	recoveryCodeCount  = 10
+	twoFactorEmailTTL  = 10 * time.Minute
+	totpEnrollmentSpan = 15 * time.Minute
+
+	// A user gets twoFactorMaxAttempts wrong codes per purpose before that
+	// purpose is locked for twoFactorLockDuration.
+	twoFactorMaxAttempts   = 5
+	twoFactorLockDuration  = 15 * time.Minute
+	twoFactorAttemptWindow = time.Hour
+)
+
+var (
+	ErrTwoFactorRequired    = errors.New("two-factor code required")
+	ErrInvalidTwoFactorCode = errors.New("invalid or expired two-factor code")
+	ErrTwoFactorLocked      = errors.New("too many incorrect two-factor codes, please try again later")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrNoPendingEnrollment  = errors.New("no pending authenticator enrollment")
+	ErrEmailNotVerified     = errors.New("email must be verified before enabling email two-factor authentication")
+	ErrNoEmail              = errors.New("user has no email address")
+)
+
+type TwoFactorService interface {
+	BeginTOTPEnrollment(context.Context, *models.User) (string, string, error)
+	ConfirmTOTPEnrollment(context.Context, *models.User, string) ([]string, error)
+	EnableEmailTwoFactor(context.Context, *models.User) ([]string, error)
+	DisableTwoFactor(context.Context, *models.User, string) error
+	SendEmailCode(context.Context, *models.User, string) error
+	VerifyCode(context.Context, *models.User, string, string) error
+}
+
+type TwoFactorServiceImpl struct {
+	userRepository    repository.UserRepository
+	attemptRepository repository.LoginAttemptRepository
+	otpService        OtpService
+	mailer            Mailer
+}
+
+func TwoFactorConstructor(userRepository repository.UserRepository, attemptRepository repository.LoginAttemptRepository, otpService OtpService, mailer Mailer) TwoFactorService {
+	return &TwoFactorServiceImpl{
+		userRepository:    userRepository,
+		attemptRepository: attemptRepository,
+		otpService:        otpService,
+		mailer:            mailer,
+	}
+}
+
+func twoFactorAttemptKey(userID, purpose string) string {
+	return "two_factor:" + purpose + ":" + userID
+}
+
+// BeginTOTPEnrollment stores a fresh, not yet enabled TOTP secret for the
+// user and returns it with the otpauth:// URI for the QR code.
+func (t *TwoFactorServiceImpl) BeginTOTPEnrollment(ctx context.Context, user *models.User) (string, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if user.Two_Factor.Enabled {
+		return "", "", ErrTwoFactorEnabled
+	}
+	secret, err := helper.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	err = t.userRepository.Update(ctx, user.User_ID, repository.UserUpdate{
+		Two_Factor: &models.TwoFactor{
+			Totp_Secret:   &secret,
+			Method:        TwoFactorMethodTOTP,
+			Pending_Until: time.Now().Add(totpEnrollmentSpan),
+		},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	account := user.User_ID
+	if user.Email != nil {
+		account = *user.Email
+	}
+	return secret, helper.TOTPProvisioningURI(totpIssuer, account, secret), nil
+}
+
+// ConfirmTOTPEnrollment enables TOTP once the user proves their
+// authenticator app produces valid codes, and issues recovery codes.
+func (t *TwoFactorServiceImpl) ConfirmTOTPEnrollment(ctx context.Context, user *models.User, code string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if user.Two_Factor.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.Two_Factor.Totp_Secret == nil || time.Now().After(user.Two_Factor.Pending_Until) {
+		return nil, ErrNoPendingEnrollment
+	}
+	step, ok := helper.ValidateTOTPCode(*user.Two_Factor.Totp_Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	return t.enable(ctx, user, TwoFactorMethodTOTP, step)
+}
+
+func (t *TwoFactorServiceImpl) EnableEmailTwoFactor(ctx context.Context, user *models.User) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if user.Two_Factor.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if !user.Email_Verified {
+		return nil, ErrEmailNotVerified
+	}
+	return t.enable(ctx, user, TwoFactorMethodEmail, 0)
+}
+
+func (t *TwoFactorServiceImpl) enable(ctx context.Context, user *models.User, method string, totpStep int64) ([]string, error) {
+	codes, hashes, err := helper.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	twoFactor := models.TwoFactor{
+		Enabled:        true,
+		Method:         method,
+		Recovery_Codes: hashes,
+		Enabled_At:     time.Now(),
+	}
+	// A TOTP user keeps the secret confirmed during enrollment, and the code
+	// that confirmed it cannot be used again.
+	if method == TwoFactorMethodTOTP {
+		twoFactor.Totp_Secret = user.Two_Factor.Totp_Secret
+		twoFactor.Totp_Last_Step = totpStep
+	}
+	if err := t.userRepository.Update(ctx, user.User_ID, repository.UserUpdate{Two_Factor: &twoFactor}); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (t *TwoFactorServiceImpl) DisableTwoFactor(ctx context.Context, user *models.User, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if !user.Two_Factor.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if err := t.VerifyCode(ctx, user, TwoFactorPurposeDisable, code); err != nil {
+		return err
+	}
+	return t.userRepository.Update(ctx, user.User_ID, repository.UserUpdate{Two_Factor: &models.TwoFactor{}})
+}
+
+// SendEmailCode emails a one-time code for the given purpose. It is the
+// primary factor for email 2FA and the fallback for TOTP users without
+// their device.
+func (t *TwoFactorServiceImpl) SendEmailCode(ctx context.Context, user *models.User, purpose string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if user.Email == nil {
+		return ErrNoEmail
+	}
+	code, err := t.otpService.Issue(ctx, user.User_ID, purpose, twoFactorEmailTTL)
+	if err != nil {
+		return err
+	}
+	name := user.User_ID
+	if user.Username != nil {
+		name = *user.Username
+	}
+	err = sendTwoFactorEmail(t.mailer, name, *user.Email, purpose, code)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// VerifyCode checks a second-factor code for the given purpose. A TOTP code,
+// an emailed code or an unused recovery code are all accepted. Every code
+// counts towards the attempt limit for the purpose, counted before it is
+// checked so parallel guesses cannot exceed it, and the purpose is locked
+// once the limit is reached.
+func (t *TwoFactorServiceImpl) VerifyCode(ctx context.Context, user *models.User, purpose, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if !user.Two_Factor.Enabled {
+		return nil
+	}
+	if code == "" {
+		return ErrTwoFactorRequired
+	}
+	key := twoFactorAttemptKey(user.User_ID, purpose)
+	now := time.Now()
+	before, err := t.attemptRepository.CountAttempt(ctx, key, now, now.Add(-twoFactorAttemptWindow))
+	if err != nil {
+		return err
+	}
+	if now.Before(before.Locked_Until) || before.Failures >= twoFactorMaxAttempts {
+		t.attemptRepository.Uncount(ctx, key)
+		return ErrTwoFactorLocked
+	}
+	if t.consumeTOTPCode(ctx, user, code) || t.consumeEmailCode(ctx, user, purpose, code) || t.consumeRecoveryCode(ctx, user, code) {
+		if _, err := t.attemptRepository.Delete(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "resetting two-factor attempts", "user_id", user.User_ID, "err", err)
+		}
+		return nil
+	}
+	if _, err := t.attemptRepository.Lock(ctx, key, twoFactorMaxAttempts, now.Add(twoFactorLockDuration)); err != nil {
+		return err
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+// consumeTOTPCode accepts a TOTP code once. A code stays valid for about a
+// minute, so one seen by an attacker could otherwise be replayed.
+func (t *TwoFactorServiceImpl) consumeTOTPCode(ctx context.Context, user *models.User, code string) bool {
+	if user.Two_Factor.Method != TwoFactorMethodTOTP || user.Two_Factor.Totp_Secret == nil {
+		return false
+	}
+	step, ok := helper.ValidateTOTPCode(*user.Two_Factor.Totp_Secret, code, time.Now())
+	if !ok {
+		return false
+	}
+	used, err := t.userRepository.UseTOTPStep(ctx, user.User_ID, step)
+	return err == nil && used
+}
+
+func (t *TwoFactorServiceImpl) consumeEmailCode(ctx context.Context, user *models.User, purpose, code string) bool {
+	return t.otpService.Verify(ctx, user.User_ID, purpose, code) == nil
+}
+
+func (t *TwoFactorServiceImpl) consumeRecoveryCode(ctx context.Context, user *models.User, code string) bool {
+	consumed, err := t.userRepository.ConsumeRecoveryCode(ctx, user.User_ID, helper.HashRecoveryCode(code))
+	return err == nil && consumed
+}
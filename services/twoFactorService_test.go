@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	helper "github.com/JayJosh846/donationPlatform/utils"
+)
+
+// enrollTOTP starts authenticator enrollment for a new user and returns the
+// service with functions that load the user and give their code at a time.
+func enrollTOTP(t *testing.T) (TwoFactorService, func() *models.User, func(time.Time) string) {
+	t.Helper()
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	if err := repos.Users.Create(ctx, testUser("u1", "ada@example.com", "+2341", "ada")); err != nil {
+		t.Fatal(err)
+	}
+	twoFactor := TwoFactorConstructor(repos.Users, repos.LoginAttempts, OtpConstructor(repos.Otps), &recordingMailer{})
+	user := func() *models.User {
+		t.Helper()
+		user, err := repos.Users.FindByID(ctx, "u1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+	if _, _, err := twoFactor.BeginTOTPEnrollment(ctx, user()); err != nil {
+		t.Fatal(err)
+	}
+	code := func(at time.Time) string {
+		t.Helper()
+		code, err := helper.GenerateTOTPCode(*user().Two_Factor.Totp_Secret, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	return twoFactor, user, code
+}
+
+func TestTOTPCodeIsNotReplayed(t *testing.T) {
+	ctx := context.Background()
+	twoFactor, user, code := enrollTOTP(t)
+	now := time.Now()
+	enrolled := code(now)
+	if _, err := twoFactor.ConfirmTOTPEnrollment(ctx, user(), enrolled); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		code string
+		want error
+	}{
+		{"code that confirmed enrollment", enrolled, ErrInvalidTwoFactorCode},
+		{"earlier code", code(now.Add(-30 * time.Second)), ErrInvalidTwoFactorCode},
+		{"next code", code(now.Add(30 * time.Second)), nil},
+		{"next code again", code(now.Add(30 * time.Second)), ErrInvalidTwoFactorCode},
+	} {
+		if err := twoFactor.VerifyCode(ctx, user(), TwoFactorPurposeLogin, tt.code); !errors.Is(err, tt.want) {
+			t.Errorf("%s: VerifyCode = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestTwoFactorAttemptLimit(t *testing.T) {
+	ctx := context.Background()
+	twoFactor, user, code := enrollTOTP(t)
+	if _, err := twoFactor.ConfirmTOTPEnrollment(ctx, user(), code(time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	// wrong is none of the codes accepted around now.
+	wrong := "000000"
+	for _, step := range []time.Duration{-30 * time.Second, 0, 30 * time.Second} {
+		if code(time.Now().Add(step)) == wrong {
+			wrong = "111111"
+		}
+	}
+
+	for i := 0; i < twoFactorMaxAttempts; i++ {
+		if err := twoFactor.VerifyCode(ctx, user(), TwoFactorPurposePayout, wrong); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Fatalf("wrong code %d: VerifyCode = %v, want %v", i+1, err, ErrInvalidTwoFactorCode)
+		}
+	}
+	// Once locked even the right code is refused, but only for the purpose
+	// that was guessed at.
+	next := code(time.Now().Add(30 * time.Second))
+	if err := twoFactor.VerifyCode(ctx, user(), TwoFactorPurposePayout, next); !errors.Is(err, ErrTwoFactorLocked) {
+		t.Fatalf("code after %d wrong ones: VerifyCode = %v, want %v", twoFactorMaxAttempts, err, ErrTwoFactorLocked)
+	}
+	if err := twoFactor.VerifyCode(ctx, user(), TwoFactorPurposeBankChange, next); err != nil {
+		t.Fatalf("code for another purpose: VerifyCode = %v, want nil", err)
+	}
+}
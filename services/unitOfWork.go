@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// UnitOfWork runs fn so that the writes the services make with the ctx it
+// is given commit together or not at all. fn may run more than once, so it
+// must not have effects outside the database.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+const (
+	// unitOfWorkAttempts bounds how often a transaction that hit a
+	// transient error, such as a write conflict or an election, is run.
+	unitOfWorkAttempts = 5
+	unitOfWorkBackoff  = 50 * time.Millisecond
+)
+
+// MongoUnitOfWork runs fn in a multi-document transaction, which needs a
+// replica set or a sharded cluster.
+type MongoUnitOfWork struct {
+	client *mongo.Client
+}
+
+// ErrNoTransactions is returned for a standalone MongoDB server, where
+// balance changes could be left half made.
+var ErrNoTransactions = errors.New("MongoDB is a standalone server without transactions; run it as a replica set")
+
+// NewUnitOfWork returns a MongoUnitOfWork when the deployment supports
+// transactions. A standalone server does not, so it is refused unless
+// allowDirect, for local development, where the writes are then made one by
+// one and a warning is logged.
+func NewUnitOfWork(ctx context.Context, client *mongo.Client, allowDirect bool) (UnitOfWork, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return nil, err
+	}
+	if hello.SetName == "" && hello.Msg != "isdbgrid" {
+		if !allowDirect {
+			return nil, ErrNoTransactions
+		}
+		slog.Warn("MongoDB is a standalone server; balance changes will not be transactional. Run it as a replica set.")
+		return DirectUnitOfWork{}, nil
+	}
+	return &MongoUnitOfWork{client: client}, nil
+}
+
+func (u *MongoUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := u.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.WithoutCancel(ctx))
+
+	opts := options.Transaction().
+		SetReadConcern(readconcern.Snapshot()).
+		SetWriteConcern(writeconcern.Majority())
+	for attempt := 1; ; attempt++ {
+		err = mongo.WithSession(ctx, session, func(sc mongo.SessionContext) error {
+			if err := session.StartTransaction(opts); err != nil {
+				return err
+			}
+			if err := fn(sc); err != nil {
+				if abortErr := session.AbortTransaction(context.WithoutCancel(sc)); abortErr != nil {
+					slog.WarnContext(ctx, "aborting transaction", "err", abortErr)
+				}
+				return err
+			}
+			return commit(sc, session)
+		})
+		if err == nil || !hasErrorLabel(err, "TransientTransactionError") || attempt == unitOfWorkAttempts {
+			return err
+		}
+		slog.WarnContext(ctx, "retrying transaction", "attempt", attempt, "err", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * unitOfWorkBackoff):
+		}
+	}
+}
+
+// commit retries a commit whose outcome is unknown, which the server
+// allows; one that fails with a transient error runs the whole
+// transaction again instead.
+func commit(ctx context.Context, session mongo.Session) error {
+	var err error
+	for attempt := 1; attempt <= unitOfWorkAttempts; attempt++ {
+		err = session.CommitTransaction(ctx)
+		if err == nil || !hasErrorLabel(err, "UnknownTransactionCommitResult") {
+			return err
+		}
+	}
+	return err
+}
+
+func hasErrorLabel(err error, label string) bool {
+	var labeled mongo.LabeledError
+	return errors.As(err, &labeled) && labeled.HasErrorLabel(label)
+}
+
+// SerialUnitOfWork runs one fn at a time. It gives in-memory repositories
+// the isolation of a transaction, though not its rollback.
+type SerialUnitOfWork struct {
+	mu sync.Mutex
+}
+
+func (u *SerialUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return fn(ctx)
+}
+
+// DirectUnitOfWork runs fn without a transaction. It is used against a
+// standalone server in development.
+type DirectUnitOfWork struct{}
+
+func (DirectUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// replicaSet connects to the replica set at MONGO_TEST_URI, for example
+// one started with `mongod --replSet rs0` and `rs.initiate()`, and returns
+// a throwaway database. The test is skipped when the variable is unset.
+func replicaSet(t *testing.T) (*mongo.Client, *mongo.Database) {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database("uow_test_" + uuid.NewString()[:8])
+	t.Cleanup(func() {
+		db.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+	// Collections cannot be created inside a transaction on older servers.
+	for _, name := range []string{"Users", "Transactions", "Ledger", "LimitUsage"} {
+		if err := db.CreateCollection(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return client, db
+}
+
+func TestMongoUnitOfWork(t *testing.T) {
+	client, db := replicaSet(t)
+	ctx := context.Background()
+
+	uow, err := NewUnitOfWork(ctx, client, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := uow.(*MongoUnitOfWork); !ok {
+		t.Fatalf("NewUnitOfWork = %T, want *MongoUnitOfWork", uow)
+	}
+
+	repos := repository.NewMongo(db)
+	users := Constructor(repos.Users, repos.Kycs, repos.Socials, repos.Donations, nil, nil, nil)
+	transactions := TransactionConstructor(repos.Transactions)
+	ledger := LedgerConstructor(repos.Ledger)
+	wallet := WalletConstructor(uow, users, transactions, ledger, LimitConstructor(repos.LimitUsage, repos.Kycs, DefaultTierLimits))
+
+	userID := uuid.NewString()
+	if _, err := db.Collection("Users").InsertOne(ctx, bson.M{"user_id": userID, "balance": 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		failed := errors.New("failed")
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			if _, err := users.AdjustBalance(ctx, userID, 500); err != nil {
+				return err
+			}
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Fatalf("Do = %v, want %v", err, failed)
+		}
+		if balance := balanceOf(t, db, userID); balance != 0 {
+			t.Fatalf("balance = %d, want 0", balance)
+		}
+	})
+
+	t.Run("credits a deposit once", func(t *testing.T) {
+		reference := uuid.NewString()
+		if err := transactions.CreateTransaction(ctx, &models.Transaction{
+			ID:        primitive.NewObjectID(),
+			Reference: &reference,
+			User_ID:   userID,
+			Amount:    "1000",
+			Status:    TransactionPending,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, 5)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, _, errs[i] = wallet.CreditDeposit(ctx, reference, 1000)
+			}(i)
+		}
+		wg.Wait()
+
+		credited := 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				credited++
+			case !errors.Is(err, ErrTransactionNotPending):
+				t.Errorf("CreditDeposit = %v", err)
+			}
+		}
+		if credited != 1 {
+			t.Fatalf("credited %d times, want 1", credited)
+		}
+		if balance := balanceOf(t, db, userID); balance != 1000 {
+			t.Fatalf("balance = %d, want 1000", balance)
+		}
+		entries, err := ledger.GetUserLedger(ctx, userID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Balance != 1000 {
+			t.Fatalf("ledger = %+v, want one entry with balance 1000", entries)
+		}
+	})
+
+	t.Run("keeps to the limits", func(t *testing.T) {
+		testWalletLimits(t, uow, repos)
+	})
+}
+
+func balanceOf(t *testing.T, db *mongo.Database, userID string) int {
+	t.Helper()
+	var user models.User
+	if err := db.Collection("Users").FindOne(context.Background(), bson.M{"user_id": userID}).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	return user.Balance
+}
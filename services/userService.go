@@ -6,282 +6,342 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/JayJosh846/donationPlatform/database"
 	"github.com/JayJosh846/donationPlatform/models"
-	helper "github.com/JayJosh846/donationPlatform/utils"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/JayJosh846/donationPlatform/repository"
+)
+
+var (
+	ErrNoPhone             = errors.New("user has no phone number")
+	ErrEmailTaken          = repository.ErrEmailTaken
+	ErrPhoneTaken          = repository.ErrPhoneTaken
+	ErrUsernameTaken       = repository.ErrUsernameTaken
+	ErrInsufficientBalance = repository.ErrInsufficientBalance
 )
 
 type UserService interface {
-	CreateUser(*models.User) error
-	GetUserByID(string) (*models.User, error)
-	GetUser(*string) (*models.User, error)
-	GetUserCount() (int64, error)
-	GetAdmin(*string) (*models.User, error)
-	UpdateUserBalance(*models.User, int, string) error
-	CreateEmailVerification(*models.User, string) (*models.Otp, error)
-	UpdateUserEmailPhone(string, string, string) error
-	UpdateUserEmailStatus(string) error
-	UpdateUserPicture(string, string) error
-	UpdateUserKYCStatus(string, string) error
-	GetUserKycByID(string) (*models.KYC, error)
-	GetUserSocialsByID(string) (*models.Social, error)
-	GetUserDonationsByID(string) ([]*models.Donation, error)
-	CreateSocial(*models.Social) error
-	GetAllUsers() ([]*models.User, error)
-	GetAllKycUsers() ([]*models.User, error)
+	CreateUser(context.Context, *models.User) error
+	GetUserByID(context.Context, string) (*models.User, error)
+	GetUser(context.Context, *string) (*models.User, error)
+	GetUserCount(context.Context) (int64, error)
+	EmailExists(context.Context, string) (bool, error)
+	PhoneExists(context.Context, string) (bool, error)
+	AvailableUsername(context.Context, string, string) (string, error)
+	UpdateTokens(context.Context, string, string, string) error
+	GetAdmin(context.Context, *string) (*models.User, error)
+	AdjustBalance(context.Context, string, int) (int, error)
+	CreateEmailVerification(context.Context, *models.User, string) error
+	VerifyEmailOtp(context.Context, string, string) error
+	CreatePhoneVerification(context.Context, *models.User) error
+	VerifyPhone(context.Context, *models.User, string) error
+	UpdateUserKycTier(context.Context, string, int) error
+	UpdateUserEmailPhone(context.Context, string, string, string) error
+	UpdateUserEmailStatus(context.Context, string) error
+	UpdateUserPicture(context.Context, string, string, string) error
+	UpdateUserKYCStatus(context.Context, string, string) error
+	SetSelfieUploaded(context.Context, string) error
+	SetBVNVerified(context.Context, string) error
+	GetUserKycByID(context.Context, string) (*models.KYC, error)
+	GetUserSocialsByID(context.Context, string) (*models.Social, error)
+	HasSocials(context.Context, string) (bool, error)
+	GetUserDonationsByID(context.Context, string) ([]*models.Donation, error)
+	CreateSocial(context.Context, *models.Social) error
+	GetAllUsers(context.Context) ([]*models.User, error)
+	GetAllKycUsers(context.Context) ([]*models.User, error)
 }
 
 type UserServiceImpl struct {
-	userCollection *mongo.Collection
-	ctx            context.Context
+	userRepository     repository.UserRepository
+	kycRepository      repository.KycRepository
+	socialRepository   repository.SocialRepository
+	donationRepository repository.DonationRepository
+	otpService         OtpService
+	smsSender          SMSSender
+	mailer             Mailer
 }
 
-func Constructor(userCollection *mongo.Collection, ctx context.Context) UserService {
+const (
+	emailVerificationTTL = 30 * time.Minute
+	phoneVerificationTTL = 10 * time.Minute
+
+	OtpPurposePhoneVerification = "phone_verification"
+)
+
+func Constructor(userRepository repository.UserRepository, kycRepository repository.KycRepository, socialRepository repository.SocialRepository, donationRepository repository.DonationRepository, otpService OtpService, smsSender SMSSender, mailer Mailer) UserService {
 	return &UserServiceImpl{
-		userCollection: userCollection,
-		ctx:            ctx,
+		userRepository:     userRepository,
+		kycRepository:      kycRepository,
+		socialRepository:   socialRepository,
+		donationRepository: donationRepository,
+		otpService:         otpService,
+		smsSender:          smsSender,
+		mailer:             mailer,
 	}
 }
 
-var OTPCollection *mongo.Collection = database.GetUserCollection(database.Client, "Otps")
-var KycCollection *mongo.Collection = database.GetUserCollection(database.Client, "Kycs")
-var SocialCollection *mongo.Collection = database.GetUserCollection(database.Client, "Socials")
-var DonationCollection *mongo.Collection = database.GetUserCollection(database.Client, "Donations")
-
-func (u *UserServiceImpl) CreateUser(user *models.User) error {
-	_, err := u.userCollection.InsertOne(u.ctx, user)
-	return err
-}
-
-func (u *UserServiceImpl) GetUser(email *string) (*models.User, error) {
-	var user *models.User
-	query := bson.M{"email": email}
-	err := u.userCollection.FindOne(u.ctx, query).Decode(&user)
-	return user, err
-}
-
-func (u *UserServiceImpl) GetUserByID(id string) (*models.User, error) {
-	var user *models.User
-	query := bson.M{"user_id": id}
-	err := u.userCollection.FindOne(u.ctx, query).Decode(&user)
-	return user, err
-}
-
-func (u *UserServiceImpl) GetUserCount() (int64, error) {
-	query := bson.M{}
-	count, err := u.userCollection.CountDocuments(u.ctx, query)
-	return count, err
-}
-
-func (u *UserServiceImpl) GetAdmin(email *string) (*models.User, error) {
-	var user *models.User
-	query := bson.M{"email": email, "role": "admin"}
-	err := u.userCollection.FindOne(u.ctx, query).Decode(&user)
-	return user, err
-}
-
-func (u *UserServiceImpl) UpdateUserBalance(user *models.User, amount int, updateType string) error {
-	switch updateType {
-	case "add":
-		user.Balance = user.Balance + amount
-		filter := bson.D{primitive.E{Key: "email", Value: user.Email}}
-		update := bson.D{
-			primitive.E{
-				Key: "$set",
-				Value: bson.D{
-					primitive.E{Key: "balance", Value: user.Balance},
-				},
-			},
-		}
-		result, _ := u.userCollection.UpdateOne(u.ctx, filter, update)
-		if result.MatchedCount != 1 {
-			return errors.New("no matched document found for update")
-		}
-		return nil
-
-	case "subtract":
-		user.Balance = user.Balance - amount
-		filter := bson.D{primitive.E{Key: "email", Value: user.Email}}
-		update := bson.D{
-			primitive.E{
-				Key: "$set",
-				Value: bson.D{
-					primitive.E{Key: "balance", Value: user.Balance},
-				},
-			},
-		}
-		result, _ := u.userCollection.UpdateOne(u.ctx, filter, update)
-		if result.MatchedCount != 1 {
-			return errors.New("no matched document found for update")
-		}
-		return nil
-	default:
-		return errors.New("unsupported update type")
+func (u *UserServiceImpl) CreateUser(ctx context.Context, user *models.User) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.userRepository.Create(ctx, user)
+}
+
+func (u *UserServiceImpl) EmailExists(ctx context.Context, email string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	count, err := u.userRepository.Count(ctx, repository.UserFilter{Email: email})
+	return count > 0, err
+}
+
+func (u *UserServiceImpl) PhoneExists(ctx context.Context, phone string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	count, err := u.userRepository.Count(ctx, repository.UserFilter{Phone: phone})
+	return count > 0, err
+}
+
+// AvailableUsername returns base, or when another account has it, base
+// with the end of userID appended, as the username migration does.
+func (u *UserServiceImpl) AvailableUsername(ctx context.Context, base, userID string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	count, err := u.userRepository.Count(ctx, repository.UserFilter{Username: base})
+	if err != nil || count == 0 {
+		return base, err
+	}
+	if len(userID) > 6 {
+		userID = userID[len(userID)-6:]
 	}
+	return base + "-" + userID, nil
 }
 
-func (u *UserServiceImpl) CreateEmailVerification(user *models.User, email string) (*models.Otp, error) {
-	var otp models.Otp
-	verificationCode := helper.GenerateVerificationCode()
-	expirationTime := time.Now().Add(30 * time.Minute)
+func (u *UserServiceImpl) UpdateTokens(ctx context.Context, token, refreshToken, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.userRepository.Update(ctx, id, repository.UserUpdate{
+		Token:         &token,
+		Refresh_Token: &refreshToken,
+	})
+}
 
-	otp.User_ID = user.User_ID
-	otp.Token = verificationCode
-	otp.Expires_At = expirationTime
-	OTPCollection.InsertOne(u.ctx, otp)
+func (u *UserServiceImpl) GetUser(ctx context.Context, email *string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if email == nil {
+		return nil, repository.ErrNotFound
+	}
+	return u.userRepository.FindByEmail(ctx, *email)
+}
 
-	err := sendVerificationEmail(*user.Username, email, verificationCode)
+func (u *UserServiceImpl) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.userRepository.FindByID(ctx, id)
+}
+
+func (u *UserServiceImpl) GetUserCount(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.userRepository.Count(ctx, repository.UserFilter{})
+}
+
+func (u *UserServiceImpl) GetAdmin(ctx context.Context, email *string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	user, err := u.GetUser(ctx, email)
 	if err != nil {
-		fmt.Println("Error sending verification email:", err)
 		return nil, err
 	}
-
-	return &otp, nil
+	if user.Role != "admin" {
+		return nil, repository.ErrNotFound
+	}
+	return user, nil
 }
 
-func (u *UserServiceImpl) UpdateUserEmailPhone(id, email, phone string) error {
-	filter := bson.M{"user_id": id}
+// AdjustBalance adds amount, which is negative for a debit, to the user's
+// balance and returns the new balance. A debit larger than the balance
+// fails with ErrInsufficientBalance and changes nothing.
+func (u *UserServiceImpl) AdjustBalance(ctx context.Context, id string, amount int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.userRepository.AdjustBalance(ctx, id, amount)
+}
 
-	update := bson.M{
-		"$set": bson.M{
-			// "email_verified": true,
-			"email": email,
-			"phone": phone,
-		},
+func (u *UserServiceImpl) CreateEmailVerification(ctx context.Context, user *models.User, email string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	verificationCode, err := u.otpService.Issue(ctx, user.User_ID, OtpPurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
 	}
-	result, _ := u.userCollection.UpdateOne(u.ctx, filter, update)
-	if result.MatchedCount != 1 {
-		return errors.New("no matched document found for update")
+
+	err = sendVerificationEmail(u.mailer, *user.Username, email, verificationCode)
+	if err != nil {
+		return err
 	}
+
 	return nil
 }
 
-func (u *UserServiceImpl) UpdateUserEmailStatus(id string) error {
-	filter := bson.D{primitive.E{Key: "user_id", Value: id}}
-	update := bson.D{
-		primitive.E{
-			Key: "$set",
-			Value: bson.D{
-				primitive.E{Key: "email_verified", Value: true},
-			},
-		},
+func (u *UserServiceImpl) VerifyEmailOtp(ctx context.Context, id, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.otpService.Verify(ctx, id, OtpPurposeEmailVerification, code)
+}
+
+func (u *UserServiceImpl) CreatePhoneVerification(ctx context.Context, user *models.User) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if user.Phone == nil || *user.Phone == "" {
+		return ErrNoPhone
 	}
-	result, _ := u.userCollection.UpdateOne(u.ctx, filter, update)
-	if result.MatchedCount != 1 {
-		return errors.New("no matched document found for update")
+	code, err := u.otpService.Issue(ctx, user.User_ID, OtpPurposePhoneVerification, phoneVerificationTTL)
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("Your Pocdonation verification code is %s. It expires in %d minutes.", code, int(phoneVerificationTTL.Minutes()))
+	if err := u.smsSender.Send(ctx, *user.Phone, message); err != nil {
+		return err
 	}
 	return nil
 }
 
-func (u *UserServiceImpl) UpdateUserPicture(id, picture string) error {
-	filter := bson.D{primitive.E{Key: "user_id", Value: id}}
-	update := bson.D{
-		primitive.E{
-			Key: "$set",
-			Value: bson.D{
-				primitive.E{Key: "profile_picture", Value: picture},
-			},
-		},
+// VerifyPhone marks the phone verified and grants KYC tier 1, which a
+// verified phone number is the requirement for.
+func (u *UserServiceImpl) VerifyPhone(ctx context.Context, user *models.User, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	if user.Phone == nil || *user.Phone == "" {
+		return ErrNoPhone
 	}
-	result, _ := u.userCollection.UpdateOne(u.ctx, filter, update)
-	if result.MatchedCount != 1 {
-		return errors.New("no matched document found for update")
+	if err := u.otpService.Verify(ctx, user.User_ID, OtpPurposePhoneVerification, code); err != nil {
+		return err
 	}
-	return nil
+	// Matching on the phone as well means a number changed after the code
+	// was sent is not marked verified.
+	if err := u.userRepository.VerifyPhone(ctx, user.User_ID, *user.Phone); err != nil {
+		return err
+	}
+	tier, err := u.earnedTier(ctx, user)
+	if err != nil {
+		return err
+	}
+	return u.UpdateUserKycTier(ctx, user.User_ID, tier)
 }
 
-func (u *UserServiceImpl) UpdateUserKYCStatus(id, document string) error {
-	filter := bson.M{"user_id": id}
-
-	update := bson.M{
-		"$set": bson.M{
-			"identification": document,
-			"kyc_status":     true,
-			"id_upload":      true,
-		},
+// earnedTier is the tier the user's checks are worth with a verified phone.
+// A changed phone drops the account to tier 0; once the new number is
+// verified the BVN and document checks already passed count again.
+func (u *UserServiceImpl) earnedTier(ctx context.Context, user *models.User) (int, error) {
+	kyc, err := u.kycRepository.FindByUserID(ctx, user.User_ID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+	case err != nil:
+		return 0, err
+	case kyc.Status == KycStatusApproved:
+		return KycDocumentTier, nil
 	}
-	result, _ := u.userCollection.UpdateOne(u.ctx, filter, update)
-	if result.MatchedCount != 1 {
-		return errors.New("no matched document found for update")
+	if user.Bvn_Verified {
+		return 2, nil
 	}
-	return nil
+	return 1, nil
 }
 
-func (u *UserServiceImpl) GetUserKycByID(id string) (*models.KYC, error) {
-	var kyc *models.KYC
-	query := bson.M{"user_id": id}
-	err := KycCollection.FindOne(u.ctx, query).Decode(&kyc)
-	return kyc, err
+// UpdateUserKycTier raises the user's KYC tier. It never lowers it.
+func (u *UserServiceImpl) UpdateUserKycTier(ctx context.Context, id string, tier int) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.kycRepository.RaiseTier(ctx, id, tier)
 }
 
-func (u *UserServiceImpl) GetUserSocialsByID(id string) (*models.Social, error) {
-	var social *models.Social
-	query := bson.M{"user_id": id}
-	err := SocialCollection.FindOne(u.ctx, query).Decode(&social)
-	return social, err
+// UpdateUserEmailPhone changes the user's contact details. A new phone
+// number has to be verified again, and until it is the account is tier 0.
+func (u *UserServiceImpl) UpdateUserEmailPhone(ctx context.Context, id, email, phone string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	phoneChanged, err := u.userRepository.SetContact(ctx, id, email, phone)
+	if err != nil || !phoneChanged {
+		return err
+	}
+	return u.kycRepository.LowerTier(ctx, id, 0)
 }
 
-func (u *UserServiceImpl) GetUserDonationsByID(id string) ([]*models.Donation, error) {
-	var donations []*models.Donation
-	query := bson.M{"user_id": id}
-	cursor, err := DonationCollection.Find(u.ctx, query)
-	defer cursor.Close(u.ctx)
+func (u *UserServiceImpl) UpdateUserEmailStatus(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	verified := true
+	return u.userRepository.Update(ctx, id, repository.UserUpdate{Email_Verified: &verified})
+}
 
-	for cursor.Next(u.ctx) {
-		var donation *models.Donation
-		if err := cursor.Decode(&donation); err != nil {
-			return nil, err
-		}
-		donations = append(donations, donation)
-	}
-	return donations, err
+func (u *UserServiceImpl) UpdateUserPicture(ctx context.Context, id, picture, thumbnail string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.userRepository.Update(ctx, id, repository.UserUpdate{
+		Profile_Picture:   &picture,
+		Profile_Thumbnail: &thumbnail,
+	})
 }
 
-func (u *UserServiceImpl) CreateSocial(social *models.Social) error {
-	_, err := SocialCollection.InsertOne(u.ctx, social)
-	return err
+func (u *UserServiceImpl) UpdateUserKYCStatus(ctx context.Context, id, document string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	uploaded := true
+	return u.userRepository.Update(ctx, id, repository.UserUpdate{
+		Identification: &document,
+		ID_Upload:      &uploaded,
+	})
 }
 
-func (u *UserServiceImpl) GetAllUsers() ([]*models.User, error) {
-	var users []*models.User
-	query := bson.M{"role": "user"}
-	cursor, err := u.userCollection.Find(u.ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(u.ctx)
-
-	for cursor.Next(u.ctx) {
-		var user *models.User
-		if err := cursor.Decode(&user); err != nil {
-			return nil, err
-		}
-		users = append(users, user)
-	}
-	return users, err
+func (u *UserServiceImpl) SetSelfieUploaded(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	uploaded := true
+	return u.userRepository.Update(ctx, id, repository.UserUpdate{Selfie_Upload: &uploaded})
 }
 
-func (u *UserServiceImpl) GetAllKycUsers() ([]*models.User, error) {
-	var users []*models.User
-	query := bson.M{
-		"role":       "user",
-		"kyc_status": true,
-	}
-	cursor, err := u.userCollection.Find(u.ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(u.ctx)
-
-	for cursor.Next(u.ctx) {
-		var user *models.User
-		if err := cursor.Decode(&user); err != nil {
-			return nil, err
-		}
-		users = append(users, user)
-	}
-	return users, err
+func (u *UserServiceImpl) SetBVNVerified(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	verified := true
+	return u.userRepository.Update(ctx, id, repository.UserUpdate{Bvn_Verified: &verified})
+}
+
+func (u *UserServiceImpl) GetUserKycByID(ctx context.Context, id string) (*models.KYC, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.kycRepository.FindByUserID(ctx, id)
+}
+
+func (u *UserServiceImpl) GetUserSocialsByID(ctx context.Context, id string) (*models.Social, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.socialRepository.FindByUserID(ctx, id)
+}
+
+func (u *UserServiceImpl) HasSocials(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.socialRepository.Exists(ctx, id)
+}
+
+func (u *UserServiceImpl) GetUserDonationsByID(ctx context.Context, id string) ([]*models.Donation, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.donationRepository.ListByUserID(ctx, id)
+}
+
+func (u *UserServiceImpl) CreateSocial(ctx context.Context, social *models.Social) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.socialRepository.Create(ctx, social)
+}
+
+func (u *UserServiceImpl) GetAllUsers(ctx context.Context) ([]*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.userRepository.List(ctx, repository.UserFilter{Role: "user"})
+}
+
+func (u *UserServiceImpl) GetAllKycUsers(ctx context.Context) ([]*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseTimeout)
+	defer cancel()
+	return u.userRepository.List(ctx, repository.UserFilter{Role: "user", Kyc_Verified: true})
 }
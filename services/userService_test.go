@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newUserService(t *testing.T, users ...*models.User) (UserService, *repository.Repositories, *recordingSMS) {
+	t.Helper()
+	repos := repository.NewMemory()
+	for _, user := range users {
+		if err := repos.Users.Create(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sms := &recordingSMS{}
+	otps := OtpConstructor(repos.Otps)
+	return Constructor(repos.Users, repos.Kycs, repos.Socials, repos.Donations, otps, sms, &recordingMailer{}), repos, sms
+}
+
+func testUser(userID, email, phone, username string) *models.User {
+	return &models.User{
+		ID:       primitive.NewObjectID(),
+		User_ID:  userID,
+		Email:    &email,
+		Phone:    &phone,
+		Username: &username,
+		Role:     "user",
+	}
+}
+
+func TestAvailableUsername(t *testing.T) {
+	users, _, _ := newUserService(t, testUser("u1", "ada@example.com", "+2341", "ada"))
+	tests := []struct {
+		base, userID string
+		want         string
+	}{
+		{"grace", "u2", "grace"},
+		{"ada", "0d6f4e2a-7c1b-4f7e-9a51-3b2c8e9f1a2b", "ada-9f1a2b"},
+		{"ada", "u2", "ada-u2"},
+	}
+	for _, tt := range tests {
+		got, err := users.AvailableUsername(context.Background(), tt.base, tt.userID)
+		if err != nil || got != tt.want {
+			t.Errorf("AvailableUsername(%s, %s) = %s, %v; want %s", tt.base, tt.userID, got, err, tt.want)
+		}
+	}
+}
+
+func TestVerifyPhone(t *testing.T) {
+	tests := []struct {
+		name string
+		// newPhone replaces the stored number after the code was sent,
+		// while the caller still holds the user it loaded before.
+		newPhone string
+		want     error
+		wantTier int
+	}{
+		{name: "same number", want: nil, wantTier: 1},
+		{name: "changed number", newPhone: "+2349", want: repository.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			users, repos, sms := newUserService(t, testUser("u1", "ada@example.com", "+2341", "ada"))
+			user, err := users.GetUserByID(ctx, "u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := users.CreatePhoneVerification(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+			code := lastCode(sms.sent)
+			if tt.newPhone != "" {
+				if err := users.UpdateUserEmailPhone(ctx, "u1", "ada@example.com", tt.newPhone); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := users.VerifyPhone(ctx, user, code); !errors.Is(err, tt.want) {
+				t.Fatalf("VerifyPhone = %v, want %v", err, tt.want)
+			}
+			stored, err := users.GetUserByID(ctx, "u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Phone_Verified != (tt.want == nil) {
+				t.Errorf("Phone_Verified = %v", stored.Phone_Verified)
+			}
+			kyc, err := repos.Kycs.FindByUserID(ctx, "u1")
+			if tt.wantTier == 0 {
+				if err != repository.ErrNotFound {
+					t.Errorf("KYC record = %+v, %v; want none", kyc, err)
+				}
+				return
+			}
+			if err != nil || kyc.Tier != tt.wantTier {
+				t.Errorf("KYC record = %+v, %v; want tier %d", kyc, err, tt.wantTier)
+			}
+		})
+	}
+}
+
+func TestPhoneChangeDropsTier(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup passes the checks behind the tiers above 1.
+		setup    func(ctx context.Context, users UserService, repos *repository.Repositories) error
+		phone    string
+		wantTier int
+	}{
+		{name: "same phone", phone: "+2341", wantTier: 1},
+		{name: "verified phone", phone: "+2349", wantTier: 1},
+		{
+			name:  "verified BVN",
+			phone: "+2349",
+			setup: func(ctx context.Context, users UserService, repos *repository.Repositories) error {
+				if err := users.SetBVNVerified(ctx, "u1"); err != nil {
+					return err
+				}
+				return users.UpdateUserKycTier(ctx, "u1", 2)
+			},
+			wantTier: 2,
+		},
+		{
+			name:  "approved document",
+			phone: "+2349",
+			setup: func(ctx context.Context, users UserService, repos *repository.Repositories) error {
+				tier := KycDocumentTier
+				_, err := repos.Kycs.Transition(ctx, "u1", []string{KycStatusOngoing}, repository.KycChange{Status: KycStatusApproved, Tier: &tier})
+				return err
+			},
+			wantTier: KycDocumentTier,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			users, repos, sms := newUserService(t, testUser("u1", "ada@example.com", "+2341", "ada"))
+			verify := func() {
+				t.Helper()
+				user, err := users.GetUserByID(ctx, "u1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := users.CreatePhoneVerification(ctx, user); err != nil {
+					t.Fatal(err)
+				}
+				if err := users.VerifyPhone(ctx, user, lastCode(sms.sent)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tier := func() int {
+				t.Helper()
+				kyc, err := repos.Kycs.FindByUserID(ctx, "u1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return kyc.Tier
+			}
+
+			verify()
+			if tt.setup != nil {
+				if err := tt.setup(ctx, users, repos); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := tier()
+			if err := users.UpdateUserEmailPhone(ctx, "u1", "ada@example.com", tt.phone); err != nil {
+				t.Fatal(err)
+			}
+			if tt.phone == "+2341" {
+				if got := tier(); got != before {
+					t.Fatalf("tier after keeping the phone = %d, want %d", got, before)
+				}
+				return
+			}
+			if got := tier(); got != 0 {
+				t.Fatalf("tier after changing the phone = %d, want 0", got)
+			}
+			verify()
+			if got := tier(); got != tt.wantTier {
+				t.Fatalf("tier after verifying the new phone = %d, want %d", got, tt.wantTier)
+			}
+		})
+	}
+}
+
+func TestGetAdmin(t *testing.T) {
+	admin := testUser("a1", "admin@example.com", "+2340", "admin")
+	admin.Role = "admin"
+	users, _, _ := newUserService(t, admin, testUser("u1", "ada@example.com", "+2341", "ada"))
+	tests := []struct {
+		email string
+		want  error
+	}{
+		{"admin@example.com", nil},
+		{"ada@example.com", repository.ErrNotFound},
+		{"nobody@example.com", repository.ErrNotFound},
+	}
+	for _, tt := range tests {
+		email := tt.email
+		if _, err := users.GetAdmin(context.Background(), &email); !errors.Is(err, tt.want) {
+			t.Errorf("GetAdmin(%s) = %v, want %v", tt.email, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrNotPayout     = errors.New("transaction is not a payout")
+	ErrInvalidAmount = errors.New("amount must be positive")
+)
+
+// WalletService moves money in and out of users' balances. Each operation
+// checks the limits, changes the balance, settles the transaction and
+// writes the ledger entry and limit usage in one unit of work, so they all
+// happen or none do.
+type WalletService interface {
+	CreditDeposit(context.Context, string, int) (*models.Transaction, int, error)
+	ReleaseHeld(context.Context, string) (*models.Transaction, int, error)
+	ReservePayout(context.Context, *models.User, int, string) (*models.Transaction, error)
+	CompletePayout(context.Context, string) (*models.Transaction, error)
+	RefundPayout(context.Context, string) (*models.Transaction, error)
+}
+
+type WalletServiceImpl struct {
+	unitOfWork         UnitOfWork
+	userService        UserService
+	transactionService TransactionService
+	ledgerService      LedgerService
+	limitService       LimitService
+}
+
+func WalletConstructor(unitOfWork UnitOfWork, userService UserService, transactionService TransactionService, ledgerService LedgerService, limitService LimitService) WalletService {
+	return &WalletServiceImpl{
+		unitOfWork:         unitOfWork,
+		userService:        userService,
+		transactionService: transactionService,
+		ledgerService:      ledgerService,
+		limitService:       limitService,
+	}
+}
+
+// CreditDeposit completes the pending donation with reference and credits
+// amount to its recipient, returning the new balance. A donation that would
+// breach the recipient's limits is held instead and its *LimitError
+// returned. A donation that was already settled fails with
+// ErrTransactionNotPending, so a repeated webhook credits nothing.
+func (w *WalletServiceImpl) CreditDeposit(ctx context.Context, reference string, amount int) (transaction *models.Transaction, balance int, err error) {
+	if amount <= 0 {
+		return nil, 0, ErrInvalidAmount
+	}
+	var breach *LimitError
+	err = w.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		breach = nil
+		transaction, err = w.transactionService.GetTransactionByReference(ctx, &reference)
+		if err != nil {
+			return err
+		}
+		if transaction.Type != "" {
+			return fmt.Errorf("crediting %s transaction %s as a deposit", transaction.Type, reference)
+		}
+		// The limits are checked against the balance and usage this unit of
+		// work sees. Every credit and payout also writes the balance, so two
+		// of them at once for the same user conflict and one is run again
+		// on top of the other.
+		user, err := w.userService.GetUserByID(ctx, transaction.User_ID)
+		if err != nil {
+			return err
+		}
+		if err := w.limitService.CheckInflow(ctx, user, amount); errors.As(err, &breach) {
+			return w.transactionService.HoldTransaction(ctx, &reference, breach.Error())
+		} else if err != nil {
+			return err
+		}
+		transaction, err = w.transactionService.CompleteTransaction(ctx, reference)
+		if err != nil {
+			return err
+		}
+		balance, err = w.credit(ctx, transaction.User_ID, LedgerDeposit, reference, amount)
+		return err
+	})
+	if err == nil && breach != nil {
+		return transaction, 0, breach
+	}
+	return transaction, balance, err
+}
+
+// ReleaseHeld completes a donation that was held for breaching the
+// recipient's limits and credits it.
+func (w *WalletServiceImpl) ReleaseHeld(ctx context.Context, reference string) (transaction *models.Transaction, balance int, err error) {
+	err = w.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		transaction, err = w.transactionService.ReleaseHeldTransaction(ctx, &reference)
+		if err != nil {
+			return err
+		}
+		amount, err := strconv.Atoi(transaction.Amount)
+		if err != nil {
+			return fmt.Errorf("held transaction %s amount: %w", reference, err)
+		}
+		balance, err = w.credit(ctx, transaction.User_ID, LedgerRelease, reference, amount)
+		return err
+	})
+	return transaction, balance, err
+}
+
+func (w *WalletServiceImpl) credit(ctx context.Context, userID, kind, reference string, amount int) (int, error) {
+	balance, err := w.userService.AdjustBalance(ctx, userID, amount)
+	if err != nil {
+		return 0, err
+	}
+	err = w.ledgerService.Record(ctx, &models.LedgerEntry{
+		User_ID:   userID,
+		Kind:      kind,
+		Reference: reference,
+		Amount:    amount,
+		Balance:   balance,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return balance, w.limitService.RecordInflow(ctx, userID, amount, reference)
+}
+
+// ReservePayout debits amount from the user's balance and records a
+// pending payout under reference before any money is sent, so two payouts
+// at once cannot both spend the same balance or withdrawal limit. It fails
+// with ErrInsufficientBalance when the balance is too low and a
+// *LimitError when the payout would breach the limits.
+func (w *WalletServiceImpl) ReservePayout(ctx context.Context, user *models.User, amount int, reference string) (transaction *models.Transaction, err error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	err = w.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		current, err := w.userService.GetUserByID(ctx, user.User_ID)
+		if err != nil {
+			return err
+		}
+		if err := w.limitService.CheckPayout(ctx, current, amount); err != nil {
+			return err
+		}
+		balance, err := w.userService.AdjustBalance(ctx, user.User_ID, -amount)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		transaction = &models.Transaction{
+			ID:             primitive.NewObjectID(),
+			Reference:      &reference,
+			User_ID:        user.User_ID,
+			User_Full_name: user.Fullname,
+			Amount:         strconv.Itoa(amount),
+			Status:         TransactionPending,
+			Type:           TransactionTypePayout,
+			Created_At:     now,
+			Updated_At:     now,
+		}
+		if err := w.transactionService.CreateTransaction(ctx, transaction); err != nil {
+			return err
+		}
+		err = w.ledgerService.Record(ctx, &models.LedgerEntry{
+			User_ID:   user.User_ID,
+			Kind:      LedgerPayout,
+			Reference: reference,
+			Amount:    -amount,
+			Balance:   balance,
+		})
+		if err != nil {
+			return err
+		}
+		return w.limitService.RecordPayout(ctx, user.User_ID, amount, reference)
+	})
+	return transaction, err
+}
+
+// CompletePayout marks a reserved payout as paid.
+func (w *WalletServiceImpl) CompletePayout(ctx context.Context, reference string) (*models.Transaction, error) {
+	transaction, err := w.transactionService.GetTransactionByReference(ctx, &reference)
+	if err != nil {
+		return nil, err
+	}
+	if transaction.Type != TransactionTypePayout {
+		return nil, ErrNotPayout
+	}
+	return w.transactionService.CompleteTransaction(ctx, reference)
+}
+
+// RefundPayout fails a reserved payout that was not paid and returns the
+// amount to the user's balance and withdrawal limit.
+func (w *WalletServiceImpl) RefundPayout(ctx context.Context, reference string) (transaction *models.Transaction, err error) {
+	err = w.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		transaction, err = w.transactionService.FailTransaction(ctx, reference)
+		if err != nil {
+			return err
+		}
+		if transaction.Type != TransactionTypePayout {
+			return ErrNotPayout
+		}
+		amount, err := strconv.Atoi(transaction.Amount)
+		if err != nil {
+			return fmt.Errorf("payout %s amount: %w", reference, err)
+		}
+		balance, err := w.userService.AdjustBalance(ctx, transaction.User_ID, amount)
+		if err != nil {
+			return err
+		}
+		err = w.ledgerService.Record(ctx, &models.LedgerEntry{
+			User_ID:   transaction.User_ID,
+			Kind:      LedgerPayoutRefund,
+			Reference: reference,
+			Amount:    amount,
+			Balance:   balance,
+		})
+		if err != nil {
+			return err
+		}
+		return w.limitService.RemoveUsage(ctx, reference)
+	})
+	return transaction, err
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/JayJosh846/donationPlatform/models"
+	"github.com/JayJosh846/donationPlatform/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestWalletLimits(t *testing.T) {
+	testWalletLimits(t, &SerialUnitOfWork{}, repository.NewMemory())
+}
+
+// testWalletLimits runs payouts and deposits for the same user at once and
+// checks that together they stay within the daily limits.
+func testWalletLimits(t *testing.T, uow UnitOfWork, repos *repository.Repositories) {
+	ctx := context.Background()
+	limits := map[int]TierLimits{0: {
+		Max_Single_Donation: Unlimited,
+		Daily_Inflow:        3000,
+		Monthly_Inflow:      Unlimited,
+		Daily_Payout:        5000,
+		Max_Balance:         Unlimited,
+	}}
+	users := Constructor(repos.Users, repos.Kycs, repos.Socials, repos.Donations, nil, nil, nil)
+	transactions := TransactionConstructor(repos.Transactions)
+	wallet := WalletConstructor(uow, users, transactions, LedgerConstructor(repos.Ledger), LimitConstructor(repos.LimitUsage, repos.Kycs, limits))
+	newUser := func(balance int) *models.User {
+		id := primitive.NewObjectID()
+		user := &models.User{ID: id, User_ID: id.Hex(), Balance: balance}
+		if err := repos.Users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	t.Run("payouts", func(t *testing.T) {
+		user := newUser(10000)
+		var wg sync.WaitGroup
+		errs := make([]error, 8)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = wallet.ReservePayout(ctx, user, 1000, uuid.NewString())
+			}(i)
+		}
+		wg.Wait()
+
+		var reserved int
+		for _, err := range errs {
+			var breach *LimitError
+			switch {
+			case err == nil:
+				reserved++
+			case !errors.As(err, &breach) || breach.Limit != LimitDailyPayout:
+				t.Errorf("ReservePayout = %v, want nil or the daily payout limit", err)
+			}
+		}
+		if reserved != 5 {
+			t.Fatalf("%d payouts reserved, want 5", reserved)
+		}
+		if current, _ := users.GetUserByID(ctx, user.User_ID); current.Balance != 5000 {
+			t.Fatalf("balance = %d, want 5000", current.Balance)
+		}
+	})
+
+	t.Run("deposits", func(t *testing.T) {
+		user := newUser(0)
+		references := make([]string, 5)
+		for i := range references {
+			references[i] = uuid.NewString()
+			if err := transactions.CreateTransaction(ctx, &models.Transaction{
+				ID:        primitive.NewObjectID(),
+				Reference: &references[i],
+				User_ID:   user.User_ID,
+				Amount:    "1000",
+				Status:    TransactionPending,
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, len(references))
+		for i, reference := range references {
+			wg.Add(1)
+			go func(i int, reference string) {
+				defer wg.Done()
+				_, _, errs[i] = wallet.CreditDeposit(ctx, reference, 1000)
+			}(i, reference)
+		}
+		wg.Wait()
+
+		var credited, held int
+		for i, err := range errs {
+			var breach *LimitError
+			switch {
+			case err == nil:
+				credited++
+			case errors.As(err, &breach) && breach.Limit == LimitDailyInflow:
+				held++
+				if transaction, _ := transactions.GetTransactionByReference(ctx, &references[i]); transaction.Status != TransactionHeld {
+					t.Errorf("over the limit donation is %s, want held", transaction.Status)
+				}
+			default:
+				t.Errorf("CreditDeposit = %v, want nil or the daily inflow limit", err)
+			}
+		}
+		if credited != 3 || held != 2 {
+			t.Fatalf("%d credited and %d held, want 3 and 2", credited, held)
+		}
+		if current, _ := users.GetUserByID(ctx, user.User_ID); current.Balance != 3000 {
+			t.Fatalf("balance = %d, want 3000", current.Balance)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// Workers runs best-effort jobs, such as notifications, off the request
+// path and lets shutdown wait for the ones still running. A nil *Workers
+// runs jobs inline.
+type Workers struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	stopped bool
+}
+
+func NewWorkers() *Workers {
+	return &Workers{}
+}
+
+// Go runs job in the background. Once Wait has been called jobs run inline
+// instead, so nothing started during shutdown is lost.
+func (w *Workers) Go(job func()) {
+	if w == nil {
+		job()
+		return
+	}
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		job()
+		return
+	}
+	w.wg.Add(1)
+	w.mu.Unlock()
+	go func() {
+		defer w.wg.Done()
+		job()
+	}()
+}
+
+// Wait blocks until every running job has finished or ctx is done.
+func (w *Workers) Wait(ctx context.Context) error {
+	w.mu.Lock()
+	w.stopped = true
+	w.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
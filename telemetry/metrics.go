@@ -0,0 +1,129 @@
+// Package telemetry holds the Prometheus metrics and the OpenTelemetry
+// tracing set up shared by the server.
+package telemetry
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric the server exports. It is separate from the
+// Prometheus default so only what is declared here is exposed.
+var Registry = prometheus.NewRegistry()
+
+// durationBuckets suit both API handlers and provider calls, which range
+// from milliseconds to the provider timeout.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by route and status code.",
+	}, []string{"method", "route", "status"})
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests, by route.",
+		Buckets: durationBuckets,
+	}, []string{"method", "route"})
+	HTTPInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+
+	ProviderRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "provider_requests_total",
+		Help: "Calls to external providers, by outcome: 2xx, 4xx, 5xx, error or circuit_open.",
+	}, []string{"provider", "method", "path", "outcome"})
+	ProviderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "provider_request_duration_seconds",
+		Help:    "Time taken by a single attempt of a provider call.",
+		Buckets: durationBuckets,
+	}, []string{"provider", "method", "path"})
+
+	WebhookEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_events_total",
+		Help: "Payment webhooks received, by outcome.",
+	}, []string{"outcome"})
+
+	Payins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "payins_total",
+		Help: "Payment links requested by donors, by outcome.",
+	}, []string{"outcome"})
+	Donations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "donations_total",
+		Help: "Donations confirmed by the payment provider, by outcome: credited, held or failed.",
+	}, []string{"outcome"})
+	DonationVolume = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "donation_volume_naira_total",
+		Help: "Naira received in donations, by outcome.",
+	}, []string{"outcome"})
+	Payouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "payouts_total",
+		Help: "Payouts to recipients' banks, by outcome.",
+	}, []string{"outcome"})
+	PayoutVolume = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "payout_volume_naira_total",
+		Help: "Naira paid out to recipients.",
+	})
+)
+
+// Webhook, payin, donation and payout outcomes.
+const (
+	OutcomeSucceeded        = "succeeded"
+	OutcomeFailed           = "failed"
+	OutcomeRejected         = "rejected"
+	OutcomeCredited         = "credited"
+	OutcomeHeld             = "held"
+	OutcomeInvalidSignature = "invalid_signature"
+	OutcomeInvalidPayload   = "invalid_payload"
+	OutcomeUnknownUser      = "unknown_user"
+	OutcomeUnknownReference = "unknown_reference"
+	OutcomeDuplicate        = "duplicate"
+	OutcomeIgnored          = "ignored"
+	OutcomePending          = "pending"
+	OutcomeRefunded         = "refunded"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration, HTTPInFlight,
+		ProviderRequests, ProviderDuration,
+		WebhookEvents, Payins, Donations, DonationVolume, Payouts, PayoutVolume,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format. When token is
+// set, scrapers must send it as a bearer token.
+func Handler(token string) http.Handler {
+	metrics := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	if token == "" {
+		return metrics
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		metrics.ServeHTTP(w, r)
+	})
+}
+
+// StatusOutcome buckets an HTTP status into 2xx, 4xx or 5xx.
+func StatusOutcome(status int) string {
+	switch {
+	case status >= 500:
+		return "5xx"
+	case status >= 400:
+		return "4xx"
+	case status >= 300:
+		return "3xx"
+	}
+	return "2xx"
+}
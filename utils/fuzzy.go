@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// NameSimilarity scores how closely name matches reference, from 0 to 1,
+// the same either way round. Each part of the shorter name is paired with
+// its closest part of the longer one, so word order, case, punctuation and
+// one missing middle name do not lower the score, while small typos only
+// lower it a little. Any other part left unpaired counts as a miss, so a
+// lone first name does not match a full name.
+func NameSimilarity(name, reference string) float64 {
+	parts := nameParts(name)
+	refParts := nameParts(reference)
+	if len(parts) == 0 || len(refParts) == 0 {
+		return 0
+	}
+	total := pairParts(parts, refParts)
+	if len(parts) == len(refParts) {
+		// Greedy pairing can depend on which side leads.
+		total = min(total, pairParts(refParts, parts))
+	} else if len(parts) > len(refParts) {
+		parts, refParts = refParts, parts
+		total = pairParts(parts, refParts)
+	}
+	unpaired := len(refParts) - len(parts)
+	if len(parts) >= 2 && unpaired > 0 {
+		unpaired--
+	}
+	return total / float64(len(parts)+unpaired)
+}
+
+// pairParts pairs each of parts with its closest unused part of refParts
+// and sums the scores.
+func pairParts(parts, refParts []string) float64 {
+	used := make([]bool, len(refParts))
+	var total float64
+	for _, part := range parts {
+		best, bestIndex := 0.0, -1
+		for i, ref := range refParts {
+			if used[i] {
+				continue
+			}
+			if score := stringSimilarity(part, ref); score > best {
+				best, bestIndex = score, i
+			}
+		}
+		if bestIndex >= 0 {
+			used[bestIndex] = true
+		}
+		total += best
+	}
+	return total
+}
+
+func nameParts(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+// stringSimilarity is one minus the edit distance relative to the longer
+// string.
+func stringSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+var dateLayouts = []string{
+	"2006-01-02",
+	"02-01-2006",
+	"02/01/2006",
+	"2006/01/02",
+	"02-Jan-2006",
+	"2 January 2006",
+	"January 2, 2006",
+	time.RFC3339,
+}
+
+// ParseDate reads a date of birth in any of the formats identity providers
+// and the signup form use.
+func ParseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// SameDate reports whether two dates of birth in any supported format are
+// the same day.
+func SameDate(a, b string) bool {
+	ta, okA := ParseDate(a)
+	tb, okB := ParseDate(b)
+	if !okA || !okB {
+		return false
+	}
+	ya, ma, da := ta.Date()
+	yb, mb, db := tb.Date()
+	return ya == yb && ma == mb && da == db
+}
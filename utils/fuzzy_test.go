@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		name, reference string
+		want            float64
+	}{
+		{"John Michael Smith", "John Michael Smith", 1},
+		{"smith, JOHN michael", "John Michael Smith", 1},
+		{"John Smith", "John Michael Smith", 1},
+		{"Jon Michael Smith", "John Michael Smith", (0.75 + 1 + 1) / 3},
+		{"John", "John Michael Smith", 1.0 / 3},
+		{"John", "John Smith", 0.5},
+		{"Smith", "John Michael Smith", 1.0 / 3},
+		{"John Smith", "John Paul Michael Smith", 2.0 / 3},
+		{"Xu", "John Smith", 0},
+		{"", "John Smith", 0},
+	}
+	for _, tt := range tests {
+		// The score must not depend on which name is the reference.
+		for _, args := range [][2]string{{tt.name, tt.reference}, {tt.reference, tt.name}} {
+			if got := NameSimilarity(args[0], args[1]); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("NameSimilarity(%q, %q) = %.3f, want %.3f", args[0], args[1], got, tt.want)
+			}
+		}
+	}
+}
@@ -4,8 +4,9 @@ import (
 	cryptorand "crypto/rand"
 	"encoding/base64"
 	"fmt"
-	"log"
+	"math/big"
 	mathrand "math/rand"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -13,35 +14,31 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-type Usererror struct {
-	Error        bool   `json:"error"`
-	ResponseCode int    `json:"response code"`
-	Message      string `json:"message"`
-	Data         string `json:"data"`
-}
+// PasswordHashCost is the bcrypt cost for new hashes. Existing hashes keep
+// the cost they were created with.
+const PasswordHashCost = 12
+
+// passwordSlots bounds how many bcrypt operations run at once so a burst of
+// login attempts cannot starve every other request of CPU.
+var passwordSlots = make(chan struct{}, runtime.NumCPU())
 
-func HashPassword(password string) string {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+// HashPassword fails only for passwords longer than bcrypt's 72 bytes.
+func HashPassword(password string) (string, error) {
+	passwordSlots <- struct{}{}
+	defer func() { <-passwordSlots }()
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), PasswordHashCost)
 	if err != nil {
-		log.Panic(err)
+		return "", err
 	}
-	return string(bytes)
+	return string(bytes), nil
 }
 
-func VerifyPassword(userpassword string, givenpassword string) (bool, Usererror) {
+// VerifyPassword reports whether userpassword matches the stored hash.
+func VerifyPassword(userpassword string, givenpassword string) bool {
+	passwordSlots <- struct{}{}
+	defer func() { <-passwordSlots }()
 	err := bcrypt.CompareHashAndPassword([]byte(givenpassword), []byte(userpassword))
-	valid := true
-	if err != nil {
-		valid = false
-		return valid,
-			Usererror{
-				Error:        true,
-				ResponseCode: 400,
-				Message:      "Invalid Password",
-				Data:         "",
-			}
-	}
-	return valid, Usererror{}
+	return err == nil
 }
 
 func GenerateTransactionReference() string {
@@ -77,7 +74,12 @@ func GenerateRandomPassword(length int) (string, error) {
 	return password, nil
 }
 
-func GenerateVerificationCode() string {
-	code := mathrand.Intn(900000) + 100000
-	return strconv.Itoa(code)
+// GenerateVerificationCode returns a uniformly random six digit code from
+// the system CSPRNG.
+func GenerateVerificationCode() (string, error) {
+	n, err := cryptorand.Int(cryptorand.Reader, big.NewInt(900000))
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(n.Int64()+100000, 10), nil
 }
@@ -1,18 +1,13 @@
 package utils
 
 import (
-	"context"
-	"log"
-	"os"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
 	"time"
 
-	"github.com/JayJosh846/donationPlatform/database"
-
 	jwt "github.com/dgrijalva/jwt-go"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type SignedDetails struct {
@@ -21,8 +16,9 @@ type SignedDetails struct {
 	jwt.StandardClaims
 }
 
-var UserData *mongo.Collection = database.GetUserCollection(database.Client, "Users")
-var SECRET_KEY = os.Getenv("SECRETS")
+// SECRET_KEY signs tokens and file links. It is set from the configuration
+// at startup.
+var SECRET_KEY string
 
 func TokenGenerator(uid string, email string) (signedtoken string, signedrefreshtoken string, err error) {
 	claims := &SignedDetails{
@@ -45,10 +41,9 @@ func TokenGenerator(uid string, email string) (signedtoken string, signedrefresh
 	}
 	refreshtoken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshclaims).SignedString([]byte(SECRET_KEY))
 	if err != nil {
-		log.Panicln(err)
-		return
+		return "", "", err
 	}
-	return token, refreshtoken, err
+	return token, refreshtoken, nil
 }
 
 func ValidateToken(signedtoken string) (claims *SignedDetails, msg string, err error) {
@@ -73,26 +68,28 @@ func ValidateToken(signedtoken string) (claims *SignedDetails, msg string, err e
 	return claims, msg, err
 }
 
-func UpdateAllTokens(signedtoken string, signedrefreshtoken string, userid string) {
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	var updateobj primitive.D
-	updateobj = append(updateobj, bson.E{Key: "token", Value: signedtoken})
-	updateobj = append(updateobj, bson.E{Key: "refresh_token", Value: signedrefreshtoken})
-	updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	updateobj = append(updateobj, bson.E{Key: "updatedat", Value: updated_at})
-	upsert := true
-	filter := bson.M{"user_id": userid}
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
-	}
-	_, err := UserData.UpdateOne(ctx, filter, bson.D{
-		{Key: "$set", Value: updateobj},
-	},
-		&opt)
-	defer cancel()
-	if err != nil {
-		log.Panic(err)
-		return
-	}
+// HashOtp keys the code hash with the server secret and binds it to the
+// user and purpose, so a leaked OTP collection does not reveal codes and a
+// code cannot be replayed for another user or purpose.
+func HashOtp(userID, purpose, code string) string {
+	h := hmac.New(sha256.New, []byte(SECRET_KEY))
+	h.Write([]byte(userID + "|" + purpose + "|" + code))
+	return hex.EncodeToString(h.Sum(nil))
+}
 
+// SignFileURL signs a private file key until expires (Unix seconds), so a
+// link to it can be handed out without the caller's token.
+func SignFileURL(key string, expires int64) string {
+	h := hmac.New(sha256.New, []byte(SECRET_KEY))
+	h.Write([]byte("file|" + key + "|" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyFileURL checks a signature from SignFileURL and that it has not
+// expired.
+func VerifyFileURL(key string, expires int64, signature string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(SignFileURL(key, expires)), []byte(signature))
 }